@@ -0,0 +1,139 @@
+// Package clock lets the time dependent logic (booking windows, no-shows, rate limits...)
+// read the time through an interface, so that tests can control it instead of depending on the wall clock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates tickers
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on C like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock
+type Real struct{}
+
+// Now returns time.Now()
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns a time.Ticker
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// Fake is a clock which only moves when the test sets or advances it.
+// Its tickers fire when the time is moved past their next tick.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFake returns a fake clock stopped at the given time
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the time the fake clock is stopped at
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to the given time, firing the tickers due by then
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	f.now = now
+	tickers := append([]*fakeTicker(nil), f.tickers...)
+	f.mu.Unlock()
+
+	for _, ticker := range tickers {
+		ticker.fire(now)
+	}
+}
+
+// Advance moves the clock forward by d, firing the tickers due by then
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// NewTicker returns a ticker firing every d of fake time
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ticker := &fakeTicker{clock: f, interval: d, next: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.tickers = append(f.tickers, ticker)
+	return ticker
+}
+
+type fakeTicker struct {
+	clock    *Fake
+	interval time.Duration
+
+	mu      sync.Mutex
+	next    time.Time
+	stopped bool
+	c       chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+// fire sends a tick if the ticker is due, like time.Ticker ticks are dropped when the reader is too slow
+func (t *fakeTicker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped || now.Before(t.next) {
+		return
+	}
+	for !now.Before(t.next) {
+		t.next = t.next.Add(t.interval)
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	t.stopped = true
+	t.mu.Unlock()
+
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// the fake clock only moves when it is told to
+func TestFake_Now(t *testing.T) {
+	start := time.Date(2024, time.October, 2, 9, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if !fake.Now().Equal(start) {
+		t.Errorf("expected %v, got %v", start, fake.Now())
+	}
+	fake.Advance(90 * time.Minute)
+	if expected := start.Add(90 * time.Minute); !fake.Now().Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, fake.Now())
+	}
+}
+
+// tickers fire once the clock moves past their next tick and not after being stopped
+func TestFake_Ticker(t *testing.T) {
+	fake := NewFake(time.Date(2024, time.October, 2, 9, 0, 0, 0, time.UTC))
+	ticker := fake.NewTicker(time.Minute)
+
+	fake.Advance(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatalf("expected no tick before the interval")
+	default:
+	}
+
+	fake.Advance(30 * time.Second)
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(fake.Now()) {
+			t.Errorf("expected the tick at %v, got %v", fake.Now(), tick)
+		}
+	default:
+		t.Fatalf("expected a tick after the interval")
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Errorf("expected no tick after stop")
+	default:
+	}
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/MeherKandukuri/studioClasses_API/handlers"
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+	"github.com/MeherKandukuri/studioClasses_API/outbox"
 	"github.com/MeherKandukuri/studioClasses_API/routes"
 )
 
@@ -11,6 +17,49 @@ const portNumber = ":8080"
 
 func main() {
 	log.Println("We are starting on port number:", portNumber)
+
+	// keeping the events in a journal file so that the ones not delivered yet survive a restart
+	if path := os.Getenv("OUTBOX_PATH"); path != "" {
+		store, err := outbox.OpenFileStore(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer store.Close()
+		handlers.SetOutboxStore(store)
+	}
+
+	// emailing the notifications to the members when an SMTP server is configured
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		if os.Getenv("SMTP_FROM") == "" {
+			log.Fatalln("SMTP_FROM is needed to send emails")
+		}
+		templates, err := notify.LoadTemplates(os.Getenv("EMAIL_TEMPLATES_DIR"))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		handlers.SetNotifier(notify.NewSMTPNotifier(notify.SMTPConfig{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}, templates))
+	}
+
+	// marking bookings nobody checked in for as no-shows once their class ends
+	go handlers.RunNoShowSweeper(context.Background(), time.Minute)
+
+	// giving the seats of holds which were not converted in time back to their class
+	go handlers.RunHoldSweeper(context.Background(), 15*time.Second)
+
+	// delivering the notifications queued for members, e.g when an instructor is substituted
+	go handlers.RunNotifications(context.Background())
+
+	// delivering the events of the outbox to the subscribers of the event bus
+	go handlers.RunOutbox(context.Background())
+
+	// delivering the class and booking events to the webhooks
+	go handlers.RunWebhooks(context.Background())
+
 	server := run()
 	err := server.ListenAndServe()
 	if err != nil {
@@ -0,0 +1,284 @@
+// Package events is the in-process event bus of the studio.
+// The class and booking operations publish typed events once they succeeded, and the features reacting to them
+// (metrics, webhooks...) subscribe to the bus instead of being called from the handlers.
+//
+// Synchronous subscribers are done with an event once their handler returns. Asynchronous subscribers start their work,
+// e.g queue a webhook for their own workers, and acknowledge the event once the work is over. The bus does not queue
+// anything itself, so that the publisher can keep an event until its subscribers acknowledged it and the event is not
+// lost with the queue of a subscriber when the process stops.
+//
+// An event whose delivery failed is delivered again, with the same id, to the subscribers which did not handle it.
+// Subscribers can get an event twice after a restart and should drop the events whose id they have already seen
+// when that matters.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// types of the events
+const (
+	TypeClassCreated     = "class.created"
+	TypeBookingCreated   = "booking.created"
+	TypeBookingCancelled = "booking.cancelled"
+	TypeClassChanged     = "class.changed"
+)
+
+// Event is something which happened in the studio
+type Event interface {
+	// Type is one of the Type constants
+	Type() string
+	// Key is the id of the class the event is about, the events of a class are delivered in order
+	Key() string
+	// Metadata returns the id of the event and when it happened
+	Metadata() Meta
+}
+
+// Meta is embedded in every event
+type Meta struct {
+	// ID identifies the event, it stays the same when the event is delivered again so that consumers can drop duplicates
+	ID string
+	At time.Time
+}
+
+// NewMeta returns the metadata of an event which happened at the given time, with a new id
+func NewMeta(at time.Time) Meta {
+	return Meta{ID: helpers.NewID(), At: at.UTC()}
+}
+
+// Metadata returns the metadata itself, it lets the events embedding Meta implement Event
+func (m Meta) Metadata() Meta { return m }
+
+// ClassCreated is published when a class is created, Sessions is the number of sessions on its dates
+type ClassCreated struct {
+	Meta
+	Class    models.Class
+	Sessions int
+}
+
+func (ClassCreated) Type() string  { return TypeClassCreated }
+func (e ClassCreated) Key() string { return e.Class.ID }
+
+// BookingCreated is published once a member is enrolled, after the payment of drop-ins was captured
+type BookingCreated struct {
+	Meta
+	Booking models.Booking
+	Class   models.Class
+}
+
+func (BookingCreated) Type() string  { return TypeBookingCreated }
+func (e BookingCreated) Key() string { return e.Booking.ClassID }
+
+// BookingCancelled is published when a member cancels their booking
+type BookingCancelled struct {
+	Meta
+	Booking    models.Booking
+	Class      models.Class
+	LateCancel bool
+}
+
+func (BookingCancelled) Type() string  { return TypeBookingCancelled }
+func (e BookingCancelled) Key() string { return e.Booking.ClassID }
+
+// ClassChanged is published when the instructor of a session of a class is substituted,
+// with the bookings of the members enrolled in the session
+type ClassChanged struct {
+	Meta
+	Class                models.Class
+	Date                 time.Time
+	PreviousInstructorID string
+	Bookings             []models.Booking
+}
+
+func (ClassChanged) Type() string  { return TypeClassChanged }
+func (e ClassChanged) Key() string { return e.Class.ID }
+
+// Decode returns the event of the type encoded as JSON in payload, it is used to read back the events stored in the outbox
+func Decode(eventType string, payload []byte) (Event, error) {
+	var event Event
+	var err error
+	switch eventType {
+	case TypeClassCreated:
+		var e ClassCreated
+		err = json.Unmarshal(payload, &e)
+		event = e
+	case TypeBookingCreated:
+		var e BookingCreated
+		err = json.Unmarshal(payload, &e)
+		event = e
+	case TypeBookingCancelled:
+		var e BookingCancelled
+		err = json.Unmarshal(payload, &e)
+		event = e
+	case TypeClassChanged:
+		var e ClassChanged
+		err = json.Unmarshal(payload, &e)
+		event = e
+	default:
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s event: %w", eventType, err)
+	}
+	return event, nil
+}
+
+// Handler reacts to an event, the error of a synchronous handler fails the delivery of the event to its subscriber
+type Handler func(ctx context.Context, event Event) error
+
+// Ack is called once an asynchronous subscriber is done with an event, with the error which failed it
+type Ack func(err error)
+
+// Split returns an ack for each of the n parts the work on an event is split in, ack is called with their errors joined
+// once every part is done, right away when there are no parts
+func (ack Ack) Split(n int) []Ack {
+	if n == 0 {
+		ack(nil)
+		return nil
+	}
+	var mu sync.Mutex
+	left := n
+	var errs []error
+	parts := make([]Ack, n)
+	for i := range parts {
+		var once sync.Once
+		parts[i] = func(err error) {
+			once.Do(func() {
+				mu.Lock()
+				left--
+				errs = append(errs, err)
+				done := left == 0
+				mu.Unlock()
+				if done {
+					ack(errors.Join(errs...))
+				}
+			})
+		}
+	}
+	return parts
+}
+
+// AsyncHandler starts handling an event in the background, e.g queues it for a worker, and calls ack once it is done
+// with it, possibly from another goroutine. It returns an error when the event could not be started, ack must not be
+// called then.
+type AsyncHandler func(ctx context.Context, event Event, ack Ack) error
+
+type subscriber struct {
+	name    string
+	types   []string
+	handler Handler
+	// async is the handler of asynchronous subscribers, handler is nil for them
+	async AsyncHandler
+}
+
+// wants reports whether the subscriber is for events of the type, subscribers without types get every event
+func (s *subscriber) wants(eventType string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver hands the event to the subscriber and calls ack once it is done with it. A subscriber panicking
+// fails the event so that it cannot break the publisher or the others.
+func (s *subscriber) deliver(ctx context.Context, event Event, ack Ack) {
+	var once sync.Once
+	ackOnce := func(err error) {
+		once.Do(func() {
+			if err != nil {
+				err = fmt.Errorf("subscriber %s could not handle %s: %w", s.name, event.Type(), err)
+			}
+			ack(err)
+		})
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			once.Do(func() { ack(fmt.Errorf("subscriber %s panicked handling %s: %v", s.name, event.Type(), recovered)) })
+		}
+	}()
+
+	if s.async == nil {
+		ackOnce(s.handler(ctx, event))
+		return
+	}
+	if err := s.async(ctx, event, ackOnce); err != nil {
+		ackOnce(err)
+	}
+}
+
+// Bus hands the events to its subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+}
+
+// NewBus returns a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls the handler for the events of the types, or every event when no type is given.
+// The handler runs while the event is delivered, so it must be quick and must not call back into the publisher.
+func (b *Bus) Subscribe(name string, handler Handler, types ...string) {
+	b.add(&subscriber{name: name, types: types, handler: handler})
+}
+
+// SubscribeAsync starts the handler for the events of the types, or every event when no type is given.
+// The subscriber does its work in the background, e.g delivers a webhook, and acknowledges the event once it is done.
+func (b *Bus) SubscribeAsync(name string, handler AsyncHandler, types ...string) {
+	b.add(&subscriber{name: name, types: types, async: handler})
+}
+
+func (b *Bus) add(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+// Subscribers returns the names of the subscribers wanting the events of the type, in the order they subscribed
+func (b *Bus) Subscribers(eventType string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var names []string
+	for _, s := range b.subscribers {
+		if s.wants(eventType) {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// Deliver hands the event to the named subscriber and calls ack once the subscriber is done with it: right away for
+// synchronous subscribers, when the work in the background is over for asynchronous ones.
+// ack is called exactly once, with an error when the subscriber failed or does not exist.
+func (b *Bus) Deliver(ctx context.Context, name string, event Event, ack Ack) {
+	b.mu.RLock()
+	var found *subscriber
+	for _, s := range b.subscribers {
+		if s.name == name {
+			found = s
+			break
+		}
+	}
+	b.mu.RUnlock()
+
+	if found == nil {
+		ack(fmt.Errorf("no subscriber %s for %s", name, event.Type()))
+		return
+	}
+	found.deliver(ctx, event, ack)
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+func bookingCreated(classID, name string) BookingCreated {
+	return BookingCreated{Booking: models.Booking{ID: name, ClassID: classID, Name: name}}
+}
+
+// deliver hands the event to every subscriber wanting it and returns their acks
+func deliver(bus *Bus, event Event) []error {
+	var acks []error
+	for _, name := range bus.Subscribers(event.Type()) {
+		bus.Deliver(context.Background(), name, event, func(err error) { acks = append(acks, err) })
+	}
+	return acks
+}
+
+// synchronous subscribers are done with the events once they return, they only get the types they want
+func TestBus_Subscribe(t *testing.T) {
+	bus := NewBus()
+	var calls []string
+	bus.Subscribe("all", func(ctx context.Context, e Event) error {
+		calls = append(calls, "all:"+e.Type())
+		return nil
+	})
+	bus.Subscribe("bookings", func(ctx context.Context, e Event) error {
+		calls = append(calls, "bookings:"+e.Type())
+		return nil
+	}, TypeBookingCreated)
+
+	deliver(bus, ClassCreated{Class: models.Class{ID: "c1"}})
+	if acks := deliver(bus, bookingCreated("c1", "Meher")); len(acks) != 2 || acks[0] != nil || acks[1] != nil {
+		t.Errorf("expected both subscribers to acknowledge the booking, got %v", acks)
+	}
+
+	expected := []string{"all:class.created", "all:booking.created", "bookings:booking.created"}
+	if fmt.Sprint(calls) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, calls)
+	}
+}
+
+// a panicking or failing subscriber fails the delivery to itself only, so that it can be retried
+func TestBus_SubscriberFails(t *testing.T) {
+	bus := NewBus()
+	bus.Subscribe("broken", func(ctx context.Context, e Event) error { panic("boom") })
+	bus.Subscribe("failing", func(ctx context.Context, e Event) error { return errors.New("smtp is down") })
+	bus.Subscribe("working", func(ctx context.Context, e Event) error { return nil })
+
+	acks := deliver(bus, bookingCreated("c1", "Meher"))
+	if len(acks) != 3 || acks[0] == nil || !strings.Contains(acks[0].Error(), "broken panicked") ||
+		acks[1] == nil || !strings.Contains(acks[1].Error(), "smtp is down") || acks[2] != nil {
+		t.Errorf("expected the errors of the first two subscribers, got %v", acks)
+	}
+}
+
+// asynchronous subscribers acknowledge the events once their work is over, those which cannot start fail right away
+func TestBus_SubscribeAsync(t *testing.T) {
+	bus := NewBus()
+	var queued []Ack
+	bus.SubscribeAsync("queue", func(ctx context.Context, e Event, ack Ack) error {
+		if len(queued) == 1 {
+			return errors.New("queue is full")
+		}
+		queued = append(queued, ack)
+		return nil
+	}, TypeBookingCreated)
+	bus.Subscribe("metrics", func(ctx context.Context, e Event) error { return nil })
+
+	if names := bus.Subscribers(TypeBookingCreated); fmt.Sprint(names) != "[queue metrics]" {
+		t.Errorf("expected both subscribers, got %v", names)
+	}
+	if names := bus.Subscribers(TypeClassCreated); fmt.Sprint(names) != "[metrics]" {
+		t.Errorf("expected only the subscriber of every event, got %v", names)
+	}
+
+	var acks []error
+	for i := 0; i < 2; i++ {
+		bus.Deliver(context.Background(), "queue", bookingCreated("c1", "Meher"), func(err error) { acks = append(acks, err) })
+	}
+	if len(acks) != 1 || acks[0] == nil || !strings.Contains(acks[0].Error(), "queue is full") {
+		t.Fatalf("expected only the event which could not be queued to be acknowledged, got %v", acks)
+	}
+	queued[0](nil)
+	queued[0](errors.New("acknowledged twice"))
+	if len(acks) != 2 || acks[1] != nil {
+		t.Errorf("expected the queued event to be acknowledged once, got %v", acks)
+	}
+
+	bus.Deliver(context.Background(), "webhooks", bookingCreated("c1", "Meher"), func(err error) { acks = append(acks, err) })
+	if len(acks) != 3 || acks[2] == nil {
+		t.Errorf("expected an unknown subscriber to fail, got %v", acks)
+	}
+}
+
+// the work on an event split in parts is done once every part is
+func TestAck_Split(t *testing.T) {
+	var errs []error
+	ack := Ack(func(err error) { errs = append(errs, err) })
+
+	parts := ack.Split(2)
+	parts[0](errors.New("receiver is down"))
+	if len(errs) != 0 {
+		t.Fatalf("expected to wait for the second part, got %v", errs)
+	}
+	parts[1](nil)
+	if len(errs) != 1 || errs[0] == nil || errs[0].Error() != "receiver is down" {
+		t.Errorf("expected the error of the first part, got %v", errs)
+	}
+
+	ack.Split(0)
+	if len(errs) != 2 || errs[1] != nil {
+		t.Errorf("expected the work without parts to be done right away, got %v", errs)
+	}
+}
+
+// events read back from their JSON are the events which were encoded
+func TestDecode(t *testing.T) {
+	event := BookingCancelled{Meta: NewMeta(time.Now()), Booking: models.Booking{ID: "b1", ClassID: "c1", Name: "Meher"}, Class: models.Class{ID: "c1", Duration: time.Hour}, LateCancel: true}
+	payload, _ := json.Marshal(event)
+
+	decoded, err := Decode(event.Type(), payload)
+	if err != nil {
+		t.Fatalf("could not decode: %v", err)
+	}
+	if cancelled, ok := decoded.(BookingCancelled); !ok || cancelled.ID != event.ID || !cancelled.LateCancel || cancelled.Booking.Name != "Meher" || cancelled.Class.Duration != time.Hour {
+		t.Errorf("unexpected event %+v", decoded)
+	}
+	if _, err := Decode("class.moved", payload); err == nil {
+		t.Errorf("expected an error for an unknown type")
+	}
+}
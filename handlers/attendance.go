@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// checkInOpensBefore is how long before the start of a class the front desk can check members in
+const checkInOpensBefore = time.Hour
+
+// AttendanceRecord is a booking in the attendance history of a member
+type AttendanceRecord struct {
+	BookingID   string     `json:"booking_id"`
+	ClassName   string     `json:"class_name"`
+	Date        string     `json:"date"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// AttendanceHistory is the response of the attendance endpoint
+type AttendanceHistory struct {
+	Member    string             `json:"member"`
+	Attended  int                `json:"attended"`
+	NoShows   int                `json:"no_shows"`
+	Upcoming  int                `json:"upcoming"`
+	Cancelled int                `json:"cancelled"`
+	Bookings  []AttendanceRecord `json:"bookings"`
+}
+
+// BookingResponse is how a booking is shown by the API
+type BookingResponse struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	ClassName   string     `json:"class_name"`
+	Date        string     `json:"date"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+	// Spot is the numbered spot the member holds, in classes with numbered spots
+	Spot int `json:"spot,omitempty"`
+	// PaymentStatus and AmountCents describe the payment of drop-in bookings
+	PaymentStatus string `json:"payment_status,omitempty"`
+	AmountCents   int    `json:"amount_cents,omitempty"`
+}
+
+// newBookingResponse builds the response for a booking of the class
+func newBookingResponse(booking models.Booking, class models.Class) BookingResponse {
+	response := BookingResponse{
+		ID:            booking.ID,
+		Name:          booking.Name,
+		ClassName:     class.ClassName,
+		Date:          booking.Date.Format("2006-01-02"),
+		Status:        booking.Status,
+		CreatedAt:     booking.CreatedAt,
+		Spot:          booking.Spot,
+		PaymentStatus: booking.PaymentStatus,
+		AmountCents:   booking.AmountCents,
+	}
+	if !booking.CheckedInAt.IsZero() {
+		checkedInAt := booking.CheckedInAt
+		response.CheckedInAt = &checkedInAt
+	}
+	return response
+}
+
+// Handler for fetching a single booking
+func GetBooking(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	storageMu.RLock()
+	datestr, i, found := findBooking(id)
+	var response BookingResponse
+	if found {
+		booking := bookings[datestr][i]
+		class, _ := bookingClass(booking)
+		booking.Status = currentStatus(booking, clk.Now())
+		response = newBookingResponse(booking, class)
+	}
+	storageMu.RUnlock()
+
+	if !found {
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, response, http.StatusOK)
+}
+
+// Handler for the front desk to check a member in for their booking
+func PostCheckIn(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	current := clk.Now()
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	// making sure bookings of classes which already ended cannot be checked in anymore
+	markNoShows(current)
+
+	datestr, i, found := findBooking(id)
+	if !found {
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	}
+	booking := &bookings[datestr][i]
+	class, _ := bookingClass(*booking)
+
+	switch booking.Status {
+	case models.StatusCheckedIn:
+		http.Error(w, "Booking is already checked in", http.StatusConflict)
+		return
+	case models.StatusNoShow:
+		http.Error(w, "The class has ended, the booking was marked as a no-show", http.StatusConflict)
+		return
+	case models.StatusPendingPayment, models.StatusReleased:
+		http.Error(w, "The booking was not paid for", http.StatusConflict)
+		return
+	case models.StatusHeld, models.StatusExpired:
+		http.Error(w, "The seat was only held, the hold was not converted into a booking", http.StatusConflict)
+		return
+	}
+
+	if current.Before(class.Start(booking.Date).Add(-checkInOpensBefore)) {
+		http.Error(w, "Check-in opens 1 hour before the class starts", http.StatusConflict)
+		return
+	}
+
+	booking.Status = models.StatusCheckedIn
+	booking.CheckedInAt = current.UTC()
+
+	message := fmt.Sprintf("%s has been checked in for class on %s", booking.Name, datestr)
+	helpers.WriteJSONResponse(w, message, http.StatusOK)
+}
+
+// Handler for the attendance history of a member, members are identified by their name like in PostCreateBooking
+func GetMemberAttendance(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+
+	current := clk.Now()
+	storageMu.RLock()
+
+	history := AttendanceHistory{Member: member, Bookings: []AttendanceRecord{}}
+	for datestr, booked := range bookings {
+		for _, booking := range booked {
+			if !strings.EqualFold(booking.Name, member) {
+				continue
+			}
+
+			class, _ := bookingClass(booking)
+			booking.Status = currentStatus(booking, current)
+			record := AttendanceRecord{
+				BookingID: booking.ID,
+				ClassName: class.ClassName,
+				Date:      datestr,
+				Status:    booking.Status,
+			}
+			switch booking.Status {
+			case models.StatusCheckedIn:
+				history.Attended++
+				checkedInAt := booking.CheckedInAt
+				record.CheckedInAt = &checkedInAt
+			case models.StatusNoShow:
+				history.NoShows++
+			case models.StatusCancelled:
+				history.Cancelled++
+			case models.StatusReleased, models.StatusHeld, models.StatusExpired:
+				// the drop-in payment failed or the seat was only held, the member never booked it
+			default:
+				history.Upcoming++
+			}
+			history.Bookings = append(history.Bookings, record)
+		}
+	}
+	storageMu.RUnlock()
+
+	sort.Slice(history.Bookings, func(i, j int) bool {
+		return history.Bookings[i].Date < history.Bookings[j].Date
+	})
+
+	helpers.WriteJSON(w, history, http.StatusOK)
+}
+
+// MarkNoShows marks the bookings which were not checked in by the end of their class as no-shows
+func MarkNoShows() int {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	return markNoShows(clk.Now())
+}
+
+// RunNoShowSweeper calls MarkNoShows every interval until the context is done
+func RunNoShowSweeper(ctx context.Context, interval time.Duration) {
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			MarkNoShows()
+		}
+	}
+}
+
+// markNoShows returns the number of bookings it marked, the caller must hold storageMu
+func markNoShows(current time.Time) int {
+	marked := 0
+	for datestr, booked := range bookings {
+		for i := range booked {
+			booking := &bookings[datestr][i]
+			if currentStatus(*booking, current) != models.StatusNoShow || booking.Status == models.StatusNoShow {
+				continue
+			}
+			class, _ := bookingClass(*booking)
+			booking.Status = models.StatusNoShow
+			addStrike(booking.Name, class.End(booking.Date))
+			marked++
+		}
+	}
+	return marked
+}
+
+// currentStatus returns the status of the booking at the given time: a booking which was not checked in by the end
+// of its class is a no-show even before the sweeper marks it. The caller must hold storageMu.
+func currentStatus(booking models.Booking, current time.Time) string {
+	if booking.Status != models.StatusBooked {
+		return booking.Status
+	}
+	if class, found := bookingClass(booking); found && !current.Before(class.End(booking.Date)) {
+		return models.StatusNoShow
+	}
+	return booking.Status
+}
+
+// findBooking returns the date and index of the booking in bookings, the caller must hold storageMu
+func findBooking(id string) (string, int, bool) {
+	for datestr, booked := range bookings {
+		for i, booking := range booked {
+			if booking.ID == id {
+				return datestr, i, true
+			}
+		}
+	}
+	return "", 0, false
+}
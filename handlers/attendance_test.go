@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// withURLParam adds a chi URL parameter to the request as the router would
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.RouteContext(req.Context())
+	if rctx == nil {
+		rctx = chi.NewRouteContext()
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+	rctx.URLParams.Add(key, value)
+	return req
+}
+
+// useFakeClock makes the handlers read the time from a fake clock for the rest of the test
+func useFakeClock(t *testing.T, current time.Time) *clock.Fake {
+	t.Helper()
+	fake := clock.NewFake(current)
+	SetClock(fake)
+	t.Cleanup(func() { SetClock(clock.Real{}) })
+	return fake
+}
+
+// a 9:00 one hour yoga class on the 2nd of October with two bookings
+func setUpAttendanceStorage() time.Time {
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+	classStorage = map[time.Time][]models.Class{
+		date: {{ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour}},
+	}
+	bookings = map[string][]models.Booking{
+		"2024-10-02": {
+			{ID: "b1", Name: "Meher", Date: date, Status: models.StatusBooked},
+			{ID: "b2", Name: "Ravi", Date: date, Status: models.StatusBooked},
+		},
+	}
+	return date
+}
+
+func checkIn(id string) *httptest.ResponseRecorder {
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/bookings/"+id+"/check-in", nil), "id", id)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCheckIn).ServeHTTP(rec, req)
+	return rec
+}
+
+// checking in during the class should mark the booking as checked in, only once
+func TestPostCheckIn(t *testing.T) {
+	date := setUpAttendanceStorage()
+	useFakeClock(t, date.Add(8*time.Hour+45*time.Minute))
+
+	rec := checkIn("b1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.Status != models.StatusCheckedIn || booking.CheckedInAt.IsZero() {
+		t.Errorf("expected the booking to be checked in, got %+v", booking)
+	}
+
+	rec = checkIn("b1")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when checking in twice, got %d", rec.Code)
+	}
+}
+
+// checking in is only possible from an hour before the class until it ends
+func TestPostCheckIn_OutsideClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		at       time.Duration
+		status   int
+		expected string
+	}{
+		{"too early", 7 * time.Hour, http.StatusConflict, "Check-in opens 1 hour before the class starts"},
+		{"after the class", 10 * time.Hour, http.StatusConflict, "The class has ended, the booking was marked as a no-show"},
+	}
+
+	for _, tc := range tests {
+		date := setUpAttendanceStorage()
+		useFakeClock(t, date.Add(tc.at))
+
+		rec := checkIn("b1")
+		if rec.Code != tc.status || strings.TrimSpace(rec.Body.String()) != tc.expected {
+			t.Errorf("%s: expected %d %q, got %d %q", tc.name, tc.status, tc.expected, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := checkIn("unknown")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown booking, got %d", rec.Code)
+	}
+}
+
+// fetching a booking should show its status
+func TestGetBooking(t *testing.T) {
+	date := setUpAttendanceStorage()
+	useFakeClock(t, date.Add(8*time.Hour))
+
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/bookings/b2", nil), "id", "b2")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetBooking).ServeHTTP(rec, req)
+
+	var booking BookingResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &booking); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if booking.ID != "b2" || booking.Name != "Ravi" || booking.ClassName != "Yoga" || booking.Status != models.StatusBooked {
+		t.Errorf("unexpected booking %+v", booking)
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodGet, "/bookings/unknown", nil), "id", "unknown")
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetBooking).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// bookings which were not checked in by the end of the class are marked as no-shows
+func TestMarkNoShows(t *testing.T) {
+	date := setUpAttendanceStorage()
+	bookings["2024-10-02"][0].Status = models.StatusCheckedIn
+
+	fake := useFakeClock(t, date.Add(9*time.Hour+30*time.Minute))
+	if marked := MarkNoShows(); marked != 0 {
+		t.Errorf("expected no bookings to be marked during the class, got %d", marked)
+	}
+
+	fake.Advance(30 * time.Minute)
+	if marked := MarkNoShows(); marked != 1 {
+		t.Errorf("expected 1 booking to be marked, got %d", marked)
+	}
+	if status := bookings["2024-10-02"][1].Status; status != models.StatusNoShow {
+		t.Errorf("expected Ravi to be a no-show, got %s", status)
+	}
+}
+
+// the sweeper marks the no-shows on every tick of the clock
+func TestRunNoShowSweeper(t *testing.T) {
+	date := setUpAttendanceStorage()
+	fake := useFakeClock(t, date.Add(9*time.Hour+59*time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunNoShowSweeper(ctx, time.Minute)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the sweeper might not have created its ticker yet, so we keep moving the clock until it marks the bookings
+	deadline := time.Now().Add(time.Second)
+	for {
+		fake.Advance(time.Minute)
+		storageMu.RLock()
+		status := bookings["2024-10-02"][1].Status
+		storageMu.RUnlock()
+		if status == models.StatusNoShow {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the sweeper to mark Ravi as a no-show, got %s", status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// the attendance history should count attended, missed and upcoming classes
+func TestGetMemberAttendance(t *testing.T) {
+	date := setUpAttendanceStorage()
+	next := date.AddDate(0, 0, 1)
+	classStorage[next] = []models.Class{{ClassName: "Yoga", StartDate: next, EndDate: next, Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour}}
+	bookings["2024-10-03"] = []models.Booking{{ID: "b3", Name: "Ravi", Date: next, Status: models.StatusBooked}}
+	strikes = make(map[string][]time.Time)
+	useFakeClock(t, date.Add(12*time.Hour))
+
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/members/ravi/attendance", nil), "id", "ravi")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetMemberAttendance).ServeHTTP(rec, req)
+
+	var history AttendanceHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+
+	if history.NoShows != 1 || history.Upcoming != 1 || history.Attended != 0 {
+		t.Errorf("unexpected counts %+v", history)
+	}
+	if len(history.Bookings) != 2 || history.Bookings[0].BookingID != "b2" || history.Bookings[0].Status != models.StatusNoShow {
+		t.Errorf("unexpected bookings %+v", history.Bookings)
+	}
+
+	// reading the history does not change anything, the sweeper marks the no-show
+	if bookings["2024-10-02"][1].Status != models.StatusBooked || len(strikes["ravi"]) != 0 {
+		t.Errorf("expected the booking to be left to the sweeper, got %+v %v", bookings["2024-10-02"][1], strikes["ravi"])
+	}
+}
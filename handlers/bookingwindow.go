@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// codes of the problem details returned for bookings outside of the booking window
+const (
+	CodeClassInPast    = "class_in_past"
+	CodeBookingNotOpen = "booking_not_open"
+	CodeBookingClosed  = "booking_closed"
+)
+
+// BookingWindowProblem is the problem details body for bookings made outside of the booking window,
+// it tells the member when the session starts and when booking opens or closed
+type BookingWindowProblem struct {
+	helpers.ProblemDetails
+	ClassStart time.Time  `json:"class_start"`
+	OpensAt    *time.Time `json:"opens_at,omitempty"`
+	ClosesAt   *time.Time `json:"closes_at,omitempty"`
+}
+
+// bookingWindowOffset converts the optional minutes of a booking window to a duration
+func bookingWindowOffset(minutes *int, fallback time.Duration, field string) (time.Duration, error) {
+	if minutes == nil {
+		return fallback, nil
+	}
+	if *minutes < 0 {
+		return 0, &requestError{status: http.StatusBadRequest, message: field + " cannot be negative"}
+	}
+	return time.Duration(*minutes) * time.Minute, nil
+}
+
+// checkBookingWindow rejects bookings for sessions which already started or whose booking window is not open,
+// the booking is checked at the time it was created
+func checkBookingWindow(class models.Class, booking models.Booking) error {
+	current := booking.CreatedAt
+	start := class.Start(booking.Date)
+
+	if !current.Before(start) {
+		message := "Cannot book a class in the past"
+		return &requestError{
+			status:  http.StatusBadRequest,
+			message: message,
+			reason:  metrics.RejectPast,
+			problem: BookingWindowProblem{
+				ProblemDetails: helpers.NewProblem(http.StatusBadRequest, CodeClassInPast, message),
+				ClassStart:     start.UTC(),
+			},
+		}
+	}
+
+	if opens := class.BookingOpens(booking.Date); current.Before(opens) {
+		opens = opens.UTC()
+		message := fmt.Sprintf("Booking for this class opens at %s", opens.Format(time.RFC3339))
+		return &requestError{
+			status:  http.StatusConflict,
+			message: message,
+			reason:  metrics.RejectNotOpen,
+			problem: BookingWindowProblem{
+				ProblemDetails: helpers.NewProblem(http.StatusConflict, CodeBookingNotOpen, message),
+				ClassStart:     start.UTC(),
+				OpensAt:        &opens,
+			},
+		}
+	}
+
+	if closes := class.BookingCloses(booking.Date); !current.Before(closes) {
+		closes = closes.UTC()
+		message := fmt.Sprintf("Booking for this class closed at %s", closes.Format(time.RFC3339))
+		return &requestError{
+			status:  http.StatusConflict,
+			message: message,
+			reason:  metrics.RejectClosed,
+			problem: BookingWindowProblem{
+				ProblemDetails: helpers.NewProblem(http.StatusConflict, CodeBookingClosed, message),
+				ClassStart:     start.UTC(),
+				ClosesAt:       &closes,
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// a 9:00 yoga class on the 2nd of October which opens for booking a day before and closes an hour before
+func setUpBookingWindowStorage() time.Time {
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+	classStorage = map[time.Time][]models.Class{
+		date: {{
+			ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 10,
+			StartTime: 9 * time.Hour, Duration: time.Hour,
+			BookingOpensBefore: 24 * time.Hour, BookingClosesBefore: time.Hour,
+		}},
+	}
+	bookings = make(map[string][]models.Booking)
+	// strikes from no-shows of other tests should not suspend our members
+	strikes = make(map[string][]time.Time)
+	suspensions = make(map[string]time.Time)
+	return date
+}
+
+func postBooking(t *testing.T, body string) (*httptest.ResponseRecorder, BookingWindowProblem) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreateBooking).ServeHTTP(rec, req)
+
+	var problem BookingWindowProblem
+	if rec.Header().Get("Content-Type") == "application/problem+json" {
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("could not unmarshal problem: %v", err)
+		}
+	}
+	return rec, problem
+}
+
+// booking before the window opens should tell the member when it opens
+func TestPostCreateBooking_NotOpen(t *testing.T) {
+	date := setUpBookingWindowStorage()
+	useFakeClock(t, date.Add(-20*time.Hour))
+
+	rec, problem := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	opens := date.Add(-15 * time.Hour)
+	if problem.Code != CodeBookingNotOpen || problem.OpensAt == nil || !problem.OpensAt.Equal(opens) {
+		t.Errorf("expected booking to open at %v, got %+v", opens, problem)
+	}
+	if !problem.ClassStart.Equal(date.Add(9 * time.Hour)) {
+		t.Errorf("unexpected class start %v", problem.ClassStart)
+	}
+}
+
+// bookings are accepted while the window is open and rejected once it closed
+func TestPostCreateBooking_Window(t *testing.T) {
+	date := setUpBookingWindowStorage()
+
+	fake := useFakeClock(t, date.Add(7*time.Hour))
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	fake.Advance(90 * time.Minute)
+	rec, problem := postBooking(t, `{"name":"Ravi","date":"2024-10-02"}`)
+	if rec.Code != http.StatusConflict || problem.Code != CodeBookingClosed {
+		t.Fatalf("expected booking to be closed, got %d %+v", rec.Code, problem)
+	}
+	if problem.ClosesAt == nil || !problem.ClosesAt.Equal(date.Add(8*time.Hour)) {
+		t.Errorf("unexpected closing time %v", problem.ClosesAt)
+	}
+}
+
+// sessions which already started cannot be booked
+func TestPostCreateBooking_Past(t *testing.T) {
+	date := setUpBookingWindowStorage()
+	useFakeClock(t, date.AddDate(0, 0, 3))
+
+	rec, problem := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+	if rec.Code != http.StatusBadRequest || problem.Code != CodeClassInPast {
+		t.Errorf("expected the class to be in the past, got %d %+v", rec.Code, problem)
+	}
+}
+
+// the window defaults to opening a week and closing an hour before the class, 0 opens the booking straight away
+func TestNewClass_BookingWindow(t *testing.T) {
+	req := CreateClassRequest{ClassName: "Yoga", StartDate: "2024-10-02", EndDate: "2024-10-02", Capacity: 10}
+	class, err := newClass(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if class.BookingOpensBefore != DefaultBookingOpensBefore || class.BookingClosesBefore != DefaultBookingClosesBefore {
+		t.Errorf("expected the default window, got %v %v", class.BookingOpensBefore, class.BookingClosesBefore)
+	}
+
+	opens, closes := 0, 0
+	req.BookingOpensMinutesBefore, req.BookingClosesMinutesBefore = &opens, &closes
+	if class, err = newClass(req); err != nil || class.BookingOpensBefore != 0 || class.BookingClosesBefore != 0 {
+		t.Errorf("expected no restrictions, got %+v %v", class, err)
+	}
+
+	opens, closes = 30, 60
+	if _, err := newClass(req); err == nil || err.Error() != "booking cannot close before it opens" {
+		t.Errorf("expected the window to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/ical"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+const calendarProdID = "-//Studio Classes API//Class Schedule//EN"
+
+// Handler for the iCalendar feed of the whole studio schedule
+func GetClassesCalendar(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	events := make([]ical.Event, 0, len(classStorage))
+	for date, sessions := range classStorage {
+		for _, class := range sessions {
+			events = append(events, classEvent(date, class, fmt.Sprintf("Capacity: %d", class.Capacity)))
+		}
+	}
+	storageMu.RUnlock()
+
+	writeCalendar(w, ical.Calendar{ProdID: calendarProdID, Name: "Studio classes", Events: events})
+}
+
+// Handler for the iCalendar feed of the classes a member has booked.
+// Members are identified by their name for now, the same way PostCreateBooking identifies them.
+func GetMemberBookingsCalendar(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+	if member == "" {
+		http.Error(w, "Missing member id", http.StatusBadRequest)
+		return
+	}
+
+	storageMu.RLock()
+	var events []ical.Event
+	for date, sessions := range classStorage {
+		for _, class := range sessions {
+			// a member has at most one active booking per class but can have cancelled earlier ones
+			var active *models.Booking
+			cancelled := 0
+			for _, booking := range classRoster(bookings[date.Format("2006-01-02")], class) {
+				if !strings.EqualFold(booking.Name, member) {
+					continue
+				}
+				if booking.Active() {
+					active = &booking
+				} else {
+					cancelled++
+				}
+			}
+			if active == nil && cancelled == 0 {
+				continue
+			}
+
+			// every cancellation and booking again is a new version of the same event,
+			// cancelled events stay in the feed so that calendar apps remove them
+			event := classEvent(date, class, "Booked by "+member)
+			event.UID = bookingUID(date, class, member)
+			if active != nil {
+				event.Description = "Booked by " + active.Name
+				event.Sequence = 2 * cancelled
+			} else {
+				event.Status = ical.StatusCancelled
+				event.Sequence = 2*cancelled - 1
+			}
+			events = append(events, event)
+		}
+	}
+	storageMu.RUnlock()
+
+	writeCalendar(w, ical.Calendar{ProdID: calendarProdID, Name: member + "'s classes", Events: events})
+}
+
+// classEvent builds the event of a class session, the UID depends on the date and the class.
+// Classes without a duration are shown as all day events.
+func classEvent(date time.Time, class models.Class, description string) ical.Event {
+	event := ical.Event{
+		UID:         fmt.Sprintf("class-%s@studioclasses", sessionKey(date, class)),
+		Summary:     class.ClassName,
+		Description: description,
+		Start:       class.Start(date),
+		End:         class.End(date),
+		Status:      ical.StatusConfirmed,
+		Stamp:       clk.Now(),
+	}
+	if class.Duration == 0 {
+		event.Start, event.End, event.AllDay = date, date.AddDate(0, 0, 1), true
+	}
+	return event
+}
+
+// bookingUID is stable for a member and session so that a personal feed never duplicates a booking.
+// The member name is hashed as it can have spaces, @ or commas which dont belong in a UID.
+func bookingUID(date time.Time, class models.Class, member string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(member)))
+	return fmt.Sprintf("booking-%s-%s@studioclasses", sessionKey(date, class), hex.EncodeToString(sum[:8]))
+}
+
+// sessionKey identifies the session of the class on date in UIDs
+func sessionKey(date time.Time, class models.Class) string {
+	if class.ID == "" {
+		return date.Format("20060102")
+	}
+	return date.Format("20060102") + "-" + class.ID
+}
+
+// writeCalendar sorts the events by date, then by UID for the ones starting together, so that the feed is stable and writes it
+func writeCalendar(w http.ResponseWriter, cal ical.Calendar) {
+	sort.SliceStable(cal.Events, func(i, j int) bool {
+		if !cal.Events[i].Start.Equal(cal.Events[j].Start) {
+			return cal.Events[i].Start.Before(cal.Events[j].Start)
+		}
+		return cal.Events[i].UID < cal.Events[j].UID
+	})
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := cal.Write(w); err != nil {
+		http.Error(w, "Failed to write calendar", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// setting up two classes where Meher booked only the second one
+func setUpCalendarStorage() {
+	first, _ := time.Parse("2006-01-02", "2024-10-02")
+	second, _ := time.Parse("2006-01-02", "2024-10-03")
+	classStorage = map[time.Time][]models.Class{
+		first:  {{ClassName: "Yoga", StartDate: first, EndDate: second, Capacity: 10}},
+		second: {{ClassName: "Yoga", StartDate: first, EndDate: second, Capacity: 10}},
+	}
+	bookings = map[string][]models.Booking{
+		"2024-10-02": {{Name: "Ravi", Date: first}},
+		"2024-10-03": {{Name: "Meher", Date: second}, {Name: "Ravi", Date: second}},
+	}
+}
+
+// the studio feed should have one event per class ordered by date
+func TestGetClassesCalendar(t *testing.T) {
+	setUpCalendarStorage()
+
+	req := httptest.NewRequest(http.MethodGet, "/classes.ics", nil)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetClassesCalendar).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") {
+		t.Errorf("expected a text/calendar content type, got %s", rec.Header().Get("Content-Type"))
+	}
+
+	body := rec.Body.String()
+	first := strings.Index(body, "UID:class-20241002@studioclasses")
+	second := strings.Index(body, "UID:class-20241003@studioclasses")
+	if first == -1 || second == -1 || first > second {
+		t.Errorf("expected both classes in date order, got %s", body)
+	}
+}
+
+// the personal feed should only have the classes the member booked, matched case insensitively
+func TestGetMemberBookingsCalendar(t *testing.T) {
+	setUpCalendarStorage()
+
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/members/meher/bookings.ics", nil), "id", "meher")
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetMemberBookingsCalendar).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	if strings.Count(body, "BEGIN:VEVENT") != 1 {
+		t.Errorf("expected a single event, got %s", body)
+	}
+	if !strings.Contains(body, "UID:"+bookingUID(time.Date(2024, time.October, 3, 0, 0, 0, 0, time.UTC), models.Class{}, "Meher")) {
+		t.Errorf("expected the booking UID, got %s", body)
+	}
+}
+
+// cancelled bookings stay in the feed as cancelled events, and booking again bumps the sequence of the same event
+func TestGetMemberBookingsCalendar_Cancelled(t *testing.T) {
+	setUpCalendarStorage()
+	bookings["2024-10-02"] = append(bookings["2024-10-02"], models.Booking{Name: "Meher", Status: models.StatusCancelled})
+
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/members/meher/bookings.ics", nil), "id", "meher")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetMemberBookingsCalendar).ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	cancelled := "UID:" + bookingUID(time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC), models.Class{}, "meher")
+	if !strings.Contains(body, cancelled) || !strings.Contains(body[strings.Index(body, cancelled):], "STATUS:CANCELLED\r\nSEQUENCE:1") {
+		t.Errorf("expected the cancelled booking with sequence 1, got %s", body)
+	}
+
+	// booking again after cancelling replaces the cancelled event
+	bookings["2024-10-02"] = append(bookings["2024-10-02"], models.Booking{Name: "Meher", Status: models.StatusBooked})
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetMemberBookingsCalendar).ServeHTTP(rec, req)
+
+	body = rec.Body.String()
+	if strings.Count(body, cancelled) != 1 || !strings.Contains(body[strings.Index(body, cancelled):], "STATUS:CONFIRMED\r\nSEQUENCE:2") {
+		t.Errorf("expected a single confirmed event with sequence 2, got %s", body)
+	}
+}
+
+// UIDs only have safe characters whatever the member name, and different members get different UIDs
+func TestBookingUID(t *testing.T) {
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+	seen := make(map[string]string)
+	for _, member := range []string{"Meher K", "meher@example.com", "Kandukuri, Meher", "Zoë", "Meher,K"} {
+		uid := bookingUID(date, models.Class{ID: "c1"}, member)
+		if strings.Trim(uid[:strings.Index(uid, "@")], "abcdefghijklmnopqrstuvwxyz0123456789-") != "" || strings.Count(uid, "@") != 1 {
+			t.Errorf("%s: unexpected characters in %s", member, uid)
+		}
+		if other, found := seen[uid]; found {
+			t.Errorf("%s and %s got the same UID %s", member, other, uid)
+		}
+		seen[uid] = member
+	}
+	if bookingUID(date, models.Class{}, "Meher") != bookingUID(date, models.Class{}, "MEHER") {
+		t.Errorf("expected the UID to ignore the case of the name")
+	}
+}
+
+// events starting together are ordered by UID so that the feed is the same on every fetch
+func TestGetClassesCalendar_SameStart(t *testing.T) {
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+	classStorage = map[time.Time][]models.Class{
+		date: {
+			{ID: "c2", ClassName: "Spin", StartDate: date, EndDate: date, Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour},
+			{ID: "c1", ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour},
+		},
+	}
+	bookings = make(map[string][]models.Booking)
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetClassesCalendar).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/classes.ics", nil))
+	body := rec.Body.String()
+	first, second := strings.Index(body, "UID:class-20241002-c1@"), strings.Index(body, "UID:class-20241002-c2@")
+	if first == -1 || second == -1 || first > second {
+		t.Errorf("expected the classes ordered by UID, got %s", body)
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// CancellationPolicy decides what happens when a member cancels late or doesnt show up
+type CancellationPolicy struct {
+	// Cutoff is how long before the start of the class a member can cancel without penalty
+	Cutoff time.Duration
+	// LateCancelFeeCents is charged for a late cancellation, 0 for no fee
+	LateCancelFeeCents int
+	// ForfeitCredit makes a late cancellation lose the credit or drop-in payment used for the booking instead of refunding it
+	ForfeitCredit bool
+	// StrikeLimit is the number of strikes (late cancellations and no-shows) within StrikeWindow
+	// after which the member is suspended, 0 disables suspensions
+	StrikeLimit int
+	// StrikeWindow is how long a strike counts for
+	StrikeWindow time.Duration
+	// SuspensionPeriod is how long a suspended member cannot book
+	SuspensionPeriod time.Duration
+}
+
+// DefaultCancellationPolicy returns the policy of the studio
+func DefaultCancellationPolicy() CancellationPolicy {
+	return CancellationPolicy{
+		Cutoff:             12 * time.Hour,
+		LateCancelFeeCents: 500,
+		ForfeitCredit:      true,
+		StrikeLimit:        3,
+		StrikeWindow:       30 * 24 * time.Hour,
+		SuspensionPeriod:   7 * 24 * time.Hour,
+	}
+}
+
+// the policy in use and the strikes, suspensions and fees owed of the members keyed by lower case name, all guarded by storageMu
+var (
+	cancellationPolicy = DefaultCancellationPolicy()
+	strikes            = make(map[string][]time.Time)
+	suspensions        = make(map[string]time.Time)
+	memberFees         = make(map[string][]models.Fee)
+)
+
+// SetCancellationPolicy replaces the policy applied to the cancellations and no-shows from now on
+func SetCancellationPolicy(policy CancellationPolicy) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	cancellationPolicy = policy
+}
+
+// Penalty is what a member was charged for a late cancellation
+type Penalty struct {
+	FeeCents int `json:"fee_cents"`
+	// FeeID is the fee the member now owes, see GET /members/{id}/fees
+	FeeID           string     `json:"fee_id,omitempty"`
+	CreditForfeited bool       `json:"credit_forfeited"`
+	Strikes         int        `json:"strikes"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
+}
+
+// CancellationResponse is the response of the cancellation endpoint
+type CancellationResponse struct {
+	Message    string   `json:"message"`
+	LateCancel bool     `json:"late_cancel"`
+	Penalty    *Penalty `json:"penalty,omitempty"`
+	// CreditsRefunded is the number of credits given back for the booking
+	CreditsRefunded int `json:"credits_refunded,omitempty"`
+	// RefundedCents is the drop-in payment given back for the booking
+	RefundedCents int `json:"refunded_cents,omitempty"`
+}
+
+// Handler for a member cancelling their booking, late cancellations are penalised following the cancellation policy
+func PostCancelBooking(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	current := clk.Now()
+
+	storageMu.Lock()
+	response, booking, refund, err := applyCancellation(id, current)
+	storageMu.Unlock()
+
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	// the payment provider is called without holding the lock
+	if refund {
+		response.RefundedCents = refundBooking(r.Context(), booking)
+	}
+
+	helpers.WriteJSON(w, response, http.StatusOK)
+}
+
+// applyCancellation cancels the booking with the id at the given time, applies the cancellation policy and emits
+// the booking.cancelled event, the cancellation is undone when the event cannot be emitted.
+// It reports whether the drop-in payment of the booking has to be refunded. The caller must hold storageMu.
+func applyCancellation(id string, current time.Time) (CancellationResponse, models.Booking, bool, error) {
+	markNoShows(current)
+
+	datestr, i, found := findBooking(id)
+	if !found {
+		return CancellationResponse{}, models.Booking{}, false, &requestError{status: http.StatusNotFound, message: "Booking not found"}
+	}
+	booking := &bookings[datestr][i]
+	class, _ := bookingClass(*booking)
+
+	switch booking.Status {
+	case models.StatusCancelled, models.StatusReleased, models.StatusExpired:
+		return CancellationResponse{}, *booking, false, &requestError{status: http.StatusConflict, message: "Booking is already cancelled"}
+	case models.StatusHeld:
+		return CancellationResponse{}, *booking, false, &requestError{status: http.StatusConflict, message: "The seat is only held, let go of the hold with DELETE /holds/" + booking.ID}
+	case models.StatusPendingPayment:
+		return CancellationResponse{}, *booking, false, &requestError{status: http.StatusConflict, message: "The payment of the booking is still pending"}
+	case models.StatusCheckedIn, models.StatusNoShow:
+		return CancellationResponse{}, *booking, false, &requestError{status: http.StatusConflict, message: "Booking cannot be cancelled once the class has started"}
+	}
+
+	start := class.Start(booking.Date)
+	if !current.Before(start) {
+		return CancellationResponse{}, *booking, false, &requestError{status: http.StatusConflict, message: "Booking cannot be cancelled once the class has started"}
+	}
+
+	// the no-shows marked above are kept when the cancellation is undone
+	saved := saveMemberState(datestr, booking.Name)
+	booking.Status = models.StatusCancelled
+	booking.CancelledAt = current.UTC()
+
+	response := CancellationResponse{
+		Message: fmt.Sprintf("%s's booking for class on %s has been cancelled", booking.Name, datestr),
+	}
+
+	// cancelling within the cutoff window is a late cancellation
+	if current.After(start.Add(-cancellationPolicy.Cutoff)) {
+		response.LateCancel = true
+		response.Penalty = &Penalty{
+			FeeCents:        cancellationPolicy.LateCancelFeeCents,
+			CreditForfeited: cancellationPolicy.ForfeitCredit,
+		}
+		response.Penalty.Strikes, response.Penalty.SuspendedUntil = addStrike(booking.Name, current)
+		if cancellationPolicy.LateCancelFeeCents > 0 {
+			response.Penalty.FeeID = addFee(*booking, models.FeeLateCancel, cancellationPolicy.LateCancelFeeCents, current)
+		}
+	}
+
+	// the credits and drop-in payment of the booking are refunded unless the policy makes late cancellations forfeit them
+	refund := !response.LateCancel || !cancellationPolicy.ForfeitCredit
+	if refund {
+		response.CreditsRefunded = refundCredits(*booking, current)
+	}
+
+	cancelled := *booking
+	if err := emit(events.BookingCancelled{Meta: events.NewMeta(current), Booking: cancelled, Class: class, LateCancel: response.LateCancel}); err != nil {
+		saved.restore()
+		return CancellationResponse{}, cancelled, false, err
+	}
+	return response, cancelled, refund && cancelled.PaymentStatus == models.PaymentCaptured, nil
+}
+
+// addFee records a fee the member of the booking owes and returns its id, the caller must hold storageMu
+func addFee(booking models.Booking, reason string, cents int, at time.Time) string {
+	key := strings.ToLower(booking.Name)
+	fee := models.Fee{
+		ID:          helpers.NewID(),
+		Member:      booking.Name,
+		BookingID:   booking.ID,
+		Reason:      reason,
+		AmountCents: cents,
+		CreatedAt:   at.UTC(),
+	}
+	memberFees[key] = append(memberFees[key], fee)
+	return fee.ID
+}
+
+// FeeResponse is a fee a member owes
+type FeeResponse struct {
+	ID          string    `json:"id"`
+	BookingID   string    `json:"booking_id"`
+	Reason      string    `json:"reason"`
+	AmountCents int       `json:"amount_cents"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FeesResponse is the response of the fees endpoint
+type FeesResponse struct {
+	Member string `json:"member"`
+	// OwedCents is the total of the fees
+	OwedCents int           `json:"owed_cents"`
+	Fees      []FeeResponse `json:"fees"`
+}
+
+// Handler for the fees a member owes, e.g for late cancellations
+func GetMemberFees(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+	response := FeesResponse{Member: member, Fees: []FeeResponse{}}
+
+	storageMu.RLock()
+	for _, fee := range memberFees[strings.ToLower(member)] {
+		response.OwedCents += fee.AmountCents
+		response.Fees = append(response.Fees, FeeResponse{
+			ID:          fee.ID,
+			BookingID:   fee.BookingID,
+			Reason:      fee.Reason,
+			AmountCents: fee.AmountCents,
+			CreatedAt:   fee.CreatedAt,
+		})
+	}
+	storageMu.RUnlock()
+
+	helpers.WriteJSON(w, response, http.StatusOK)
+}
+
+// addStrike records a strike for the member at the given time and suspends them when they reach the strike limit.
+// It returns the number of strikes the member has in the window and the end of the suspension if they got one.
+// The caller must hold storageMu.
+func addStrike(member string, at time.Time) (int, *time.Time) {
+	key := strings.ToLower(member)
+
+	// forgetting the strikes which are out of the window
+	recent := strikes[key][:0]
+	for _, strike := range strikes[key] {
+		if at.Sub(strike) < cancellationPolicy.StrikeWindow {
+			recent = append(recent, strike)
+		}
+	}
+	recent = append(recent, at)
+	strikes[key] = recent
+
+	if cancellationPolicy.StrikeLimit <= 0 || len(recent) < cancellationPolicy.StrikeLimit {
+		return len(recent), nil
+	}
+
+	// the member starts from a clean slate once suspended
+	until := at.Add(cancellationPolicy.SuspensionPeriod).UTC()
+	suspensions[key] = until
+	delete(strikes, key)
+	return len(recent), &until
+}
+
+// suspendedUntil reports whether the member is suspended at the given time and until when.
+// The caller must hold storageMu.
+func suspendedUntil(member string, at time.Time) (time.Time, bool) {
+	until, ok := suspensions[strings.ToLower(member)]
+	return until, ok && at.Before(until)
+}
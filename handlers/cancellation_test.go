@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// resetting the strikes and suspensions and using a small policy which is easy to reason about
+func setUpCancellationPolicy(t *testing.T) {
+	t.Helper()
+	strikes = make(map[string][]time.Time)
+	suspensions = make(map[string]time.Time)
+	memberFees = make(map[string][]models.Fee)
+	SetCancellationPolicy(CancellationPolicy{
+		Cutoff:             2 * time.Hour,
+		LateCancelFeeCents: 500,
+		ForfeitCredit:      true,
+		StrikeLimit:        2,
+		StrikeWindow:       30 * 24 * time.Hour,
+		SuspensionPeriod:   7 * 24 * time.Hour,
+	})
+	t.Cleanup(func() { SetCancellationPolicy(DefaultCancellationPolicy()) })
+}
+
+func cancelBooking(t *testing.T, id string) (*httptest.ResponseRecorder, CancellationResponse) {
+	t.Helper()
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/bookings/"+id+"/cancel", nil), "id", id)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCancelBooking).ServeHTTP(rec, req)
+
+	var response CancellationResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("could not unmarshal response: %v", err)
+		}
+	}
+	return rec, response
+}
+
+// cancelling before the cutoff gives the seat back without any penalty
+func TestPostCancelBooking_OnTime(t *testing.T) {
+	setUpCancellationPolicy(t)
+	date := setUpAttendanceStorage()
+	useFakeClock(t, date.Add(6*time.Hour))
+
+	rec, response := cancelBooking(t, "b1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if response.LateCancel || response.Penalty != nil {
+		t.Errorf("expected no penalty, got %+v", response)
+	}
+	if status := bookings["2024-10-02"][0].Status; status != models.StatusCancelled {
+		t.Errorf("expected the booking to be cancelled, got %s", status)
+	}
+	if len(memberFees["meher"]) != 0 {
+		t.Errorf("expected no fee, got %+v", memberFees["meher"])
+	}
+
+	// cancelling twice is not possible
+	if rec, _ := cancelBooking(t, "b1"); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when cancelling twice, got %d", rec.Code)
+	}
+}
+
+// late cancellations are penalised and enough strikes suspend the member
+func TestPostCancelBooking_Late(t *testing.T) {
+	setUpCancellationPolicy(t)
+	date := setUpAttendanceStorage()
+	useFakeClock(t, date.Add(8*time.Hour+55*time.Minute))
+
+	// a no-show from last week is the first strike
+	addStrike("Meher", date.AddDate(0, 0, -7))
+
+	rec, response := cancelBooking(t, "b1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !response.LateCancel || response.Penalty == nil {
+		t.Fatalf("expected a late cancel penalty, got %+v", response)
+	}
+
+	penalty := *response.Penalty
+	expectedUntil := date.Add(8*time.Hour+55*time.Minute).AddDate(0, 0, 7)
+	if penalty.FeeCents != 500 || penalty.FeeID == "" || !penalty.CreditForfeited || penalty.Strikes != 2 ||
+		penalty.SuspendedUntil == nil || !penalty.SuspendedUntil.Equal(expectedUntil) {
+		t.Errorf("unexpected penalty %+v", penalty)
+	}
+
+	// the fee is owed by the member
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/members/meher/fees", nil), "id", "meher")
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetMemberFees).ServeHTTP(rec, req)
+	var fees FeesResponse
+	json.Unmarshal(rec.Body.Bytes(), &fees)
+	if fees.OwedCents != 500 || len(fees.Fees) != 1 || fees.Fees[0].ID != penalty.FeeID || fees.Fees[0].BookingID != "b1" || fees.Fees[0].Reason != models.FeeLateCancel {
+		t.Errorf("unexpected fees %s", rec.Body.String())
+	}
+
+	// the suspended member cannot book again
+	booking := models.Booking{Name: "meher", Date: date, CreatedAt: clk.Now()}
+	_, err := addBooking(classStorage, bookings, creditLedger, booking)
+	if err == nil || !strings.HasPrefix(err.Error(), "Your booking privileges are suspended until") {
+		t.Errorf("expected the booking to be rejected, got %v", err)
+	}
+}
+
+// a class which started cannot be cancelled anymore
+func TestPostCancelBooking_Started(t *testing.T) {
+	setUpCancellationPolicy(t)
+	date := setUpAttendanceStorage()
+	useFakeClock(t, date.Add(9*time.Hour+5*time.Minute))
+
+	if rec, _ := cancelBooking(t, "b1"); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+	if rec, _ := cancelBooking(t, "unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// a cancelled booking gives its seat back so that someone else or the same member can book it
+func TestAddBooking_AfterCancellation(t *testing.T) {
+	setUpCancellationPolicy(t)
+	date := setUpAttendanceStorage()
+	classStorage[date] = []models.Class{{ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 2, StartTime: 9 * time.Hour, Duration: time.Hour}}
+	bookings["2024-10-02"][0].Status = models.StatusCancelled
+
+	if _, err := addBooking(classStorage, bookings, creditLedger, models.Booking{Name: "Meher", Date: date}); err != nil {
+		t.Errorf("expected Meher to be able to book again, got %v", err)
+	}
+	if _, err := addBooking(classStorage, bookings, creditLedger, models.Booking{Name: "Anu", Date: date}); err == nil || err.Error() != "Class is full" {
+		t.Errorf("expected the class to be full, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// DefaultCreditValidity is how long the credits of a pack can be used when the purchase does not say
+const DefaultCreditValidity = 180 * 24 * time.Hour
+
+// struct to hold payload for buying a class pack
+type CreditPurchaseRequest struct {
+	Credits int `json:"credits"`
+	// ValidDays is how many days the credits can be used for, defaults to DefaultCreditValidity
+	ValidDays int `json:"valid_days,omitempty" validate:"optional"`
+}
+
+// CreditEntryResponse is an entry of the credits history of a member
+type CreditEntryResponse struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Credits   int        `json:"credits"`
+	PackID    string     `json:"pack_id,omitempty"`
+	BookingID string     `json:"booking_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreditPackResponse is a pack of a member which still has credits
+type CreditPackResponse struct {
+	ID        string    `json:"id"`
+	Remaining int       `json:"remaining"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreditsResponse is the credits balance of a member with the packs it comes from and the history of the ledger
+type CreditsResponse struct {
+	Member  string                `json:"member"`
+	Balance int                   `json:"balance"`
+	Packs   []CreditPackResponse  `json:"packs"`
+	History []CreditEntryResponse `json:"history"`
+}
+
+// creditLedger holds the entries of the members keyed by lower case name in the order they were made, guarded by storageMu
+var creditLedger = make(map[string][]models.CreditEntry)
+
+// Handler for a member buying a class pack
+func PostPurchaseCredits(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+
+	var req CreditPurchaseRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Credits < 0 {
+		http.Error(w, "credits cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if req.ValidDays < 0 {
+		http.Error(w, "valid_days cannot be negative", http.StatusBadRequest)
+		return
+	}
+	validity := DefaultCreditValidity
+	if req.ValidDays > 0 {
+		validity = time.Duration(req.ValidDays) * 24 * time.Hour
+	}
+	current := clk.Now().UTC()
+
+	storageMu.Lock()
+	key := strings.ToLower(member)
+	creditLedger[key] = append(creditLedger[key], models.CreditEntry{
+		ID:        helpers.NewID(),
+		Member:    member,
+		Kind:      models.CreditPurchase,
+		Credits:   req.Credits,
+		ExpiresAt: current.Add(validity),
+		CreatedAt: current,
+	})
+	response := memberCredits(creditLedger, member, current)
+	storageMu.Unlock()
+
+	helpers.WriteJSON(w, response, http.StatusCreated)
+}
+
+// Handler for the credits balance and history of a member
+func GetMemberCredits(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	response := memberCredits(creditLedger, member, clk.Now())
+	storageMu.Unlock()
+
+	helpers.WriteJSON(w, response, http.StatusOK)
+}
+
+// memberCredits expires the packs of the member which ran out at the given time and describes their credits.
+// The caller must hold storageMu when passing our creditLedger.
+func memberCredits(ledger map[string][]models.CreditEntry, member string, at time.Time) CreditsResponse {
+	expireCredits(ledger, member, at)
+
+	response := CreditsResponse{Member: member, Packs: []CreditPackResponse{}, History: []CreditEntryResponse{}}
+	for _, pack := range availablePacks(ledger[strings.ToLower(member)], at) {
+		response.Balance += pack.Remaining
+		response.Packs = append(response.Packs, pack)
+	}
+	for _, entry := range ledger[strings.ToLower(member)] {
+		history := CreditEntryResponse{
+			ID:        entry.ID,
+			Kind:      entry.Kind,
+			Credits:   entry.Credits,
+			PackID:    entry.PackID,
+			BookingID: entry.BookingID,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.Kind == models.CreditPurchase {
+			expiresAt := entry.ExpiresAt
+			history.ExpiresAt = &expiresAt
+		}
+		response.History = append(response.History, history)
+	}
+	return response
+}
+
+// remainingCredits returns the credits left in each pack of the entries keyed by pack id
+func remainingCredits(entries []models.CreditEntry) map[string]int {
+	remaining := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Kind == models.CreditPurchase {
+			remaining[entry.ID] += entry.Credits
+		} else {
+			remaining[entry.PackID] += entry.Credits
+		}
+	}
+	return remaining
+}
+
+// availablePacks returns the packs which did not expire at the given time and still have credits, the first to expire first
+func availablePacks(entries []models.CreditEntry, at time.Time) []CreditPackResponse {
+	remaining := remainingCredits(entries)
+	packs := []CreditPackResponse{}
+	for _, entry := range entries {
+		if entry.Kind == models.CreditPurchase && at.Before(entry.ExpiresAt) && remaining[entry.ID] > 0 {
+			packs = append(packs, CreditPackResponse{ID: entry.ID, Remaining: remaining[entry.ID], ExpiresAt: entry.ExpiresAt})
+		}
+	}
+	sort.SliceStable(packs, func(i, j int) bool { return packs[i].ExpiresAt.Before(packs[j].ExpiresAt) })
+	return packs
+}
+
+// expireCredits records the expiry of the credits left in the packs of the member which expired at the given time.
+// The caller must hold storageMu when passing our creditLedger.
+func expireCredits(ledger map[string][]models.CreditEntry, member string, at time.Time) {
+	key := strings.ToLower(member)
+	remaining := remainingCredits(ledger[key])
+	for _, entry := range ledger[key] {
+		if entry.Kind != models.CreditPurchase || at.Before(entry.ExpiresAt) || remaining[entry.ID] <= 0 {
+			continue
+		}
+		ledger[key] = append(ledger[key], models.CreditEntry{
+			ID:        helpers.NewID(),
+			Member:    entry.Member,
+			Kind:      models.CreditExpiry,
+			Credits:   -remaining[entry.ID],
+			PackID:    entry.ID,
+			CreatedAt: entry.ExpiresAt,
+		})
+	}
+}
+
+// debitCredits takes the credits of the booking of the class from the packs of the member, the first to expire first.
+// Nothing is taken when the member does not have enough credits.
+// The caller must hold storageMu when passing our creditLedger.
+func debitCredits(ledger map[string][]models.CreditEntry, class models.Class, booking models.Booking) error {
+	if class.CreditCost == 0 {
+		return nil
+	}
+	expireCredits(ledger, booking.Name, booking.CreatedAt)
+
+	key := strings.ToLower(booking.Name)
+	packs := availablePacks(ledger[key], booking.CreatedAt)
+	balance := 0
+	for _, pack := range packs {
+		balance += pack.Remaining
+	}
+	if balance < class.CreditCost {
+		return &requestError{
+			status:  http.StatusPaymentRequired,
+			message: fmt.Sprintf("Not enough credits, the class costs %d and you have %d", class.CreditCost, balance),
+			reason:  metrics.RejectNoCredits,
+		}
+	}
+
+	due := class.CreditCost
+	for _, pack := range packs {
+		taken := min(due, pack.Remaining)
+		ledger[key] = append(ledger[key], models.CreditEntry{
+			ID:        helpers.NewID(),
+			Member:    booking.Name,
+			Kind:      models.CreditDebit,
+			Credits:   -taken,
+			PackID:    pack.ID,
+			BookingID: booking.ID,
+			CreatedAt: booking.CreatedAt,
+		})
+		if due -= taken; due == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// refundCredits gives the credits debited for the booking back to the packs they came from and returns how many.
+// Credits going back to a pack which expired in the meantime expire again.
+// The caller must hold storageMu.
+func refundCredits(booking models.Booking, at time.Time) int {
+	key := strings.ToLower(booking.Name)
+	refunded := 0
+	for _, entry := range creditLedger[key] {
+		if entry.Kind != models.CreditDebit || entry.BookingID != booking.ID {
+			continue
+		}
+		creditLedger[key] = append(creditLedger[key], models.CreditEntry{
+			ID:        helpers.NewID(),
+			Member:    booking.Name,
+			Kind:      models.CreditRefund,
+			Credits:   -entry.Credits,
+			PackID:    entry.PackID,
+			BookingID: booking.ID,
+			CreatedAt: at.UTC(),
+		})
+		refunded -= entry.Credits
+	}
+	expireCredits(creditLedger, booking.Name, at)
+	return refunded
+}
+
+// copyLedger returns a copy of creditLedger which can be modified without touching ours.
+// The caller must hold storageMu.
+func copyLedger() map[string][]models.CreditEntry {
+	ledger := make(map[string][]models.CreditEntry, len(creditLedger))
+	for member, entries := range creditLedger {
+		ledger[member] = append([]models.CreditEntry(nil), entries...)
+	}
+	return ledger
+}
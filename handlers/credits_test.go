@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// a yoga class costing two credits on the 2nd of October, booked the evening before
+func setUpCreditStorage(t *testing.T) time.Time {
+	date := setUpRoomStorage()
+	classStorage[date][0].CreditCost = 2
+	creditLedger = make(map[string][]models.CreditEntry)
+	useFakeClock(t, date.Add(-12*time.Hour))
+	return date
+}
+
+func purchaseCredits(t *testing.T, member, body string) CreditsResponse {
+	t.Helper()
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/members/"+member+"/credits", strings.NewReader(body)), "id", member)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostPurchaseCredits).ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response CreditsResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return response
+}
+
+func getCredits(t *testing.T, member string) CreditsResponse {
+	t.Helper()
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/members/"+member+"/credits", nil), "id", member)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetMemberCredits).ServeHTTP(rec, req)
+	var response CreditsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	return response
+}
+
+// a purchase adds a pack which expires after its validity
+func TestPostPurchaseCredits(t *testing.T) {
+	date := setUpCreditStorage(t)
+
+	response := purchaseCredits(t, "Meher", `{"credits":10,"valid_days":30}`)
+	expiresAt := date.Add(-12*time.Hour).AddDate(0, 0, 30)
+	if response.Balance != 10 || len(response.Packs) != 1 || !response.Packs[0].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("unexpected credits %+v", response)
+	}
+	if len(response.History) != 1 || response.History[0].Kind != models.CreditPurchase || response.History[0].Credits != 10 {
+		t.Errorf("unexpected history %+v", response.History)
+	}
+
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/members/Meher/credits", strings.NewReader(`{"credits":-1}`)), "id", "Meher")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostPurchaseCredits).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for negative credits, got %d", rec.Code)
+	}
+}
+
+// booking takes the credits from the pack expiring first, members without enough credits cannot book
+func TestPostCreateBooking_Credits(t *testing.T) {
+	setUpCreditStorage(t)
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected status 402 without credits, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(bookings["2024-10-02"]) != 0 {
+		t.Errorf("expected no booking without credits, got %+v", bookings["2024-10-02"])
+	}
+
+	purchaseCredits(t, "Meher", `{"credits":5,"valid_days":90}`)
+	purchaseCredits(t, "meher", `{"credits":1,"valid_days":10}`)
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	credits := getCredits(t, "MEHER")
+	if credits.Balance != 4 || len(credits.Packs) != 1 || credits.Packs[0].Remaining != 4 {
+		t.Errorf("expected the short pack to be used up first, got %+v", credits)
+	}
+	if debits := credits.History[2:]; len(debits) != 2 || debits[0].Credits != -1 || debits[1].Credits != -1 ||
+		debits[0].BookingID != bookings["2024-10-02"][0].ID {
+		t.Errorf("expected a debit from each pack, got %+v", debits)
+	}
+}
+
+// a timely cancellation refunds the credits, a late one forfeits them
+func TestPostCancelBooking_Credits(t *testing.T) {
+	setUpCancellationPolicy(t)
+	date := setUpCreditStorage(t)
+	purchaseCredits(t, "Meher", `{"credits":4}`)
+	postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+
+	_, response := cancelBooking(t, bookings["2024-10-02"][0].ID)
+	if response.CreditsRefunded != 2 || getCredits(t, "Meher").Balance != 4 {
+		t.Errorf("expected the credits to be refunded, got %+v", response)
+	}
+
+	postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+	useFakeClock(t, date.Add(8*time.Hour))
+	_, response = cancelBooking(t, bookings["2024-10-02"][1].ID)
+	if !response.LateCancel || response.CreditsRefunded != 0 || getCredits(t, "Meher").Balance != 2 {
+		t.Errorf("expected the credits to be forfeited, got %+v", response)
+	}
+}
+
+// the credits left in a pack expire with it
+func TestMemberCredits_Expiry(t *testing.T) {
+	date := setUpCreditStorage(t)
+	purchaseCredits(t, "Meher", `{"credits":3,"valid_days":1}`)
+
+	useFakeClock(t, date.AddDate(0, 0, 1))
+	credits := getCredits(t, "Meher")
+	if credits.Balance != 0 || len(credits.Packs) != 0 {
+		t.Errorf("expected the credits to expire, got %+v", credits)
+	}
+	if last := credits.History[len(credits.History)-1]; last.Kind != models.CreditExpiry || last.Credits != -3 {
+		t.Errorf("expected an expiry entry, got %+v", credits.History)
+	}
+
+	// reading the credits again should not expire them twice
+	if history := getCredits(t, "Meher").History; len(history) != 2 {
+		t.Errorf("expected a single expiry, got %+v", history)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/outbox"
+)
+
+// bus carries the events of the class and booking operations to the features reacting to them.
+// The metrics are counted right away, the notifications and webhooks are queued for their workers
+// and acknowledge the events once they went out.
+var bus = newEventBus()
+
+// newEventBus returns a bus with the subscribers of our events
+func newEventBus() *events.Bus {
+	b := events.NewBus()
+	b.Subscribe("metrics", recordEventMetrics)
+	b.SubscribeAsync("notifications", notifyMembers, events.TypeBookingCreated, events.TypeBookingCancelled, events.TypeClassChanged)
+	b.SubscribeAsync("webhooks", publishWebhook)
+	return b
+}
+
+// emit adds the events to the outbox, which delivers them to the subscribers of the bus. The caller must hold storageMu,
+// so that the events are stored with the change they describe and the events of a class are in the order its changes were made.
+// Either all the events are added or none, the caller must undo its change when emit fails.
+func emit(evts ...events.Event) error {
+	if len(evts) == 0 {
+		return nil
+	}
+	records := make([]outbox.Record, 0, len(evts))
+	for _, event := range evts {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return emitFailed(event, err)
+		}
+		meta := event.Metadata()
+		records = append(records, outbox.Record{ID: meta.ID, Type: event.Type(), Key: event.Key(), Payload: payload, CreatedAt: meta.At})
+	}
+	if err := eventOutbox.Add(records...); err != nil {
+		return emitFailed(evts[0], err)
+	}
+	return nil
+}
+
+// emitFailed logs why the event could not be added to the outbox and returns the error of the request
+func emitFailed(event events.Event, err error) error {
+	log.Printf("events: could not add %s event of class %s to the outbox: %v", event.Type(), event.Key(), err)
+	return &requestError{status: http.StatusInternalServerError, message: "The change could not be saved, please try again"}
+}
+
+// bookingCreated returns the booking.created event of a confirmed booking, the caller must hold storageMu
+func bookingCreated(booking models.Booking) events.Event {
+	class, _ := bookingClass(booking)
+	return events.BookingCreated{Meta: events.NewMeta(clk.Now()), Booking: booking, Class: class}
+}
+
+// memberState is what a booking or its cancellation changes for the member and the day of the class,
+// it is saved before the change so that the change can be undone when its event cannot be emitted
+type memberState struct {
+	datestr, member string
+	roster          []models.Booking
+	ledger          []models.CreditEntry
+	strikes         []time.Time
+	suspension      time.Time
+	suspended       bool
+	fees            []models.Fee
+}
+
+// saveMemberState copies the bookings of the day and what the member has, the caller must hold storageMu
+func saveMemberState(datestr, member string) memberState {
+	key := strings.ToLower(member)
+	suspension, suspended := suspensions[key]
+	return memberState{
+		datestr:    datestr,
+		member:     key,
+		roster:     append([]models.Booking(nil), bookings[datestr]...),
+		ledger:     append([]models.CreditEntry(nil), creditLedger[key]...),
+		strikes:    append([]time.Time(nil), strikes[key]...),
+		suspension: suspension,
+		suspended:  suspended,
+		fees:       append([]models.Fee(nil), memberFees[key]...),
+	}
+}
+
+// restore puts the saved state back, the caller must hold storageMu
+func (s memberState) restore() {
+	restoreEntry(bookings, s.datestr, s.roster)
+	restoreEntry(creditLedger, s.member, s.ledger)
+	restoreEntry(strikes, s.member, s.strikes)
+	restoreEntry(memberFees, s.member, s.fees)
+	if s.suspended {
+		suspensions[s.member] = s.suspension
+	} else {
+		delete(suspensions, s.member)
+	}
+}
+
+// restoreEntry sets the entry of the map back to the saved slice, dropping it when there was none
+func restoreEntry[T any](m map[string][]T, key string, saved []T) {
+	if saved == nil {
+		delete(m, key)
+		return
+	}
+	m[key] = saved
+}
+
+// recordEventMetrics counts the classes and bookings created
+func recordEventMetrics(ctx context.Context, event events.Event) error {
+	switch e := event.(type) {
+	case events.ClassCreated:
+		metrics.ClassesCreated.Add(float64(e.Sessions))
+	case events.BookingCreated:
+		metrics.BookingsCreated.Inc()
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// ExportClass is a row of the schedule export
+type ExportClass struct {
+	ClassName string `json:"class_name"`
+	Date      string `json:"date"`
+	Capacity  int    `json:"capacity"`
+	Booked    int    `json:"booked"`
+}
+
+// ExportAttendee is a member enrolled in a class of the roster export
+type ExportAttendee struct {
+	Name     string    `json:"name"`
+	BookedAt time.Time `json:"booked_at"`
+	// Spot is the numbered spot of the attendee in classes with numbered spots
+	Spot int `json:"spot,omitempty"`
+}
+
+// ExportRoster is the sign-in sheet of a class
+type ExportRoster struct {
+	ClassName string           `json:"class_name"`
+	Date      string           `json:"date"`
+	Capacity  int              `json:"capacity"`
+	Attendees []ExportAttendee `json:"attendees"`
+}
+
+// Handler for exporting the schedule as CSV or JSON depending on the Accept header
+func GetExportClasses(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := exportRange(w, r)
+	if !ok {
+		return
+	}
+
+	stream, ok := newExportStream(w, r, []string{"class_name", "date", "capacity", "booked"})
+	if !ok {
+		return
+	}
+
+	for _, date := range classDates(from, to) {
+		for _, session := range sessionsWithRosters(date) {
+			row := ExportClass{
+				ClassName: session.class.ClassName,
+				Date:      date.Format("2006-01-02"),
+				Capacity:  session.class.Capacity,
+				Booked:    len(session.roster),
+			}
+			stream.write(row, [][]string{{row.ClassName, row.Date, strconv.Itoa(row.Capacity), strconv.Itoa(row.Booked)}})
+		}
+	}
+	stream.close()
+}
+
+// Handler for exporting the rosters of the classes between from and to as CSV or JSON depending on the Accept header.
+// In CSV there is one line per attendee, classes nobody booked get a single line with empty attendee columns.
+// Attendees of classes with numbered spots are sorted by spot.
+func GetExportRosters(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := exportRange(w, r)
+	if !ok {
+		return
+	}
+
+	stream, ok := newExportStream(w, r, []string{"class_name", "date", "capacity", "name", "booked_at", "spot"})
+	if !ok {
+		return
+	}
+
+	for _, date := range classDates(from, to) {
+		for _, session := range sessionsWithRosters(date) {
+			writeRoster(stream, date, session.class, session.roster)
+		}
+	}
+	stream.close()
+}
+
+// writeRoster writes the sign-in sheet of the session of the class on date
+func writeRoster(stream *exportStream, date time.Time, class models.Class, roster []models.Booking) {
+	sheet := ExportRoster{
+		ClassName: class.ClassName,
+		Date:      date.Format("2006-01-02"),
+		Capacity:  class.Capacity,
+		Attendees: make([]ExportAttendee, 0, len(roster)),
+	}
+	if class.Spots > 0 {
+		roster = append([]models.Booking(nil), roster...)
+		sort.SliceStable(roster, func(i, j int) bool { return roster[i].Spot < roster[j].Spot })
+	}
+
+	records := make([][]string, 0, len(roster)+1)
+	for _, booking := range roster {
+		sheet.Attendees = append(sheet.Attendees, ExportAttendee{Name: booking.Name, BookedAt: booking.CreatedAt, Spot: booking.Spot})
+		spot := ""
+		if booking.Spot != 0 {
+			spot = strconv.Itoa(booking.Spot)
+		}
+		records = append(records, []string{sheet.ClassName, sheet.Date, strconv.Itoa(sheet.Capacity),
+			booking.Name, booking.CreatedAt.Format(time.RFC3339), spot})
+	}
+	if len(records) == 0 {
+		records = append(records, []string{sheet.ClassName, sheet.Date, strconv.Itoa(sheet.Capacity), "", "", ""})
+	}
+	stream.write(sheet, records)
+}
+
+// exportRange reads the optional from and to query parameters, a missing bound means no limit on that side
+func exportRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	var from, to time.Time
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := r.URL.Query().Get(param.name)
+		if raw == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			http.Error(w, "Invalid "+param.name+" date format", http.StatusBadRequest)
+			return from, to, false
+		}
+		*param.value = helpers.NormalizeDate(date)
+	}
+
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		http.Error(w, "from date cannot be after to date", http.StatusBadRequest)
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// classDates returns the sorted dates of the classes between from and to, zero bounds are ignored
+func classDates(from, to time.Time) []time.Time {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+
+	dates := make([]time.Time, 0, len(classStorage))
+	for date := range classStorage {
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// sessionRoster is a session of a class with its active bookings
+type sessionRoster struct {
+	class  models.Class
+	roster []models.Booking
+}
+
+// sessionsWithRosters returns copies of the sessions on the date with their bookings, so that we dont hold the lock while writing
+func sessionsWithRosters(date time.Time) []sessionRoster {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+
+	sessions := make([]sessionRoster, 0, len(classStorage[date]))
+	for _, class := range classStorage[date] {
+		sessions = append(sessions, sessionRoster{class: class, roster: activeBookings(classRoster(bookings[date.Format("2006-01-02")], class))})
+	}
+	return sessions
+}
+
+// exportStream writes the rows of an export one class at a time and flushes them to the client,
+// so that exporting a large range never holds the whole export in memory
+type exportStream struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	csv        *csv.Writer
+	rows       int
+}
+
+func newExportStream(w http.ResponseWriter, r *http.Request, csvHeader []string) (*exportStream, bool) {
+	stream := &exportStream{w: w, controller: http.NewResponseController(w)}
+
+	switch helpers.NegotiateContentType(r, "application/json", "text/csv") {
+	case "text/csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		stream.csv = csv.NewWriter(w)
+		stream.csv.Write(csvHeader)
+	case "application/json":
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "[")
+	default:
+		http.Error(w, "Not acceptable, expected text/csv or application/json", http.StatusNotAcceptable)
+		return nil, false
+	}
+	return stream, true
+}
+
+// write writes the value as the next JSON element or the records as CSV lines
+func (s *exportStream) write(value any, records [][]string) {
+	if s.csv != nil {
+		for _, record := range records {
+			for i := range record {
+				record[i] = spreadsheetSafe(record[i])
+			}
+		}
+		s.csv.WriteAll(records)
+	} else {
+		if s.rows > 0 {
+			io.WriteString(s.w, ",")
+		}
+		json.NewEncoder(s.w).Encode(value)
+	}
+	s.rows++
+	s.controller.Flush()
+}
+
+// spreadsheetSafe prefixes the cells a spreadsheet would run as a formula with a quote, e.g a member named
+// =HYPERLINK(...), as the sign-in sheets are opened in spreadsheets
+func spreadsheetSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (s *exportStream) close() {
+	if s.csv != nil {
+		s.csv.Flush()
+	} else {
+		io.WriteString(s.w, "]\n")
+	}
+	s.controller.Flush()
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// three days of yoga where only the first two days have bookings
+func setUpExportStorage() {
+	bookedAt := time.Date(2024, time.September, 30, 18, 0, 0, 0, time.UTC)
+	dates := []time.Time{}
+	classStorage = make(map[time.Time][]models.Class)
+	for day := 1; day <= 3; day++ {
+		date := time.Date(2024, time.October, day, 0, 0, 0, 0, time.UTC)
+		dates = append(dates, date)
+		classStorage[date] = []models.Class{{ClassName: "Yoga", StartDate: dates[0], EndDate: date, Capacity: 10}}
+	}
+	bookings = map[string][]models.Booking{
+		"2024-10-01": {{Name: "Meher", Date: dates[0], CreatedAt: bookedAt}, {Name: "Ravi", Date: dates[0], CreatedAt: bookedAt}},
+		"2024-10-02": {{Name: "Anu", Date: dates[1], CreatedAt: bookedAt}},
+	}
+}
+
+// the roster should be exported as CSV with a line per attendee when the client asks for it
+func TestGetExportRosters_CSV(t *testing.T) {
+	setUpExportStorage()
+
+	req := httptest.NewRequest(http.MethodGet, "/export/rosters?from=2024-10-02&to=2024-10-03", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetExportRosters).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("expected a CSV content type, got %s", rec.Header().Get("Content-Type"))
+	}
+
+	expected := "class_name,date,capacity,name,booked_at,spot\n" +
+		"Yoga,2024-10-02,10,Anu,2024-09-30T18:00:00Z,\n" +
+		"Yoga,2024-10-03,10,,,\n"
+	if rec.Body.String() != expected {
+		t.Errorf("unexpected export, got:\n%s\nexpected:\n%s", rec.Body.String(), expected)
+	}
+}
+
+// names a spreadsheet would run as a formula are quoted in the CSV
+func TestGetExportRosters_CSVFormula(t *testing.T) {
+	setUpExportStorage()
+	bookings["2024-10-02"][0].Name = `=HYPERLINK("https://evil.example.com","Anu")`
+	classStorage[time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)][0].ClassName = "@Yoga"
+
+	req := httptest.NewRequest(http.MethodGet, "/export/rosters?from=2024-10-02&to=2024-10-02", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetExportRosters).ServeHTTP(rec, req)
+
+	expected := "class_name,date,capacity,name,booked_at,spot\n" +
+		`'@Yoga,2024-10-02,10,"'=HYPERLINK(""https://evil.example.com"",""Anu"")",2024-09-30T18:00:00Z,` + "\n"
+	if rec.Body.String() != expected {
+		t.Errorf("unexpected export, got:\n%s\nexpected:\n%s", rec.Body.String(), expected)
+	}
+}
+
+// the roster is exported as JSON by default
+func TestGetExportRosters_JSON(t *testing.T) {
+	setUpExportStorage()
+
+	req := httptest.NewRequest(http.MethodGet, "/export/rosters?to=2024-10-01", nil)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetExportRosters).ServeHTTP(rec, req)
+
+	var rosters []ExportRoster
+	if err := json.Unmarshal(rec.Body.Bytes(), &rosters); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if len(rosters) != 1 || len(rosters[0].Attendees) != 2 || rosters[0].Attendees[1].Name != "Ravi" {
+		t.Errorf("unexpected rosters %+v", rosters)
+	}
+}
+
+// the schedule export includes how many seats were booked
+func TestGetExportClasses_JSON(t *testing.T) {
+	setUpExportStorage()
+
+	req := httptest.NewRequest(http.MethodGet, "/export/classes", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetExportClasses).ServeHTTP(rec, req)
+
+	var classes []ExportClass
+	if err := json.Unmarshal(rec.Body.Bytes(), &classes); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+
+	expected := []ExportClass{
+		{ClassName: "Yoga", Date: "2024-10-01", Capacity: 10, Booked: 2},
+		{ClassName: "Yoga", Date: "2024-10-02", Capacity: 10, Booked: 1},
+		{ClassName: "Yoga", Date: "2024-10-03", Capacity: 10, Booked: 0},
+	}
+	if len(classes) != len(expected) {
+		t.Fatalf("expected %d classes, got %+v", len(expected), classes)
+	}
+	for i := range expected {
+		if classes[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], classes[i])
+		}
+	}
+}
+
+// invalid ranges and formats we cannot produce are rejected
+func TestGetExportRosters_InvalidRequests(t *testing.T) {
+	tests := []struct {
+		target string
+		accept string
+		status int
+	}{
+		{"/export/rosters?from=2024-10-03&to=2024-10-01", "", http.StatusBadRequest},
+		{"/export/rosters?from=yesterday", "", http.StatusBadRequest},
+		{"/export/rosters", "application/xml", http.StatusNotAcceptable},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(GetExportRosters).ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.target, tc.status, rec.Code)
+		}
+	}
+}
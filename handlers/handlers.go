@@ -3,10 +3,15 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"net/mail"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+	"github.com/MeherKandukuri/studioClasses_API/events"
 	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
 	"github.com/MeherKandukuri/studioClasses_API/models"
 )
 
@@ -16,17 +21,94 @@ type CreateClassRequest struct {
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 	Capacity  int    `json:"capacity"`
+	// StartTime is the time of day (HH:MM, UTC) every session starts at, defaults to DefaultStartTime
+	StartTime string `json:"start_time" validate:"optional"`
+	// DurationMinutes is the length of every session, defaults to DefaultDuration
+	DurationMinutes int `json:"duration_minutes" validate:"optional"`
+	// BookingOpensMinutesBefore is how long before a session starts members can book it, 0 means any time.
+	// Defaults to DefaultBookingOpensBefore
+	BookingOpensMinutesBefore *int `json:"booking_opens_minutes_before,omitempty" validate:"optional"`
+	// BookingClosesMinutesBefore is how long before a session starts bookings close, 0 means at the start.
+	// Defaults to DefaultBookingClosesBefore
+	BookingClosesMinutesBefore *int `json:"booking_closes_minutes_before,omitempty" validate:"optional"`
+	// InstructorID assigns an instructor to every session of the class
+	InstructorID string `json:"instructor_id,omitempty" validate:"optional"`
+	// RoomID is the room of the class, the capacity cannot exceed the room capacity
+	RoomID string `json:"room_id,omitempty" validate:"optional"`
+	// Spots numbers the spots (mats, reformers, bikes) of every session, there must be one for every member
+	Spots int `json:"spots,omitempty" validate:"optional"`
+	// CreditCost is the number of credits members pay for a session, the class is free without it
+	CreditCost int `json:"credit_cost,omitempty" validate:"optional"`
+	// DropInPriceCents is what members pay for a session when no plan or credits cover it
+	DropInPriceCents int `json:"drop_in_price_cents,omitempty" validate:"optional"`
 }
 
+// defaults for the optional fields of CreateClassRequest
+const (
+	DefaultStartTime           = 9 * time.Hour
+	DefaultDuration            = time.Hour
+	DefaultBookingOpensBefore  = 7 * 24 * time.Hour
+	DefaultBookingClosesBefore = time.Hour
+)
+
 // struct to hold payload from postrequest for creating Booking
 type BookingRequest struct {
 	Name string `json:"name"`
 	Date string `json:"date"`
+	// ClassID picks the class when there are several on the date
+	ClassID string `json:"class_id,omitempty" validate:"optional"`
+	// Spot is the preferred spot in classes with numbered spots, the first free one is assigned without it
+	Spot int `json:"spot,omitempty" validate:"optional"`
+	// PaymentToken is the payment method of drop-ins at the payment provider
+	PaymentToken string `json:"payment_token,omitempty" validate:"optional"`
+	// Email is where the booking confirmation and the other notifications of the booking are sent
+	Email string `json:"email,omitempty" validate:"optional"`
+}
+
+// initializing, classStorage holds the sessions of each day sorted by start time
+var bookings = make(map[string][]models.Booking)
+var classStorage = make(map[time.Time][]models.Class)
+
+// storageMu guards bookings and classStorage as they are read outside of the handlers too (e.g. by /metrics)
+var storageMu sync.RWMutex
+
+// clk tells the handlers the time, tests replace it with a fake clock through SetClock
+var clk clock.Clock = clock.Real{}
+
+// SetClock sets the clock used by the handlers and the no-show sweeper
+func SetClock(c clock.Clock) {
+	clk = c
 }
 
-// initializing
-var bookings = make(map[string][]string)
-var classStorage = make(map[time.Time]models.Class)
+// requestError describes why a request was rejected,
+// it lets the handlers and the importer share the validation while reporting the errors their own way
+type requestError struct {
+	status  int
+	message string
+	// reason labels the rejected bookings metric, it is empty for errors we dont count
+	reason string
+	// problem is written as a problem details response instead of plain text when set,
+	// for errors the clients need to tell apart by code
+	problem any
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// writeRequestError writes the error as a plain text response like http.Error,
+// or as problem details for the errors which carry them
+func writeRequestError(w http.ResponseWriter, err error) {
+	if reqErr, ok := err.(*requestError); ok {
+		if reqErr.problem != nil {
+			helpers.WriteProblemBody(w, reqErr.status, reqErr.problem)
+			return
+		}
+		http.Error(w, reqErr.message, reqErr.status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
 
 // Handler for postrequest for creating classes
 func PostCreateClass(w http.ResponseWriter, r *http.Request) {
@@ -42,25 +124,53 @@ func PostCreateClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	class, err := newClass(req)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	created, err := addClass(classStorage, class)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := emit(events.ClassCreated{Meta: events.NewMeta(clk.Now()), Class: class, Sessions: created}); err != nil {
+		removeClass(classStorage, class.ID)
+		writeRequestError(w, err)
+		return
+	}
+
+	// success message of creating a class
+	message := fmt.Sprintf("created %s classes between %s and %s with Capacity: %d",
+		class.ClassName, class.StartDate.Format("2006-01-02"), class.EndDate.Format("2006-01-02"), class.Capacity)
+
+	helpers.WriteJSONResponse(w, message, http.StatusCreated)
+
+}
+
+// newClass validates the request and builds the class it describes
+func newClass(req CreateClassRequest) (models.Class, error) {
 	// This function helps in validating the request.
 	// we can check if the user did not enter any required field by comparing it to zero value of that particular field.
 	// currently we validate only for zeros by adding "CheckZeroValue" to our checkstoBeDone slice
 	checksToBeDone := []string{"checkZeroValue"}
-	if !helpers.ValidateRequiredFields(w, req, checksToBeDone) {
-		return
+	if err := helpers.CheckRequiredFields(req, checksToBeDone); err != nil {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: err.Error()}
 	}
 
 	//parsing dates
 	startDate, err := time.Parse("2006-01-02", req.StartDate)
 	if err != nil {
-		http.Error(w, "Invalid start date format", http.StatusBadRequest)
-		return
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "Invalid start date format"}
 	}
 
 	endDate, err := time.Parse("2006-01-02", req.EndDate)
 	if err != nil {
-		http.Error(w, "Invalid endDate format", http.StatusBadRequest)
-		return
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "Invalid endDate format"}
 	}
 
 	// normalizing dates to a standard format
@@ -68,40 +178,106 @@ func PostCreateClass(w http.ResponseWriter, r *http.Request) {
 
 	// check if the dates entered are valid
 	if startDate.After(endDate) {
-		http.Error(w, "start date cannot be after end date", http.StatusBadRequest)
-		return
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "start date cannot be after end date"}
 	}
 
-	class := models.Class{
-		ClassName: req.ClassName,
-		StartDate: startDate,
-		EndDate:   endDate,
-		Capacity:  req.Capacity,
+	// the time of day is optional and stored as an offset from midnight
+	startTime := DefaultStartTime
+	if req.StartTime != "" {
+		parsed, err := time.Parse("15:04", req.StartTime)
+		if err != nil {
+			return models.Class{}, &requestError{status: http.StatusBadRequest, message: "Invalid start time format"}
+		}
+		startTime = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
 	}
 
-	// If there is a class on that we cannot create one as we have only one class per day
-	currentDate := startDate
-	for !currentDate.After(endDate) {
-		if _, exists := classStorage[currentDate]; exists {
-			http.Error(w, fmt.Sprintf("Class already exists on %v", currentDate.Format("2006-01-02")), http.StatusConflict)
-			return
+	duration := DefaultDuration
+	if req.DurationMinutes < 0 {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "duration cannot be negative"}
+	}
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+
+	opensBefore, err := bookingWindowOffset(req.BookingOpensMinutesBefore, DefaultBookingOpensBefore, "booking_opens_minutes_before")
+	if err != nil {
+		return models.Class{}, err
+	}
+	closesBefore, err := bookingWindowOffset(req.BookingClosesMinutesBefore, DefaultBookingClosesBefore, "booking_closes_minutes_before")
+	if err != nil {
+		return models.Class{}, err
+	}
+	if opensBefore != 0 && opensBefore <= closesBefore {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "booking cannot close before it opens"}
+	}
+
+	// every member booked into a class with numbered spots needs a spot
+	if req.Spots < 0 {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "spots cannot be negative"}
+	}
+	if req.Spots > 0 && req.Spots < req.Capacity {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "spots cannot be fewer than the capacity"}
+	}
+	if req.CreditCost < 0 {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "credit_cost cannot be negative"}
+	}
+	if req.DropInPriceCents < 0 {
+		return models.Class{}, &requestError{status: http.StatusBadRequest, message: "drop_in_price_cents cannot be negative"}
+	}
+
+	return models.Class{
+		ID:                  helpers.NewID(),
+		ClassName:           req.ClassName,
+		StartDate:           startDate,
+		EndDate:             endDate,
+		Capacity:            req.Capacity,
+		StartTime:           startTime,
+		Duration:            duration,
+		BookingOpensBefore:  opensBefore,
+		BookingClosesBefore: closesBefore,
+		InstructorID:        req.InstructorID,
+		RoomID:              req.RoomID,
+		Spots:               req.Spots,
+		CreditCost:          req.CreditCost,
+		DropInPriceCents:    req.DropInPriceCents,
+	}, nil
+}
+
+// addClass stores the class in classes for every day between its start and end date and returns the number of days added.
+// The caller must hold storageMu, the instructor and room of the class are looked up in our instructors and rooms.
+func addClass(classes map[time.Time][]models.Class, class models.Class) (int, error) {
+	if class.InstructorID != "" {
+		if _, found := instructors[class.InstructorID]; !found {
+			return 0, &requestError{status: http.StatusBadRequest, message: "Instructor not found"}
+		}
+	}
+	if err := checkRoomCapacity(class); err != nil {
+		return 0, err
+	}
+
+	currentDate := class.StartDate
+	for !currentDate.After(class.EndDate) {
+		// a room can only hold one class at a time
+		if err := checkRoomAvailable(classes, class, currentDate); err != nil {
+			return 0, err
+		}
+		// the instructor must be free for every session
+		if err := checkInstructorAvailable(classes, class, currentDate); err != nil {
+			return 0, err
 		}
 		currentDate = currentDate.AddDate(0, 0, 1)
 	}
 
 	// Reset currentDate to startDate
-	currentDate = startDate
-	for !currentDate.After(endDate) {
+	created := 0
+	currentDate = class.StartDate
+	for !currentDate.After(class.EndDate) {
 		// Add class to storage for each date
-		classStorage[currentDate] = class
+		addSession(classes, currentDate, class)
 		currentDate = currentDate.AddDate(0, 0, 1)
+		created++
 	}
-	// success message of creating a class
-	message := fmt.Sprintf("created %s classes between %s and %s with Capacity: %d",
-		class.ClassName, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), class.Capacity)
-
-	helpers.WriteJSONResponse(w, message, http.StatusCreated)
-
+	return created, nil
 }
 
 // Handler for Booking a class
@@ -119,54 +295,183 @@ func PostCreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	booking, err := newBooking(reqBooking)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	storageMu.Lock()
+	saved := saveMemberState(booking.Date.Format("2006-01-02"), booking.Name)
+	booking, err = addBooking(classStorage, bookings, creditLedger, booking)
+	if err == nil && booking.PaymentStatus == models.PaymentPending && reqBooking.PaymentToken == "" {
+		// we dont hold a seat for a drop-in who cannot pay
+		removeBooking(booking.ID)
+		err = paymentRequired(booking)
+	}
+	// drop-ins are only enrolled once their payment is captured
+	if err == nil && booking.PaymentStatus != models.PaymentPending {
+		if err = emit(bookingCreated(booking)); err != nil {
+			saved.restore()
+		}
+	}
+	storageMu.Unlock()
+
+	// the seat is held while the drop-in pays, the payment provider is called without holding the lock
+	if err == nil && booking.PaymentStatus == models.PaymentPending {
+		booking, err = payBooking(r.Context(), booking, reqBooking.PaymentToken, models.StatusReleased)
+	}
+	if err != nil {
+		if reqErr, ok := err.(*requestError); ok && reqErr.reason != "" {
+			metrics.BookingsRejected.Inc(reqErr.reason)
+		}
+		writeRequestError(w, err)
+		return
+	}
+
+	//writing to our response with a confirmation message, the location of the booking is needed to check in
+	w.Header().Set("Location", "/v1/bookings/"+booking.ID)
+	helpers.WriteJSONResponse(w, enrolledMessage(booking), http.StatusCreated)
+
+}
+
+// newBooking validates the request and builds the booking it describes
+func newBooking(reqBooking BookingRequest) (models.Booking, error) {
 	// This function helps in validating the request.
 	// we can check if the user did not enter any required field by comparing it to zero value of that particular field.
 	// currently we validate only for zeroValues by adding "CheckZeroValue" to our checkstoBeDone slice
 	checksToBeDone := []string{"checkZeroValue"}
-	if !helpers.ValidateRequiredFields(w, reqBooking, checksToBeDone) {
-		return
+	if err := helpers.CheckRequiredFields(reqBooking, checksToBeDone); err != nil {
+		return models.Booking{}, &requestError{status: http.StatusBadRequest, message: err.Error()}
 	}
 
 	// parsing date
 	date, err := time.Parse("2006-01-02", reqBooking.Date)
 	if err != nil {
-		http.Error(w, "invalid date format", http.StatusBadRequest)
-		return
+		return models.Booking{}, &requestError{status: http.StatusBadRequest, message: "invalid date format"}
 	}
 
-	// standardising the date for ease of comparision
-	date = helpers.NormalizeDate(date)
-	datestr := date.Format("2006-01-02")
+	var email string
+	if reqBooking.Email != "" {
+		address, err := mail.ParseAddress(reqBooking.Email)
+		if err != nil {
+			return models.Booking{}, &requestError{status: http.StatusBadRequest, message: "invalid email address"}
+		}
+		email = address.Address
+	}
 
-	// make sure we have a class on that date
-	if _, found := classStorage[date]; !found {
-		http.Error(w, "We don't have a class on this day", http.StatusBadRequest)
-		return
+	// creating a struct for storing to our in memory storage,
+	// the date is standardised for ease of comparision
+	return models.Booking{
+		ID:        helpers.NewID(),
+		Name:      reqBooking.Name,
+		Email:     email,
+		ClassID:   reqBooking.ClassID,
+		Spot:      reqBooking.Spot,
+		Date:      helpers.NormalizeDate(date),
+		Status:    models.StatusBooked,
+		CreatedAt: clk.Now().UTC(),
+	}, nil
+}
+
+// enrolledMessage confirms the booking to the member, with their spot in classes with numbered spots
+func enrolledMessage(booking models.Booking) string {
+	message := fmt.Sprintf("%s has been enrolled for class on %s", booking.Name, booking.Date.Format("2006-01-02"))
+	if booking.Spot != 0 {
+		message += fmt.Sprintf(" on spot %d", booking.Spot)
+	}
+	return message
+}
+
+// addBooking enrolls the member into the class on the booking date, paying with the credits of the member in ledger,
+// and returns the booking as it was stored.
+// The caller must hold storageMu when passing our classStorage, bookings and creditLedger.
+func addBooking(classes map[time.Time][]models.Class, roster map[string][]models.Booking, ledger map[string][]models.CreditEntry, booking models.Booking) (models.Booking, error) {
+	booking, class, err := reserveSeat(classes, roster, booking)
+	if err != nil {
+		return booking, err
 	}
 
-	// creating a struct for writing json response and storing to our in memory storage
-	booking := models.Booking{
-		Name: reqBooking.Name,
-		Date: date,
+	// the booking is covered by the plan of the member or paid with credits,
+	// this is done last so that nothing is used up for a booking we turn down
+	if booking, err = entitleBooking(roster, ledger, class, booking); err != nil {
+		return booking, err
+	}
+
+	// appending to our bookings cache
+	datestr := booking.Date.Format("2006-01-02")
+	roster[datestr] = append(roster[datestr], booking)
+	return booking, nil
+}
+
+// checkSuspension rejects bookings of members who are suspended at the time the booking was created.
+// The caller must hold storageMu.
+func checkSuspension(booking models.Booking) error {
+	if until, suspended := suspendedUntil(booking.Name, booking.CreatedAt); suspended {
+		return &requestError{
+			status:  http.StatusForbidden,
+			message: fmt.Sprintf("Your booking privileges are suspended until %s", until.Format(time.RFC3339)),
+			reason:  metrics.RejectSuspended,
+		}
+	}
+	return nil
+}
+
+// reserveSeat checks that the member can take a seat in the class on the booking date and assigns their spot,
+// it returns the booking with its class without storing it.
+// The caller must hold storageMu when passing our classStorage and bookings.
+func reserveSeat(classes map[time.Time][]models.Class, roster map[string][]models.Booking, booking models.Booking) (models.Booking, models.Class, error) {
+	datestr := booking.Date.Format("2006-01-02")
+
+	// make sure we have a class on that date, the class id picks one when there are several
+	class, _, err := findClass(classes, booking.Date, booking.ClassID)
+	if err != nil {
+		return booking, class, err
+	}
+	booking.ClassID = class.ID
+
+	// sessions can only be booked while their booking window is open
+	if err := checkBookingWindow(class, booking); err != nil {
+		return booking, class, err
+	}
+
+	// stale holds give their seat back even if the sweeper did not run yet
+	expireHolds(roster[datestr], booking.CreatedAt)
+
+	// members who collected too many strikes cannot book until their suspension ends
+	if err := checkSuspension(booking); err != nil {
+		return booking, class, err
 	}
 
 	// This check is done assuming there is only one name for one person.
 	// later on We can achieve this functionality using unique user ID to make sure that all the bookings arent done by one person
-	namesInClass := bookings[datestr]
+	// cancelled bookings dont hold a seat, so the member can book again
+	bookingsInClass := activeBookings(classRoster(roster[datestr], class))
 	username := strings.ToLower(booking.Name)
 
-	for _, name := range namesInClass {
-		if strings.ToLower(name) == username {
-			http.Error(w, "You have already enrolled into class", http.StatusConflict)
-			return
+	for _, existing := range bookingsInClass {
+		if strings.ToLower(existing.Name) == username {
+			return booking, class, &requestError{status: http.StatusConflict, message: "You have already enrolled into class", reason: metrics.RejectDuplicate}
 		}
 	}
 
-	// appending to our bookings cache
-	bookings[datestr] = append(bookings[datestr], booking.Name)
+	// we cannot enroll more people than the capacity of the class
+	if len(bookingsInClass) >= class.Capacity {
+		return booking, class, &requestError{status: http.StatusConflict, message: "Class is full", reason: metrics.RejectFull}
+	}
 
-	//writing to our response with a confirmation message
-	message := fmt.Sprintf("%s has been enrolled for class on %s", booking.Name, datestr)
-	helpers.WriteJSONResponse(w, message, http.StatusCreated)
+	// members of classes with numbered spots get the spot they asked for or the first free one
+	booking.Spot, err = assignSpot(class, bookingsInClass, booking.Spot)
+	return booking, class, err
+}
 
+// activeBookings returns the bookings which still hold a seat
+func activeBookings(booked []models.Booking) []models.Booking {
+	active := make([]models.Booking, 0, len(booked))
+	for _, booking := range booked {
+		if booking.Active() {
+			active = append(active, booking)
+		}
+	}
+	return active
 }
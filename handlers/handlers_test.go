@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
 	"github.com/MeherKandukuri/studioClasses_API/models"
 )
 
@@ -125,15 +126,17 @@ func TestPostCreateBooking_WrongDates(t *testing.T) {
 func TestPostCreateBooking_SuccessfulReq(t *testing.T) {
 	
 	// Set up class for the test date and add it to cache
-	classStorage = make(map[time.Time]models.Class)
+	classStorage = make(map[time.Time][]models.Class)
 	dateStr := "2024-10-02"
 	date, _ := time.Parse("2006-01-02", dateStr)
-	classStorage[date] = models.Class{
+	classStorage[date] = []models.Class{{
 		ClassName: "Yoga",
 		StartDate: date,
 		EndDate:   date,
 		Capacity:  20,
-	}
+	}}
+	// book the day before so that the class is not in the past
+	useFakeClock(t, date.AddDate(0, 0, -1))
 
 	// set up a request Body
 	requestBody := `{"name":"Meher",
@@ -199,15 +202,15 @@ func TestPostCreateBooking_InvalidDate(t *testing.T) {
 func TestPostCreateBooking_NoClass(t *testing.T) {
 	// Set up class for the test date and making sure that the dates are different as we 
 	//dont want to have class on the booking day
-	classStorage = make(map[time.Time]models.Class)
+	classStorage = make(map[time.Time][]models.Class)
 	dateStr := "2024-11-02"
 	date, _ := time.Parse("2006-01-02", dateStr)
-	classStorage[date] = models.Class{
+	classStorage[date] = []models.Class{{
 		ClassName: "Yoga",
 		StartDate: date,
 		EndDate:   date,
 		Capacity:  20,
-	}
+	}}
 
 	requestBody := `{"name":"Meher",
 				"date":"2024-10-02"}`
@@ -236,18 +239,19 @@ func TestPostCreateBooking_NoClass(t *testing.T) {
 func TestPostCreateBooking_BookingExist(t *testing.T) {
 	
 	// Set up class for the test date
-	classStorage = make(map[time.Time]models.Class)
+	classStorage = make(map[time.Time][]models.Class)
 	dateStr := "2024-11-02"
 	date, _ := time.Parse("2006-01-02", dateStr)
-	classStorage[date] = models.Class{
+	classStorage[date] = []models.Class{{
 		ClassName: "Yoga",
 		StartDate: date,
 		EndDate:   date,
 		Capacity:  20,
-	}
+	}}
 	// create a bookings entry to test
-	bookings = make(map[string][]string)
-	bookings["2024-11-02"] = []string{"Meher"}
+	bookings = make(map[string][]models.Booking)
+	bookings["2024-11-02"] = []models.Booking{{Name: "Meher", Date: date}}
+	useFakeClock(t, date.AddDate(0, 0, -1))
 	
 	//Creating a request body
 	requestBody := `{"name":"Meher",
@@ -273,3 +277,72 @@ func TestPostCreateBooking_BookingExist(t *testing.T) {
 	}
 
 }
+
+// Testing that we dont enroll more people than the capacity of the class
+func TestPostCreateBooking_ClassFull(t *testing.T) {
+
+	// Set up a class with a single seat which is already taken
+	classStorage = make(map[time.Time][]models.Class)
+	date, _ := time.Parse("2006-01-02", "2024-11-03")
+	classStorage[date] = []models.Class{{
+		ClassName: "Yoga",
+		StartDate: date,
+		EndDate:   date,
+		Capacity:  1,
+	}}
+	bookings = make(map[string][]models.Booking)
+	bookings["2024-11-03"] = []models.Booking{{Name: "Meher", Date: date}}
+	useFakeClock(t, date.AddDate(0, 0, -1))
+
+	rejectedBefore := metrics.BookingsRejected.Value(metrics.RejectFull)
+
+	requestBody := `{"name":"Ravi",
+	"date":"2024-11-03"}`
+	req := httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(PostCreateBooking)
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+
+	expectedResponse := `Class is full`
+	actualResponse := strings.TrimSpace(rec.Body.String())
+	if expectedResponse != actualResponse {
+		t.Errorf("expected message '%v', got '%v'", expectedResponse, actualResponse)
+	}
+
+	// the rejection should be counted under the full reason
+	if got := metrics.BookingsRejected.Value(metrics.RejectFull) - rejectedBefore; got != 1 {
+		t.Errorf("expected 1 rejected booking with reason full, got %v", got)
+	}
+}
+
+// the optional start time and duration of a class should be validated and defaulted
+func TestNewClass_TimeOfDay(t *testing.T) {
+	class, err := newClass(CreateClassRequest{ClassName: "Yoga", StartDate: "2024-10-01", EndDate: "2024-10-01", Capacity: 10})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if class.StartTime != DefaultStartTime || class.Duration != DefaultDuration {
+		t.Errorf("expected the default time of day, got %v for %v", class.StartTime, class.Duration)
+	}
+
+	class, err = newClass(CreateClassRequest{ClassName: "Yoga", StartDate: "2024-10-01", EndDate: "2024-10-01", Capacity: 10,
+		StartTime: "18:30", DurationMinutes: 45})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if class.StartTime != 18*time.Hour+30*time.Minute || class.Duration != 45*time.Minute {
+		t.Errorf("expected 18:30 for 45 minutes, got %v for %v", class.StartTime, class.Duration)
+	}
+
+	_, err = newClass(CreateClassRequest{ClassName: "Yoga", StartDate: "2024-10-01", EndDate: "2024-10-01", Capacity: 10, StartTime: "6pm"})
+	if err == nil || err.Error() != "Invalid start time format" {
+		t.Errorf("expected an invalid start time error, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// how long a seat is held while the member checks out
+const (
+	DefaultHoldDuration = 10 * time.Minute
+	MaxHoldDuration     = 30 * time.Minute
+)
+
+// HoldRequest reserves a seat in a class for a few minutes, the fields are the same as for a booking
+type HoldRequest struct {
+	Name    string `json:"name"`
+	Date    string `json:"date"`
+	ClassID string `json:"class_id,omitempty" validate:"optional"`
+	Spot    int    `json:"spot,omitempty" validate:"optional"`
+	// Email is where the notifications of the booking are sent once the hold is converted
+	Email string `json:"email,omitempty" validate:"optional"`
+	// Minutes is how long the seat is held, 10 minutes when it is not given and at most 30
+	Minutes int `json:"minutes,omitempty" validate:"optional"`
+}
+
+// ConvertHoldRequest turns a hold into a booking, drop-ins pay with the payment token
+type ConvertHoldRequest struct {
+	PaymentToken string `json:"payment_token,omitempty" validate:"optional"`
+}
+
+// HoldResponse is a seat held for a member
+type HoldResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ClassID   string    `json:"class_id"`
+	Date      string    `json:"date"`
+	Spot      int       `json:"spot,omitempty"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newHoldResponse(booking models.Booking) HoldResponse {
+	return HoldResponse{
+		ID:        booking.ID,
+		Name:      booking.Name,
+		ClassID:   booking.ClassID,
+		Date:      booking.Date.Format("2006-01-02"),
+		Spot:      booking.Spot,
+		Status:    booking.Status,
+		ExpiresAt: booking.HoldExpiresAt,
+	}
+}
+
+// Handler for holding a seat while the member checks out, the hold counts against the capacity of the class
+func PostCreateHold(w http.ResponseWriter, r *http.Request) {
+	var reqHold HoldRequest
+	if !helpers.DecodeJSONPayload(w, r, &reqHold) {
+		return
+	}
+
+	duration := DefaultHoldDuration
+	if reqHold.Minutes != 0 {
+		duration = time.Duration(reqHold.Minutes) * time.Minute
+	}
+	if duration <= 0 || duration > MaxHoldDuration {
+		http.Error(w, fmt.Sprintf("minutes must be between 1 and %d", int(MaxHoldDuration/time.Minute)), http.StatusBadRequest)
+		return
+	}
+
+	booking, err := newBooking(BookingRequest{Name: reqHold.Name, Email: reqHold.Email, Date: reqHold.Date, ClassID: reqHold.ClassID, Spot: reqHold.Spot})
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	storageMu.Lock()
+	booking, _, err = reserveSeat(classStorage, bookings, booking)
+	if err == nil {
+		booking.Status = models.StatusHeld
+		booking.HoldExpiresAt = booking.CreatedAt.Add(duration)
+		datestr := booking.Date.Format("2006-01-02")
+		bookings[datestr] = append(bookings[datestr], booking)
+	}
+	storageMu.Unlock()
+
+	if err != nil {
+		if reqErr, ok := err.(*requestError); ok && reqErr.reason != "" {
+			metrics.BookingsRejected.Inc(reqErr.reason)
+		}
+		writeRequestError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/holds/"+booking.ID)
+	helpers.WriteJSON(w, newHoldResponse(booking), http.StatusCreated)
+}
+
+// Handler for fetching a hold, converted holds are fetched as bookings
+func GetHold(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	booking, err := findHold(id, clk.Now())
+	storageMu.Unlock()
+
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	helpers.WriteJSON(w, newHoldResponse(*booking), http.StatusOK)
+}
+
+// Handler for converting a hold into a booking, the booking keeps the id, class and spot of the hold
+func PostConvertHold(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	// the body is only needed by drop-ins paying for the class
+	var reqConvert ConvertHoldRequest
+	if r.ContentLength != 0 && !helpers.DecodeJSONPayload(w, r, &reqConvert) {
+		return
+	}
+
+	storageMu.Lock()
+	var saved memberState
+	if datestr, i, found := findBooking(id); found {
+		saved = saveMemberState(datestr, bookings[datestr][i].Name)
+	}
+	booking, err := convertHold(id, reqConvert.PaymentToken, clk.Now().UTC())
+	if err == nil && booking.PaymentStatus != models.PaymentPending {
+		if err = emit(bookingCreated(booking)); err != nil {
+			saved.restore()
+		}
+	}
+	storageMu.Unlock()
+
+	// the seat stays held while the drop-in pays, a failed payment can be retried until the hold expires
+	if err == nil && booking.PaymentStatus == models.PaymentPending {
+		booking, err = payBooking(r.Context(), booking, reqConvert.PaymentToken, models.StatusHeld)
+	}
+	if err != nil {
+		if reqErr, ok := err.(*requestError); ok && reqErr.reason != "" {
+			metrics.BookingsRejected.Inc(reqErr.reason)
+		}
+		writeRequestError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/bookings/"+booking.ID)
+	helpers.WriteJSONResponse(w, enrolledMessage(booking), http.StatusCreated)
+}
+
+// Handler for letting go of a hold before it expires, the seat is given back to the class
+func DeleteHold(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	booking, err := findHold(id, clk.Now())
+	if err == nil && booking.Status != models.StatusHeld {
+		err = holdNotHeld(*booking)
+	}
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	booking.Status = models.StatusReleased
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findHold returns the stored booking made from the hold, expiring it first if its time is up.
+// The caller must hold storageMu.
+func findHold(id string, current time.Time) (*models.Booking, error) {
+	datestr, i, found := findBooking(id)
+	if !found || bookings[datestr][i].HoldExpiresAt.IsZero() {
+		return nil, &requestError{status: http.StatusNotFound, message: "Hold not found"}
+	}
+	expireHolds(bookings[datestr][i:i+1], current)
+	return &bookings[datestr][i], nil
+}
+
+// holdNotHeld explains why a hold cannot be converted or let go anymore
+func holdNotHeld(booking models.Booking) error {
+	switch booking.Status {
+	case models.StatusExpired:
+		return &requestError{status: http.StatusConflict, message: "The hold expired, the seat was given back to the class"}
+	case models.StatusReleased:
+		return &requestError{status: http.StatusConflict, message: "The hold was let go"}
+	case models.StatusPendingPayment:
+		return &requestError{status: http.StatusConflict, message: "The hold is being paid for"}
+	}
+	return &requestError{status: http.StatusConflict, message: "The hold was already converted into a booking"}
+}
+
+// convertHold turns the hold into a booking covered by the plan of the member, paid with credits,
+// or pending the payment of the drop-in when a payment token is given.
+// The caller must hold storageMu.
+func convertHold(id, token string, current time.Time) (models.Booking, error) {
+	stored, err := findHold(id, current)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if stored.Status != models.StatusHeld {
+		return *stored, holdNotHeld(*stored)
+	}
+	class, found := bookingClass(*stored)
+	if !found {
+		return *stored, &requestError{status: http.StatusConflict, message: "The class of the hold no longer exists"}
+	}
+
+	booking := *stored
+	booking.Status, booking.PaymentStatus = models.StatusBooked, ""
+	booking.CreatedAt = current
+	// the window may have closed or the member may have been suspended since the seat was held
+	if err := checkBookingWindow(class, booking); err != nil {
+		return *stored, err
+	}
+	if err := checkSuspension(booking); err != nil {
+		return *stored, err
+	}
+	if booking, err = entitleBooking(bookings, creditLedger, class, booking); err != nil {
+		return booking, err
+	}
+	// the seat stays held so that the member can try again with a payment token
+	if booking.PaymentStatus == models.PaymentPending && token == "" {
+		return booking, paymentRequired(booking)
+	}
+	*stored = booking
+	return booking, nil
+}
+
+// ExpireHolds gives the seats of the holds which were not converted in time back to their class
+// and returns how many holds expired
+func ExpireHolds() int {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	current := clk.Now()
+	expired := 0
+	for _, booked := range bookings {
+		expired += expireHolds(booked, current)
+	}
+	return expired
+}
+
+// RunHoldSweeper calls ExpireHolds every interval until the context is done
+func RunHoldSweeper(ctx context.Context, interval time.Duration) {
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			ExpireHolds()
+		}
+	}
+}
+
+// expireHolds marks the holds which are due as expired in place and returns how many it marked,
+// the caller must hold storageMu when passing our bookings
+func expireHolds(booked []models.Booking, current time.Time) int {
+	expired := 0
+	for i := range booked {
+		if booked[i].Status == models.StatusHeld && !current.Before(booked[i].HoldExpiresAt) {
+			booked[i].Status = models.StatusExpired
+			expired++
+		}
+	}
+	return expired
+}
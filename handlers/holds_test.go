@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/payments"
+)
+
+// a yoga class with a single seat on the 2nd of October, held the evening before
+func setUpHoldStorage(t *testing.T) *clock.Fake {
+	date := setUpRoomStorage()
+	classStorage[date][0].Capacity = 1
+	creditLedger = make(map[string][]models.CreditEntry)
+	return useFakeClock(t, date.Add(-12*time.Hour))
+}
+
+func postHold(t *testing.T, body string) (*httptest.ResponseRecorder, HoldResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreateHold).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/holds", strings.NewReader(body)))
+
+	var response HoldResponse
+	if rec.Code == http.StatusCreated {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("could not unmarshal response: %v", err)
+		}
+	}
+	return rec, response
+}
+
+func postConvertHold(t *testing.T, id, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/holds/"+id+"/booking", strings.NewReader(body)), "id", id)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostConvertHold).ServeHTTP(rec, req)
+	return rec
+}
+
+// a hold takes a seat until it is converted into a booking, which keeps its id
+func TestPostCreateHold(t *testing.T) {
+	fake := setUpHoldStorage(t)
+
+	if rec, _ := postHold(t, `{"name":"Meher","date":"2024-10-02","minutes":45}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a hold longer than 30 minutes, got %d", rec.Code)
+	}
+
+	rec, hold := postHold(t, `{"name":"Meher","date":"2024-10-02","minutes":15}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/v1/holds/"+hold.ID {
+		t.Fatalf("expected status 201 with the location of the hold, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if hold.Status != models.StatusHeld || hold.ClassID != "c1" || !hold.ExpiresAt.Equal(fake.Now().Add(15*time.Minute)) {
+		t.Errorf("unexpected hold %+v", hold)
+	}
+
+	// the held seat counts against the capacity of the class
+	if rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02"}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected the class to be full, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := postConvertHold(t, hold.ID, ""); rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/v1/bookings/"+hold.ID {
+		t.Fatalf("expected status 201 with the location of the booking, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if status := bookings["2024-10-02"][0].Status; status != models.StatusBooked {
+		t.Errorf("expected the hold to be booked, got %s", status)
+	}
+	if rec := postConvertHold(t, hold.ID, ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when converting twice, got %d", rec.Code)
+	}
+	if rec := postConvertHold(t, "unknown", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// a hold which was not converted in time gives its seat back right away
+func TestPostConvertHold_Expired(t *testing.T) {
+	fake := setUpHoldStorage(t)
+	_, hold := postHold(t, `{"name":"Meher","date":"2024-10-02"}`)
+
+	fake.Advance(DefaultHoldDuration)
+	if rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02"}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected the expired hold to give its seat back, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postConvertHold(t, hold.ID, ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for an expired hold, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// a hold is converted under the same rules as a booking, the booking window and suspensions are checked again
+func TestPostConvertHold_Rechecks(t *testing.T) {
+	fake := setUpHoldStorage(t)
+	date := fake.Now().Add(12 * time.Hour)
+	suspensions = make(map[string]time.Time)
+	t.Cleanup(func() { suspensions = make(map[string]time.Time) })
+
+	_, hold := postHold(t, `{"name":"Meher","date":"2024-10-02"}`)
+	suspensions["meher"] = date.Add(24 * time.Hour)
+	if rec := postConvertHold(t, hold.ID, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a suspended member, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.Status != models.StatusHeld {
+		t.Errorf("expected the seat to stay held, got %+v", booking)
+	}
+
+	delete(suspensions, "meher")
+	classStorage[date][0].BookingClosesBefore = 22 * time.Hour
+	if rec := postConvertHold(t, hold.ID, ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 once bookings closed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// drop-ins pay when they convert their hold, the seat stays held when the payment fails
+func TestPostConvertHold_DropIn(t *testing.T) {
+	_, fake := setUpDropInStorage(t)
+	_, hold := postHold(t, `{"name":"Meher","date":"2024-10-02"}`)
+
+	if rec := postConvertHold(t, hold.ID, ""); rec.Code != http.StatusPaymentRequired {
+		t.Errorf("expected status 402 without a payment token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postConvertHold(t, hold.ID, `{"payment_token":"tok_declined"}`); rec.Code != http.StatusPaymentRequired {
+		t.Errorf("expected status 402 for a declined payment, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.Status != models.StatusHeld || booking.PaymentStatus != models.PaymentFailed {
+		t.Errorf("expected the seat to stay held, got %+v", booking)
+	}
+
+	if rec := postConvertHold(t, hold.ID, `{"payment_token":"tok_visa"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	booking := bookings["2024-10-02"][0]
+	if booking.Status != models.StatusBooked || booking.PaymentStatus != models.PaymentCaptured || booking.AmountCents != 2000 {
+		t.Errorf("expected a paid booking, got %+v", booking)
+	}
+	if paid := fake.Payments(); len(paid) != 1 || paid[0].Status != payments.StatusCaptured || paid[0].Charge.Reference != hold.ID {
+		t.Errorf("unexpected payments %+v", paid)
+	}
+}
+
+// letting go of a hold gives the seat back, holds are not cancelled like bookings
+func TestDeleteHold(t *testing.T) {
+	setUpHoldStorage(t)
+	_, hold := postHold(t, `{"name":"Meher","date":"2024-10-02"}`)
+
+	if rec, _ := cancelBooking(t, hold.ID); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when cancelling a hold, got %d", rec.Code)
+	}
+
+	req := withURLParam(httptest.NewRequest(http.MethodDelete, "/holds/"+hold.ID, nil), "id", hold.ID)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(DeleteHold).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02"}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected the seat to be given back, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// the sweeper expires the holds which were not converted in time
+func TestRunHoldSweeper(t *testing.T) {
+	fake := setUpHoldStorage(t)
+	postHold(t, `{"name":"Meher","date":"2024-10-02","minutes":5}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunHoldSweeper(ctx, time.Minute)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the sweeper might not have created its ticker yet, so we keep moving the clock until it expires the hold
+	deadline := time.Now().Add(time.Second)
+	for {
+		fake.Advance(time.Minute)
+		storageMu.RLock()
+		status := bookings["2024-10-02"][0].Status
+		storageMu.RUnlock()
+		if status == models.StatusExpired {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the sweeper to expire the hold, got %s", status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
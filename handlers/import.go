@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// maxImportSize limits the size of an import body, a few thousand rows fit comfortably
+const maxImportSize = 10 << 20
+
+// statuses of an import row
+const (
+	RowAccepted = "accepted"
+	RowRejected = "rejected"
+)
+
+// ImportRowResult reports what happened to a single line of an import
+type ImportRowResult struct {
+	Line    int    `json:"line"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ImportReport is the response of the import endpoints.
+// Imports are all-or-nothing, Applied is only true when every row was accepted and it was not a dry run.
+type ImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Applied  bool              `json:"applied"`
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// add records the outcome of a row
+func (report *ImportReport) add(line int, message string, err error) {
+	if err != nil {
+		report.Rejected++
+		report.Rows = append(report.Rows, ImportRowResult{Line: line, Status: RowRejected, Error: err.Error()})
+		return
+	}
+	report.Accepted++
+	report.Rows = append(report.Rows, ImportRowResult{Line: line, Status: RowAccepted, Message: message})
+}
+
+// importRow is a decoded line of an import, err is set when the line itself could not be decoded
+type importRow[T any] struct {
+	line int
+	req  T
+	err  error
+}
+
+// Handler for importing classes from CSV or JSON Lines, each row is validated like PostCreateClass
+func PostImportClasses(w http.ResponseWriter, r *http.Request) {
+	rows, dryRun, ok := readImport[CreateClassRequest](w, r)
+	if !ok {
+		return
+	}
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	// applying the rows to a copy of the storage so that we can throw everything away if a row is rejected
+	classes, _ := copyStorage()
+	report := ImportReport{DryRun: dryRun}
+	var imported []events.Event
+
+	for _, row := range rows {
+		err := row.err
+		message := ""
+		if err == nil {
+			var class models.Class
+			if class, err = newClass(row.req); err == nil {
+				var n int
+				if n, err = addClass(classes, class); err == nil {
+					imported = append(imported, events.ClassCreated{Meta: events.NewMeta(clk.Now()), Class: class, Sessions: n})
+					message = fmt.Sprintf("created %s classes between %s and %s with Capacity: %d",
+						class.ClassName, class.StartDate.Format("2006-01-02"), class.EndDate.Format("2006-01-02"), class.Capacity)
+				}
+			}
+		}
+		report.add(row.line, message, err)
+	}
+
+	if report.Rejected == 0 && !dryRun {
+		// the storage is only swapped once the events of the import are in the outbox
+		if err := emit(imported...); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		classStorage = classes
+		report.Applied = true
+	}
+	writeImportReport(w, report)
+}
+
+// Handler for importing bookings from CSV or JSON Lines, each row is validated like PostCreateBooking
+func PostImportBookings(w http.ResponseWriter, r *http.Request) {
+	rows, dryRun, ok := readImport[BookingRequest](w, r)
+	if !ok {
+		return
+	}
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	// applying the rows to a copy of the storage so that we can throw everything away if a row is rejected
+	_, roster := copyStorage()
+	ledger := copyLedger()
+	report := ImportReport{DryRun: dryRun}
+	var imported []events.Event
+
+	for _, row := range rows {
+		err := row.err
+		message := ""
+		if err == nil {
+			var booking models.Booking
+			if booking, err = newBooking(row.req); err == nil {
+				if booking, err = addBooking(classStorage, roster, ledger, booking); err == nil {
+					message = enrolledMessage(booking)
+					imported = append(imported, bookingCreated(booking))
+				}
+				// drop-ins pay when they book, we cannot take payments for the rows of an import
+				if err == nil && booking.PaymentStatus == models.PaymentPending {
+					err = &requestError{status: http.StatusPaymentRequired, message: "Drop-in bookings need a payment and cannot be imported"}
+				}
+			}
+		}
+		report.add(row.line, message, err)
+	}
+
+	if report.Rejected == 0 && !dryRun {
+		// the storage is only swapped once the events of the import are in the outbox
+		if err := emit(imported...); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		bookings, creditLedger = roster, ledger
+		report.Applied = true
+	}
+	writeImportReport(w, report)
+}
+
+// copyStorage returns copies of classStorage and bookings which can be modified without touching ours.
+// The caller must hold storageMu.
+func copyStorage() (map[time.Time][]models.Class, map[string][]models.Booking) {
+	classes := make(map[time.Time][]models.Class, len(classStorage))
+	for date, sessions := range classStorage {
+		classes[date] = append([]models.Class(nil), sessions...)
+	}
+	roster := make(map[string][]models.Booking, len(bookings))
+	for date, booked := range bookings {
+		roster[date] = append([]models.Booking(nil), booked...)
+	}
+	return classes, roster
+}
+
+// writeImportReport responds with 200 when every row was accepted and 422 when the import was rejected
+func writeImportReport(w http.ResponseWriter, report ImportReport) {
+	status := http.StatusOK
+	if report.Rejected > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	helpers.WriteJSON(w, report, status)
+}
+
+// readImport checks the method and query and decodes the rows of an import body, writing the error response when it cannot
+func readImport[T any](w http.ResponseWriter, r *http.Request) ([]importRow[T], bool, bool) {
+	// validating whether we got the right access method
+	if !helpers.ValidateRequestMethod(w, r, http.MethodPost) {
+		return nil, false, false
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid value for dry_run", http.StatusBadRequest)
+			return nil, false, false
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var rows []importRow[T]
+	var err error
+	switch mediaType {
+	case "text/csv":
+		rows, err = readCSVRows[T](body)
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		rows, err = readJSONLinesRows[T](body)
+	default:
+		http.Error(w, "Unsupported content type, expected text/csv or application/x-ndjson", http.StatusUnsupportedMediaType)
+		return nil, false, false
+	}
+
+	if err != nil {
+		http.Error(w, "Unable to read the import: "+err.Error(), http.StatusBadRequest)
+		return nil, false, false
+	}
+	if len(rows) == 0 {
+		http.Error(w, "No rows to import", http.StatusBadRequest)
+		return nil, false, false
+	}
+	return rows, dryRun, true
+}
+
+// readCSVRows decodes a CSV file whose header row uses the json field names of T
+func readCSVRows[T any](body io.Reader) ([]importRow[T], error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []importRow[T]
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		// a record which is not valid CSV stops the import as we cannot tell where the next one starts
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow[T]{line: line}
+		row.err = helpers.DecodeCSVRecord(header, record, &row.req)
+		rows = append(rows, row)
+	}
+}
+
+// readJSONLinesRows decodes one JSON object per line, blank lines are skipped
+func readJSONLinesRows[T any](body io.Reader) ([]importRow[T], error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxImportSize)
+
+	var rows []importRow[T]
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := importRow[T]{line: line}
+		if err := json.Unmarshal([]byte(text), &row.req); err != nil {
+			row.err = errors.New("Unable to decode the line")
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
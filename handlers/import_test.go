@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+func importRequest(path, contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func decodeReport(t *testing.T, rec *httptest.ResponseRecorder) ImportReport {
+	t.Helper()
+	var report ImportReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	return report
+}
+
+// importing valid classes from CSV should create all of them
+func TestPostImportClasses_CSV(t *testing.T) {
+	classStorage = make(map[time.Time][]models.Class)
+
+	body := "class_name,start_date,end_date,capacity\n" +
+		"Yoga,2024-10-01,2024-10-02,15\n" +
+		"Pilates,2024-10-03,2024-10-03,10\n"
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostImportClasses).ServeHTTP(rec, importRequest("/import/classes", "text/csv", body))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	report := decodeReport(t, rec)
+	if !report.Applied || report.Accepted != 2 || report.Rejected != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report.Rows[1].Line != 3 {
+		t.Errorf("expected the second row to be on line 3, got %d", report.Rows[1].Line)
+	}
+	if len(classStorage) != 3 {
+		t.Errorf("expected 3 class days, got %d", len(classStorage))
+	}
+}
+
+// a single invalid row should reject the whole import and report every row
+func TestPostImportClasses_AllOrNothing(t *testing.T) {
+	classStorage = make(map[time.Time][]models.Class)
+
+	body := `{"class_name":"Yoga","start_date":"2024-10-01","end_date":"2024-10-02","capacity":15}
+
+{"class_name":"Pilates","start_date":"2024-10-02","end_date":"2024-10-03","capacity":10}
+{"class_name":"Spin","start_date":"2024-10-05","end_date":"2024-10-04","capacity":10}
+not json
+`
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostImportClasses).ServeHTTP(rec, importRequest("/import/classes", "application/x-ndjson", body))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", rec.Code)
+	}
+
+	report := decodeReport(t, rec)
+	expected := []ImportRowResult{
+		{Line: 1, Status: RowAccepted, Message: "created Yoga classes between 2024-10-01 and 2024-10-02 with Capacity: 15"},
+		{Line: 3, Status: RowRejected, Error: "Class already exists on 2024-10-02"},
+		{Line: 4, Status: RowRejected, Error: "start date cannot be after end date"},
+		{Line: 5, Status: RowRejected, Error: "Unable to decode the line"},
+	}
+	if report.Applied || len(report.Rows) != len(expected) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for i, row := range expected {
+		if report.Rows[i] != row {
+			t.Errorf("row %d: expected %+v, got %+v", i, row, report.Rows[i])
+		}
+	}
+
+	// nothing should have been stored
+	if len(classStorage) != 0 {
+		t.Errorf("expected no classes to be stored, got %d", len(classStorage))
+	}
+}
+
+// a dry run validates the bookings against the existing classes without storing them
+func TestPostImportBookings_DryRun(t *testing.T) {
+	date, _ := time.Parse("2006-01-02", "2024-10-02")
+	classStorage = map[time.Time][]models.Class{
+		date: {{ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 2}},
+	}
+	bookings = make(map[string][]models.Booking)
+	useFakeClock(t, date.AddDate(0, 0, -1))
+
+	body := "name,date\nMeher,2024-10-02\nRavi,2024-10-02\n"
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostImportBookings).ServeHTTP(rec, importRequest("/import/bookings?dry_run=true", "text/csv", body))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	report := decodeReport(t, rec)
+	if !report.DryRun || report.Applied || report.Accepted != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if len(bookings["2024-10-02"]) != 0 {
+		t.Errorf("expected a dry run not to store bookings, got %v", bookings)
+	}
+}
+
+// rows are validated against the earlier rows of the same import, so capacity and duplicates are enforced
+func TestPostImportBookings_Rejected(t *testing.T) {
+	date, _ := time.Parse("2006-01-02", "2024-10-02")
+	classStorage = map[time.Time][]models.Class{
+		date: {{ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 2}},
+	}
+	bookings = make(map[string][]models.Booking)
+	useFakeClock(t, date.AddDate(0, 0, -1))
+
+	body := "name,date\nMeher,2024-10-02\nmeher,2024-10-02\nRavi,2024-10-02\nAnu,2024-10-02\n"
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostImportBookings).ServeHTTP(rec, importRequest("/import/bookings", "text/csv", body))
+
+	report := decodeReport(t, rec)
+	if rec.Code != http.StatusUnprocessableEntity || report.Applied {
+		t.Fatalf("expected the import to be rejected, got %d %+v", rec.Code, report)
+	}
+	if report.Rows[1].Error != "You have already enrolled into class" || report.Rows[3].Error != "Class is full" {
+		t.Errorf("unexpected rows %+v", report.Rows)
+	}
+	if len(bookings["2024-10-02"]) != 0 {
+		t.Errorf("expected no bookings to be stored, got %v", bookings)
+	}
+}
+
+// only CSV and JSON Lines are accepted
+func TestPostImportClasses_UnsupportedContentType(t *testing.T) {
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostImportClasses).ServeHTTP(rec, importRequest("/import/classes", "application/json", "[]"))
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status 415, got %d", rec.Code)
+	}
+}
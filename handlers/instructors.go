@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// struct to hold payload for creating or updating an instructor
+type InstructorRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty" validate:"optional"`
+	Bio   string `json:"bio,omitempty" validate:"optional"`
+}
+
+// struct to hold payload for assigning an instructor to a class session
+type AssignInstructorRequest struct {
+	InstructorID string `json:"instructor_id"`
+	// ClassID picks the class when there are several on the date
+	ClassID string `json:"class_id,omitempty" validate:"optional"`
+}
+
+// InstructorResponse is how an instructor is shown by the API
+type InstructorResponse struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Bio   string `json:"bio,omitempty"`
+}
+
+// ClassSession is a single session of the schedule
+type ClassSession struct {
+	ClassID    string              `json:"class_id"`
+	Date       string              `json:"date"`
+	ClassName  string              `json:"class_name"`
+	Start      time.Time           `json:"start"`
+	End        time.Time           `json:"end"`
+	Capacity   int                 `json:"capacity"`
+	Booked     int                 `json:"booked"`
+	Instructor *InstructorResponse `json:"instructor,omitempty"`
+	Room       *RoomResponse       `json:"room,omitempty"`
+	// FreeSpots are the spots members can still book in classes with numbered spots
+	FreeSpots []int `json:"free_spots,omitempty"`
+	// CreditCost is the number of credits a booking of the session costs
+	CreditCost int `json:"credit_cost,omitempty"`
+	// DropInPriceCents is what members pay for the session when no plan or credits cover it
+	DropInPriceCents int `json:"drop_in_price_cents,omitempty"`
+}
+
+// instructors are stored by id and guarded by storageMu like the classes they teach
+var instructors = make(map[string]models.Instructor)
+
+func newInstructorResponse(instructor models.Instructor) InstructorResponse {
+	return InstructorResponse{ID: instructor.ID, Name: instructor.Name, Email: instructor.Email, Bio: instructor.Bio}
+}
+
+// Handler for creating an instructor
+func PostCreateInstructor(w http.ResponseWriter, r *http.Request) {
+	var req InstructorRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	instructor := models.Instructor{ID: helpers.NewID(), Name: req.Name, Email: req.Email, Bio: req.Bio}
+
+	storageMu.Lock()
+	instructors[instructor.ID] = instructor
+	storageMu.Unlock()
+
+	w.Header().Set("Location", "/v1/instructors/"+instructor.ID)
+	helpers.WriteJSON(w, newInstructorResponse(instructor), http.StatusCreated)
+}
+
+// Handler for listing the instructors sorted by name
+func GetInstructors(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	list := make([]InstructorResponse, 0, len(instructors))
+	for _, instructor := range instructors {
+		list = append(list, newInstructorResponse(instructor))
+	}
+	storageMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	helpers.WriteJSON(w, list, http.StatusOK)
+}
+
+// Handler for fetching a single instructor
+func GetInstructor(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	instructor, found := instructors[chi.URLParam(r, "id")]
+	storageMu.RUnlock()
+
+	if !found {
+		http.Error(w, "Instructor not found", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, newInstructorResponse(instructor), http.StatusOK)
+}
+
+// Handler for replacing the details of an instructor
+func PutInstructor(w http.ResponseWriter, r *http.Request) {
+	var req InstructorRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	_, found := instructors[id]
+	instructor := models.Instructor{ID: id, Name: req.Name, Email: req.Email, Bio: req.Bio}
+	if found {
+		instructors[id] = instructor
+	}
+	storageMu.Unlock()
+
+	if !found {
+		http.Error(w, "Instructor not found", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, newInstructorResponse(instructor), http.StatusOK)
+}
+
+// Handler for deleting an instructor, instructors who are still assigned to a class cannot be deleted
+func DeleteInstructor(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	if _, found := instructors[id]; !found {
+		http.Error(w, "Instructor not found", http.StatusNotFound)
+		return
+	}
+	for date, sessions := range classStorage {
+		for _, class := range sessions {
+			if class.InstructorID == id {
+				http.Error(w, fmt.Sprintf("Instructor is assigned to the class on %s", date.Format("2006-01-02")), http.StatusConflict)
+				return
+			}
+		}
+	}
+	delete(instructors, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for assigning an instructor to the class session on a date, the members enrolled in the session are notified
+// of the change like for a substitution. Sessions which already started cannot be changed anymore.
+func PutClassInstructor(w http.ResponseWriter, r *http.Request) {
+	parsed, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		http.Error(w, "invalid date format", http.StatusBadRequest)
+		return
+	}
+	date := helpers.NormalizeDate(parsed)
+
+	var req AssignInstructorRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	class, i, err := findClass(classStorage, date, req.ClassID)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	instructor, found := instructors[req.InstructorID]
+	if !found {
+		http.Error(w, "Instructor not found", http.StatusBadRequest)
+		return
+	}
+
+	current := clk.Now()
+	if !current.Before(class.Start(date)) {
+		http.Error(w, fmt.Sprintf("The class on %s already started", date.Format("2006-01-02")), http.StatusConflict)
+		return
+	}
+
+	message := fmt.Sprintf("%s is teaching %s on %s", instructor.Name, class.ClassName, date.Format("2006-01-02"))
+	previous := class.InstructorID
+	if previous == instructor.ID {
+		helpers.WriteJSONResponse(w, message, http.StatusOK)
+		return
+	}
+	class.InstructorID = instructor.ID
+	if err := checkInstructorAvailable(classStorage, class, date); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	// the members are notified from the event, nothing is changed when it cannot be emitted
+	changed := events.ClassChanged{
+		Meta:                 events.NewMeta(current),
+		Class:                class,
+		Date:                 date,
+		PreviousInstructorID: previous,
+		Bookings:             enrolledBookings(date, class),
+	}
+	if err := emit(changed); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	classStorage[date][i] = class
+	recordInstructorChange(date, class, previous, "", current)
+
+	helpers.WriteJSONResponse(w, message, http.StatusOK)
+}
+
+// Handler for listing the class sessions, optionally between the from and to dates, taught by an instructor or in a room.
+// The instructor and room parameters match the id or the name of the instructor or room.
+func GetClasses(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := exportRange(w, r)
+	if !ok {
+		return
+	}
+	instructorFilter := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("instructor")))
+	roomFilter := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("room")))
+
+	sessions := []ClassSession{}
+	for _, date := range classDates(from, to) {
+		storageMu.RLock()
+		for _, class := range classStorage[date] {
+			instructor, assigned := instructors[class.InstructorID]
+			if instructorFilter != "" && (!assigned || !matchesFilter(instructorFilter, instructor.ID, instructor.Name)) {
+				continue
+			}
+			room, inRoom := rooms[class.RoomID]
+			if roomFilter != "" && (!inRoom || !matchesFilter(roomFilter, room.ID, room.Name)) {
+				continue
+			}
+
+			session := ClassSession{
+				ClassID:          class.ID,
+				Date:             date.Format("2006-01-02"),
+				ClassName:        class.ClassName,
+				Start:            class.Start(date),
+				End:              class.End(date),
+				Capacity:         class.Capacity,
+				CreditCost:       class.CreditCost,
+				DropInPriceCents: class.DropInPriceCents,
+			}
+			active := activeBookings(classRoster(bookings[date.Format("2006-01-02")], class))
+			session.Booked = len(active)
+			if class.Spots > 0 {
+				session.FreeSpots = freeSpots(class, active)
+			}
+			if assigned {
+				response := newInstructorResponse(instructor)
+				session.Instructor = &response
+			}
+			if inRoom {
+				response := newRoomResponse(room)
+				session.Room = &response
+			}
+			sessions = append(sessions, session)
+		}
+		storageMu.RUnlock()
+	}
+	helpers.WriteJSON(w, sessions, http.StatusOK)
+}
+
+// matchesFilter reports whether the lower cased filter is the id or the name of a resource
+func matchesFilter(filter, id, name string) bool {
+	return strings.ToLower(id) == filter || strings.ToLower(name) == filter
+}
+
+// writeSessionError writes the error of findClass, a missing class is a 404 for the endpoints addressing a session
+func writeSessionError(w http.ResponseWriter, err error) {
+	if reqErr, ok := err.(*requestError); ok && reqErr.reason != "" {
+		http.Error(w, reqErr.message, http.StatusNotFound)
+		return
+	}
+	writeRequestError(w, err)
+}
+
+// checkInstructorAvailable makes sure the instructor of the class on date does not teach another session at the same time.
+// The caller must hold storageMu when passing our classStorage.
+func checkInstructorAvailable(classes map[time.Time][]models.Class, class models.Class, date time.Time) error {
+	if class.InstructorID == "" {
+		return nil
+	}
+	for otherDate, sessions := range classes {
+		for _, other := range sessions {
+			if other.InstructorID != class.InstructorID || (otherDate.Equal(date) && other.ID == class.ID) {
+				continue
+			}
+			if class.Overlaps(date, other, otherDate) {
+				return &requestError{
+					status: http.StatusConflict,
+					message: fmt.Sprintf("Instructor is already teaching %s on %s at that time",
+						other.ClassName, otherDate.Format("2006-01-02")),
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+)
+
+// two instructors and a 9:00 yoga class on the 2nd and 3rd of October taught by Asha
+func setUpInstructorStorage() time.Time {
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+	next := date.AddDate(0, 0, 1)
+	instructors = map[string]models.Instructor{
+		"i1": {ID: "i1", Name: "Asha"},
+		"i2": {ID: "i2", Name: "Bruno"},
+	}
+	yoga := models.Class{ClassName: "Yoga", StartDate: date, EndDate: next, Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour, InstructorID: "i1"}
+	classStorage = map[time.Time][]models.Class{date: {yoga}, next: {yoga}}
+	bookings = make(map[string][]models.Booking)
+	return date
+}
+
+// creating an instructor should return it with a location, and it should be listed and fetched afterwards
+func TestInstructorsCRUD(t *testing.T) {
+	instructors = make(map[string]models.Instructor)
+	classStorage = make(map[time.Time][]models.Class)
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreateInstructor).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/instructors", strings.NewReader(`{"name":"Asha","bio":"Vinyasa"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created InstructorResponse
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.ID == "" || created.Name != "Asha" || rec.Header().Get("Location") != "/v1/instructors/"+created.ID {
+		t.Fatalf("unexpected instructor %+v at %q", created, rec.Header().Get("Location"))
+	}
+
+	req := withURLParam(httptest.NewRequest(http.MethodPut, "/instructors/"+created.ID, strings.NewReader(`{"name":"Asha Rao"}`)), "id", created.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(PutInstructor).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetInstructors).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/instructors", nil))
+	var list []InstructorResponse
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Name != "Asha Rao" || list[0].Bio != "" {
+		t.Errorf("unexpected instructors %+v", list)
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodDelete, "/instructors/"+created.ID, nil), "id", created.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(DeleteInstructor).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rec.Code)
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodGet, "/instructors/"+created.ID, nil), "id", created.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetInstructor).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", rec.Code)
+	}
+}
+
+// an instructor still teaching a class cannot be deleted
+func TestDeleteInstructor_Assigned(t *testing.T) {
+	setUpInstructorStorage()
+
+	req := withURLParam(httptest.NewRequest(http.MethodDelete, "/instructors/i1", nil), "id", "i1")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(DeleteInstructor).ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+}
+
+// an instructor cannot be assigned to sessions overlapping one they already teach
+func TestAddClass_InstructorConflict(t *testing.T) {
+	date := setUpInstructorStorage()
+	previous := date.AddDate(0, 0, -1)
+	// the classes are in different rooms so that only the instructor can conflict
+	rooms = map[string]models.Room{"r1": {ID: "r1", Name: "Studio A", Capacity: 20}, "r2": {ID: "r2", Name: "Studio B", Capacity: 20}}
+	for _, d := range []time.Time{date, date.AddDate(0, 0, 1)} {
+		classStorage[d][0].RoomID = "r1"
+	}
+
+	// a late class running past midnight overlaps the morning session of the next day only if it is long enough
+	late := models.Class{ClassName: "Night Flow", StartDate: previous, EndDate: previous, Capacity: 5, StartTime: 23 * time.Hour, Duration: 11 * time.Hour, InstructorID: "i1", RoomID: "r2"}
+	if _, err := addClass(classStorage, late); err == nil || !strings.HasPrefix(err.Error(), "Instructor is already teaching Yoga on 2024-10-02") {
+		t.Errorf("expected an instructor conflict, got %v", err)
+	}
+
+	late.Duration = 2 * time.Hour
+	if _, err := addClass(classStorage, late); err != nil {
+		t.Errorf("expected the class to be added, got %v", err)
+	}
+
+	late.StartDate, late.EndDate, late.InstructorID = previous.AddDate(0, 0, -1), previous.AddDate(0, 0, -1), "unknown"
+	if _, err := addClass(classStorage, late); err == nil || err.Error() != "Instructor not found" {
+		t.Errorf("expected an unknown instructor to be rejected, got %v", err)
+	}
+}
+
+func putClassInstructor(datestr, body string) *httptest.ResponseRecorder {
+	req := withURLParam(httptest.NewRequest(http.MethodPut, "/classes/"+datestr+"/instructor", strings.NewReader(body)), "date", datestr)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PutClassInstructor).ServeHTTP(rec, req)
+	return rec
+}
+
+// assigning an instructor to a session only changes that session, and notifies its members like a substitution
+func TestPutClassInstructor(t *testing.T) {
+	date := setUpInstructorStorage()
+	fake := useFakeClock(t, date.Add(-24*time.Hour))
+	bookings["2024-10-03"] = []models.Booking{{ID: "b1", Name: "Meher", Date: date.AddDate(0, 0, 1), Status: models.StatusBooked}}
+	flushEvents()
+	recorder := useRecordingNotifier(t)
+
+	if rec := putClassInstructor("2024-10-03", `{"instructor_id":"i2"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if classStorage[date][0].InstructorID != "i1" || classStorage[date.AddDate(0, 0, 1)][0].InstructorID != "i2" {
+		t.Errorf("expected only the session of the 3rd to be reassigned, got %+v", classStorage)
+	}
+	flushEvents()
+	notifications.Flush(context.Background())
+	if len(recorder.messages) != 1 || recorder.messages[0].Kind != notify.KindSubstitution || recorder.messages[0].Details.PreviousInstructor != "Asha" {
+		t.Errorf("expected Meher to be told about the new instructor, got %+v", recorder.messages)
+	}
+
+	if rec := putClassInstructor("2024-10-05", `{"instructor_id":"i2"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without a class, got %d", rec.Code)
+	}
+
+	fake.Set(date.Add(9 * time.Hour))
+	if rec := putClassInstructor("2024-10-02", `{"instructor_id":"i2"}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a session which started, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if classStorage[date][0].InstructorID != "i1" {
+		t.Errorf("expected the session which started to keep its instructor, got %+v", classStorage[date][0])
+	}
+}
+
+// the schedule can be filtered by instructor id or name
+func TestGetClasses_InstructorFilter(t *testing.T) {
+	date := setUpInstructorStorage()
+	next := date.AddDate(0, 0, 1)
+	classStorage[next][0].InstructorID = "i2"
+
+	for _, filter := range []string{"i2", "bruno"} {
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(GetClasses).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/classes?instructor="+filter, nil))
+
+		var sessions []ClassSession
+		if err := json.Unmarshal(rec.Body.Bytes(), &sessions); err != nil {
+			t.Fatalf("could not unmarshal response: %v", err)
+		}
+		if len(sessions) != 1 || sessions[0].Date != "2024-10-03" || sessions[0].Instructor == nil || sessions[0].Instructor.Name != "Bruno" {
+			t.Errorf("%s: unexpected sessions %+v", filter, sessions)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetClasses).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/classes", nil))
+	var sessions []ClassSession
+	json.Unmarshal(rec.Body.Bytes(), &sessions)
+	if len(sessions) != 2 {
+		t.Errorf("expected every session without a filter, got %+v", sessions)
+	}
+}
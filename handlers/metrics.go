@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+)
+
+// registering the gauges which are computed from our in memory storage on every scrape
+func init() {
+	metrics.Default.Register(metrics.NewGaugeFunc("studio_class_fill_ratio",
+		"Ratio of booked seats to capacity for each upcoming class.",
+		[]string{"class_name", "date", "class_id"}, classFillRatios))
+}
+
+// classFillRatios returns booked/capacity for every class from today onwards
+func classFillRatios() []metrics.GaugeValue {
+	today := helpers.NormalizeDate(clk.Now())
+
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+
+	values := make([]metrics.GaugeValue, 0, len(classStorage))
+	for date, sessions := range classStorage {
+		if date.Before(today) {
+			continue
+		}
+		datestr := date.Format("2006-01-02")
+		for _, class := range sessions {
+			if class.Capacity <= 0 {
+				continue
+			}
+			ratio := float64(len(activeBookings(classRoster(bookings[datestr], class)))) / float64(class.Capacity)
+			values = append(values, metrics.GaugeValue{
+				LabelValues: []string{class.ClassName, datestr, class.ID},
+				Value:       ratio,
+			})
+		}
+	}
+	return values
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// fill ratio should only be reported for upcoming classes
+func TestClassFillRatios(t *testing.T) {
+	today := helpers.NormalizeDate(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+
+	classStorage = map[time.Time][]models.Class{
+		today:     {{ID: "c1", ClassName: "Yoga", StartDate: today, EndDate: today, Capacity: 4}},
+		yesterday: {{ID: "c1", ClassName: "Yoga", StartDate: yesterday, EndDate: yesterday, Capacity: 4}},
+	}
+	bookings = map[string][]models.Booking{
+		today.Format("2006-01-02"):     {{ClassID: "c1", Name: "Meher", Date: today}},
+		yesterday.Format("2006-01-02"): {{ClassID: "c1", Name: "Meher", Date: yesterday}, {ClassID: "c1", Name: "Ravi", Date: yesterday}},
+	}
+
+	expected := []metrics.GaugeValue{
+		{LabelValues: []string{"Yoga", today.Format("2006-01-02"), "c1"}, Value: 0.25},
+	}
+	if actual := classFillRatios(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
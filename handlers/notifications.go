@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+)
+
+// notifyMembers queues the confirmation of a booking or of its cancellation for the member, and the change of instructor
+// for the members enrolled in the session. It is an asynchronous subscriber of the bus acknowledging the event once the
+// messages were sent, it fails when the queue is full so that the outbox retries the event.
+func notifyMembers(ctx context.Context, event events.Event, ack events.Ack) error {
+	var msg notify.Message
+	switch e := event.(type) {
+	case events.ClassChanged:
+		notifySubstitution(e, ack)
+		return nil
+	case events.BookingCreated:
+		msg = bookingMessage(notify.KindBookingConfirmed, e.Booking, e.Class)
+		msg.Subject = fmt.Sprintf("You are booked for %s on %s", msg.Details.ClassName, msg.Details.Date)
+		msg.Body = fmt.Sprintf("You are booked for %s on %s at %s.", msg.Details.ClassName, msg.Details.Date, msg.Details.StartTime)
+	case events.BookingCancelled:
+		msg = bookingMessage(notify.KindBookingCancelled, e.Booking, e.Class)
+		msg.Details.LateCancel = e.LateCancel
+		msg.Subject = fmt.Sprintf("Your booking for %s on %s is cancelled", msg.Details.ClassName, msg.Details.Date)
+		msg.Body = fmt.Sprintf("Your booking for %s on %s at %s is cancelled.", msg.Details.ClassName, msg.Details.Date, msg.Details.StartTime)
+	default:
+		ack(nil)
+		return nil
+	}
+	if !notifications.Enqueue(msg, ack) {
+		return fmt.Errorf("the notifications queue is full")
+	}
+	return nil
+}
+
+// bookingMessage returns a message of the kind for the member of the booking, without its subject and body
+func bookingMessage(kind string, booking models.Booking, class models.Class) notify.Message {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+	return notify.Message{Kind: kind, To: booking.Name, Email: booking.Email, Details: sessionDetails(booking, class)}
+}
+
+// sessionDetails describes the session of the booking for the notifications, the caller must hold storageMu
+func sessionDetails(booking models.Booking, class models.Class) notify.Details {
+	return notify.Details{
+		BookingID:       booking.ID,
+		ClassName:       class.ClassName,
+		Date:            booking.Date.Format("2006-01-02"),
+		StartTime:       class.Start(booking.Date).Format("15:04"),
+		DurationMinutes: int(class.Duration / time.Minute),
+		Instructor:      instructors[class.InstructorID].Name,
+		Room:            rooms[class.RoomID].Name,
+		Spot:            booking.Spot,
+	}
+}
+
+// notifySubstitution queues a message for every member enrolled in the session whose instructor changed,
+// ack is called once every message was sent
+func notifySubstitution(e events.ClassChanged, ack events.Ack) {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+
+	datestr := e.Date.Format("2006-01-02")
+	body := fmt.Sprintf("%s will teach %s on %s at %s", instructors[e.Class.InstructorID].Name, e.Class.ClassName, datestr, e.Class.Start(e.Date).Format("15:04"))
+	previous, found := instructors[e.PreviousInstructorID]
+	if found {
+		body += fmt.Sprintf(" instead of %s", previous.Name)
+	}
+	body += "."
+
+	acks := ack.Split(len(e.Bookings))
+	for i, booking := range e.Bookings {
+		msg := notify.Message{
+			Kind:    notify.KindSubstitution,
+			To:      booking.Name,
+			Email:   booking.Email,
+			Subject: fmt.Sprintf("New instructor for %s on %s", e.Class.ClassName, datestr),
+			Body:    body,
+			Details: sessionDetails(booking, e.Class),
+		}
+		msg.Details.PreviousInstructor = previous.Name
+		if !notifications.Enqueue(msg, acks[i]) {
+			acks[i](fmt.Errorf("the notifications queue is full, %s was not notified", booking.Name))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+	"github.com/MeherKandukuri/studioClasses_API/outbox"
+)
+
+// members get a confirmation when they are enrolled and when they cancel, with the details of the session
+func TestBookingNotifications(t *testing.T) {
+	setUpCancellationPolicy(t)
+	setUpHoldStorage(t)
+	flushEvents()
+	recorder := useRecordingNotifier(t)
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","email":"not an address"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid email, got %d", rec.Code)
+	}
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","email":"Meher <meher@example.com>"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	booking := bookings["2024-10-02"][0]
+	cancelBooking(t, booking.ID)
+	flushEvents()
+	notifications.Flush(context.Background())
+
+	if len(recorder.messages) != 2 {
+		t.Fatalf("expected 2 notifications, got %+v", recorder.messages)
+	}
+	confirmed, cancelled := recorder.messages[0], recorder.messages[1]
+	expected := notify.Details{BookingID: booking.ID, ClassName: "Yoga", Date: "2024-10-02", StartTime: "09:00", DurationMinutes: 60, Room: "Studio A"}
+	if confirmed.Kind != notify.KindBookingConfirmed || confirmed.To != "Meher" || confirmed.Email != "meher@example.com" || confirmed.Details != expected {
+		t.Errorf("unexpected confirmation %+v", confirmed)
+	}
+	if cancelled.Kind != notify.KindBookingCancelled || cancelled.Email != "meher@example.com" || cancelled.Details.BookingID != booking.ID || cancelled.Details.LateCancel {
+		t.Errorf("unexpected cancellation %+v", cancelled)
+	}
+	if _, err := notify.DefaultTemplates().Render(confirmed); err != nil {
+		t.Errorf("could not render the confirmation: %v", err)
+	}
+}
+
+// an email the mail server did not take is sent again by the outbox after its backoff
+func TestBookingNotifications_SendFails(t *testing.T) {
+	fake := setUpHoldStorage(t)
+	store := useOutboxClock(t, fake)
+	recorder := useRecordingNotifier(t)
+	recorder.failures = 1
+
+	postBooking(t, `{"name":"Meher","date":"2024-10-02","email":"meher@example.com"}`)
+	flushEvents()
+	notifications.Flush(context.Background())
+	if pending, _ := store.Pending(); len(pending) != 1 || pending[0].Attempts != 1 || len(recorder.messages) != 0 {
+		t.Fatalf("expected the event to wait for its email, got %+v %+v", pending, recorder.messages)
+	}
+
+	fake.Advance(outbox.MinBackoff)
+	flushEvents()
+	notifications.Flush(context.Background())
+	if pending, _ := store.Pending(); len(pending) != 0 || len(recorder.messages) != 1 {
+		t.Errorf("expected the email to be sent on the second attempt, got %+v %+v", pending, recorder.messages)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/outbox"
+)
+
+// OutboxInterval is how often RunOutbox retries the events a subscriber failed
+const OutboxInterval = time.Second
+
+// eventOutbox keeps the events until the subscribers of the bus acknowledged them, RunOutbox runs its dispatcher.
+// The events are kept in memory unless SetOutboxStore is called.
+var eventOutbox = newEventOutbox(outbox.NewMemoryStore())
+
+func newEventOutbox(store outbox.Store) *outbox.Dispatcher {
+	return outbox.NewDispatcher(store, busSubscribers{}, clock.Real{})
+}
+
+// SetOutboxStore keeps the events in the store, e.g an outbox.FileStore so that they survive a restart.
+// It must be called before RunOutbox and before any request is served.
+func SetOutboxStore(store outbox.Store) {
+	eventOutbox = newEventOutbox(store)
+}
+
+// RunOutbox delivers the events of the outbox to the subscribers of the bus until the context is done,
+// starting with the ones left over from before a restart
+func RunOutbox(ctx context.Context) {
+	eventOutbox.Run(ctx, OutboxInterval)
+}
+
+// busSubscribers are the consumers of the outbox, the record of an event stays in the outbox until every subscriber
+// of the bus acknowledged the event, and a subscriber which failed is the only one getting it again
+type busSubscribers struct{}
+
+// Names returns the subscribers of the events of the type
+func (busSubscribers) Names(eventType string) []string {
+	return bus.Subscribers(eventType)
+}
+
+// Deliver hands the event stored in the record to the subscriber
+func (busSubscribers) Deliver(ctx context.Context, subscriber string, record outbox.Record, ack func(err error)) {
+	event, err := events.Decode(record.Type, record.Payload)
+	if err != nil {
+		ack(err)
+		return
+	}
+	bus.Deliver(ctx, subscriber, event, ack)
+}
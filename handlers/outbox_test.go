@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+	"github.com/MeherKandukuri/studioClasses_API/outbox"
+	"github.com/MeherKandukuri/studioClasses_API/payments"
+	"github.com/MeherKandukuri/studioClasses_API/webhooks"
+)
+
+// flushEvents publishes the events waiting in the outbox and handles them on the bus, tests dont run the workers
+func flushEvents() {
+	eventOutbox.DeliverPending(context.Background())
+}
+
+// useOutboxStore keeps the events in the store for the duration of the test
+func useOutboxStore(t *testing.T, store outbox.Store) {
+	t.Helper()
+	flushEvents()
+	previous := eventOutbox
+	SetOutboxStore(store)
+	t.Cleanup(func() { eventOutbox = previous })
+}
+
+// an event which was not published before the process stopped is published after the restart, with the same id
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	setUpHoldStorage(t)
+	d := useWebhookDispatcher(t)
+	receiver := &webhookReceiver{secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	postWebhook(t, `{"url":"`+server.URL+`","events":["booking.created"],"secret":"s3cret"}`)
+
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	store, err := outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("could not open the outbox: %v", err)
+	}
+	useOutboxStore(t, store)
+
+	// the process stops before the dispatcher runs
+	postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+	store.Close()
+
+	store, err = outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("could not reopen the outbox: %v", err)
+	}
+	defer store.Close()
+	SetOutboxStore(store)
+	pending, _ := store.Pending()
+	if len(pending) != 1 || pending[0].Type != events.TypeBookingCreated || pending[0].Key != bookings["2024-10-02"][0].ClassID {
+		t.Fatalf("expected the booking.created event to be pending, got %+v", pending)
+	}
+
+	flushEvents()
+	d.Flush(context.Background())
+	notifications.Flush(context.Background())
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("expected the event to be marked delivered, got %+v", pending)
+	}
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.events) != 1 || receiver.events[0].ID != pending[0].ID {
+		t.Errorf("expected the webhook to carry the id of the event, got %+v", receiver.events)
+	}
+}
+
+// failingStore cannot store records, e.g its disk is full
+type failingStore struct {
+	*outbox.MemoryStore
+}
+
+func (failingStore) Add(records ...outbox.Record) error {
+	return errors.New("no space left on device")
+}
+
+// a change whose event cannot be added to the outbox is undone and the client is told to try again
+func TestOutbox_AddFails(t *testing.T) {
+	setUpCancellationPolicy(t)
+	fake := setUpHoldStorage(t)
+	useOutboxStore(t, failingStore{outbox.NewMemoryStore()})
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 for the booking, got %d", rec.Code)
+	}
+	if len(bookings["2024-10-02"]) != 0 {
+		t.Errorf("expected the booking to be undone, got %+v", bookings["2024-10-02"])
+	}
+
+	SetOutboxStore(outbox.NewMemoryStore())
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	SetOutboxStore(failingStore{outbox.NewMemoryStore()})
+
+	// a late cancellation leaves no strike or fee behind
+	fake.Advance(time.Hour)
+	if rec, _ := cancelBooking(t, bookings["2024-10-02"][0].ID); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 for the cancellation, got %d", rec.Code)
+	}
+	if stored := bookings["2024-10-02"][0]; stored.Status != models.StatusBooked || len(strikes) != 0 || len(memberFees) != 0 {
+		t.Errorf("expected the cancellation to be undone, got %+v %v %v", stored, strikes, memberFees)
+	}
+}
+
+// the payment of a drop-in is refunded when its booking cannot be confirmed
+func TestOutbox_AddFailsRefundsDropIn(t *testing.T) {
+	_, fake := setUpDropInStorage(t)
+	useOutboxStore(t, failingStore{outbox.NewMemoryStore()})
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","payment_token":"tok_visa"}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.Status != models.StatusReleased || booking.PaymentStatus != models.PaymentRefunded {
+		t.Errorf("expected the seat to be released and the payment refunded, got %+v", booking)
+	}
+	if paid := fake.Payments(); len(paid) != 1 || paid[0].Status != payments.StatusRefunded {
+		t.Errorf("expected the payment to be refunded, got %+v", paid)
+	}
+}
+
+// useOutboxClock delivers the events of a fresh outbox on the clock for the duration of the test
+func useOutboxClock(t *testing.T, clk clock.Clock) *outbox.MemoryStore {
+	t.Helper()
+	flushEvents()
+	store := outbox.NewMemoryStore()
+	previous := eventOutbox
+	eventOutbox = outbox.NewDispatcher(store, busSubscribers{}, clk)
+	t.Cleanup(func() { eventOutbox = previous })
+	return store
+}
+
+// an event stays in the outbox until its notifications were sent, and a subscriber which could not take it gets it again
+func TestOutbox_RetriesFullQueue(t *testing.T) {
+	fake := setUpHoldStorage(t)
+	store := useOutboxClock(t, fake)
+	recorder := &recordingNotifier{}
+	notifications = notify.NewQueue(recorder, 1)
+	t.Cleanup(func() { SetNotifier(notify.LogNotifier{}) })
+
+	postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+	cancelBooking(t, bookings["2024-10-02"][0].ID)
+	flushEvents()
+	pending, _ := store.Pending()
+	if len(pending) != 2 || pending[0].Type != events.TypeBookingCreated || pending[1].Type != events.TypeBookingCancelled || pending[1].Attempts != 1 {
+		t.Fatalf("expected the booking to wait for its email and the cancellation for the queue, got %+v", pending)
+	}
+
+	notifications.Flush(context.Background())
+	if pending, _ := store.Pending(); len(pending) != 1 || pending[0].Type != events.TypeBookingCancelled {
+		t.Fatalf("expected the booking to be delivered once its email was sent, got %+v", pending)
+	}
+	fake.Advance(outbox.MinBackoff)
+	flushEvents()
+	notifications.Flush(context.Background())
+	if pending, _ := store.Pending(); len(pending) != 0 || len(recorder.messages) != 2 {
+		t.Errorf("expected both events to be delivered, got %+v %+v", pending, recorder.messages)
+	}
+}
+
+// an event the webhooks could not take is only delivered again to them, the metrics and the emails are not repeated
+func TestOutbox_WebhooksFailOnce(t *testing.T) {
+	fake := setUpHoldStorage(t)
+	store := useOutboxClock(t, fake)
+	recorder := useRecordingNotifier(t)
+	useWebhookDispatcher(t)
+	receiver := &webhookReceiver{secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	subscription := webhooks.Subscription{ID: "w1", URL: server.URL, Events: []string{events.TypeBookingCreated}, Secret: "s3cret"}
+
+	// the queues of the webhooks cannot take anything
+	full := webhooks.NewDispatcher(http.DefaultClient, webhooks.DefaultRetryPolicy(), 0)
+	full.Subscribe(subscription)
+	SetWebhookDispatcher(full)
+	created := metrics.BookingsCreated.Value()
+
+	postBooking(t, `{"name":"Meher","date":"2024-10-02","email":"meher@example.com"}`)
+	flushEvents()
+	notifications.Flush(context.Background())
+	pending, _ := store.Pending()
+	if len(pending) != 1 || pending[0].Attempts != 1 || fmt.Sprint(pending[0].Delivered) != "[metrics notifications]" {
+		t.Fatalf("expected the event to wait for the webhooks only, got %+v", pending)
+	}
+
+	d := useWebhookDispatcher(t)
+	d.Subscribe(subscription)
+	fake.Advance(outbox.MinBackoff)
+	flushEvents()
+	d.Flush(context.Background())
+	notifications.Flush(context.Background())
+
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("expected the event to be delivered, got %+v", pending)
+	}
+	if got := metrics.BookingsCreated.Value() - created; got != 1 {
+		t.Errorf("expected the booking to be counted once, got %v", got)
+	}
+	if len(recorder.messages) != 1 {
+		t.Errorf("expected a single confirmation, got %+v", recorder.messages)
+	}
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.events) != 1 || receiver.events[0].ID != pending[0].ID {
+		t.Errorf("expected the webhook to be delivered once, got %+v", receiver.events)
+	}
+}
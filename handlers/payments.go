@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/payments"
+)
+
+// gateway takes the payments of drop-in bookings, drop-ins are turned down with a 503 until a payment provider is configured
+var gateway payments.PaymentGateway
+
+// SetPaymentGateway sets the gateway drop-in bookings are paid through, nil turns drop-ins down
+func SetPaymentGateway(g payments.PaymentGateway) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	gateway = g
+}
+
+// payBooking authorizes and captures the payment of a drop-in booking whose seat is held, then confirms the booking.
+// The booking goes back to the failed status when the payment fails, released for bookings and held for converted holds.
+// It also does when its event cannot be emitted, the payment is then refunded.
+// The caller must not hold storageMu as the payment provider is called.
+func payBooking(ctx context.Context, booking models.Booking, token, failed string) (models.Booking, error) {
+	storageMu.RLock()
+	g := gateway
+	storageMu.RUnlock()
+
+	// the gateway was removed since the seat was taken
+	if g == nil {
+		booking, _ = settlePayment(booking, "", failed, paymentsUnavailable())
+		return booking, paymentsUnavailable()
+	}
+
+	paymentID, err := g.Authorize(ctx, payments.Charge{
+		AmountCents: booking.AmountCents,
+		Token:       token,
+		Reference:   booking.ID,
+		Description: fmt.Sprintf("Drop-in for class on %s", booking.Date.Format("2006-01-02")),
+	})
+	if err == nil {
+		if err = g.Capture(ctx, paymentID); err != nil {
+			// the amount stays reserved on the payment method of the member until the authorization is released
+			if voidErr := g.Void(ctx, paymentID); voidErr != nil {
+				log.Printf("payments: could not void payment %s of booking %s: %v", paymentID, booking.ID, voidErr)
+			}
+		}
+	}
+
+	booking, err = settlePayment(booking, paymentID, failed, err)
+	if err != nil && booking.PaymentStatus == models.PaymentCaptured {
+		// the event of the booking could not be emitted, the member is not enrolled and gets their money back
+		refundBooking(ctx, booking)
+	}
+	return booking, err
+}
+
+// settlePayment confirms the booking once its payment is captured, or puts it back to the failed status
+// when the payment failed or the event of the booking cannot be emitted
+func settlePayment(booking models.Booking, paymentID, failed string, paymentErr error) (models.Booking, error) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	datestr, i, found := findBooking(booking.ID)
+	if !found {
+		return booking, fmt.Errorf("booking %s disappeared while it was paid for", booking.ID)
+	}
+	stored := &bookings[datestr][i]
+	stored.PaymentID = paymentID
+	if paymentErr != nil {
+		stored.Status, stored.PaymentStatus = failed, models.PaymentFailed
+		return *stored, &requestError{
+			status:  http.StatusPaymentRequired,
+			message: "Payment failed: " + paymentErr.Error(),
+			reason:  metrics.RejectPaymentFailed,
+		}
+	}
+	stored.Status, stored.PaymentStatus = models.StatusBooked, models.PaymentCaptured
+	if err := emit(bookingCreated(*stored)); err != nil {
+		stored.Status = failed
+		return *stored, err
+	}
+	return *stored, nil
+}
+
+// refundBooking refunds the payment of a cancelled drop-in booking and returns the amount refunded.
+// A failed refund is logged and left for the front desk, the booking stays cancelled.
+// The caller must not hold storageMu as the payment provider is called.
+func refundBooking(ctx context.Context, booking models.Booking) int {
+	storageMu.RLock()
+	g := gateway
+	storageMu.RUnlock()
+
+	if g == nil {
+		log.Printf("payments: could not refund payment %s of booking %s: no payment gateway is configured", booking.PaymentID, booking.ID)
+		return 0
+	}
+	if err := g.Refund(ctx, booking.PaymentID); err != nil {
+		log.Printf("payments: could not refund payment %s of booking %s: %v", booking.PaymentID, booking.ID, err)
+		return 0
+	}
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	if datestr, i, found := findBooking(booking.ID); found {
+		bookings[datestr][i].PaymentStatus = models.PaymentRefunded
+	}
+	return booking.AmountCents
+}
+
+// removeBooking drops a booking which was just added, the caller must hold storageMu
+func removeBooking(id string) {
+	if datestr, i, found := findBooking(id); found {
+		bookings[datestr] = append(bookings[datestr][:i], bookings[datestr][i+1:]...)
+	}
+}
+
+// paymentsUnavailable turns down a drop-in booking while no payment gateway is configured
+func paymentsUnavailable() error {
+	return &requestError{status: http.StatusServiceUnavailable, message: "Drop-in payments are not available, please book with a plan or credits"}
+}
+
+// paymentRequired turns down a drop-in booking made without a payment token
+func paymentRequired(booking models.Booking) error {
+	return &requestError{
+		status:  http.StatusPaymentRequired,
+		message: fmt.Sprintf("Drop-ins pay %s for this class, please provide a payment_token", formatCents(booking.AmountCents)),
+		reason:  metrics.RejectPaymentRequired,
+	}
+}
+
+// formatCents formats an amount in cents for messages, e.g 2000 as 20.00
+func formatCents(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
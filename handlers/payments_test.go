@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/payments"
+)
+
+// useFakeGateway makes the drop-ins pay through a fake gateway for the duration of the test
+func useFakeGateway(t *testing.T, g payments.PaymentGateway) {
+	t.Helper()
+	previous := gateway
+	SetPaymentGateway(g)
+	t.Cleanup(func() { SetPaymentGateway(previous) })
+}
+
+// a yoga class with a single seat which drop-ins pay 20.00 for
+func setUpDropInStorage(t *testing.T) (time.Time, *payments.Fake) {
+	date := setUpRoomStorage()
+	classStorage[date][0].Capacity, classStorage[date][0].DropInPriceCents = 1, 2000
+	creditLedger = make(map[string][]models.CreditEntry)
+	useFakeClock(t, date.Add(-24*time.Hour))
+	fake := payments.NewFake()
+	useFakeGateway(t, fake)
+	return date, fake
+}
+
+// authorizingGateway calls authorize before authorizing a payment with the fake gateway
+type authorizingGateway struct {
+	*payments.Fake
+	authorize func()
+}
+
+func (g authorizingGateway) Authorize(ctx context.Context, charge payments.Charge) (string, error) {
+	g.authorize()
+	return g.Fake.Authorize(ctx, charge)
+}
+
+// drop-ins pay when they book, the payment is stored on the booking
+func TestPostCreateBooking_DropIn(t *testing.T) {
+	_, fake := setUpDropInStorage(t)
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusPaymentRequired {
+		t.Errorf("expected status 402 without a payment token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(bookings["2024-10-02"]) != 0 {
+		t.Errorf("expected no seat to be held without a payment token, got %+v", bookings["2024-10-02"])
+	}
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","payment_token":"tok_visa"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	booking := bookings["2024-10-02"][0]
+	if booking.Status != models.StatusBooked || booking.PaymentStatus != models.PaymentCaptured || booking.PaymentID != "pay_1" || booking.AmountCents != 2000 {
+		t.Errorf("expected a paid booking, got %+v", booking)
+	}
+	if paid := fake.Payments(); len(paid) != 1 || paid[0].Status != payments.StatusCaptured || paid[0].Charge.Reference != booking.ID {
+		t.Errorf("unexpected payments %+v", paid)
+	}
+}
+
+// the seat is held while the payment is pending and released when it fails
+func TestPostCreateBooking_DropInSeatHeld(t *testing.T) {
+	setUpDropInStorage(t)
+	held := false
+	useFakeGateway(t, authorizingGateway{Fake: payments.NewFake(), authorize: func() {
+		rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02","payment_token":"tok_visa"}`)
+		held = rec.Code == http.StatusConflict
+	}})
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","payment_token":"tok_declined"}`); rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected status 402 for a declined payment, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !held {
+		t.Errorf("expected the seat to be held while the payment was pending")
+	}
+	if booking := bookings["2024-10-02"][0]; booking.Status != models.StatusReleased || booking.PaymentStatus != models.PaymentFailed {
+		t.Errorf("expected the seat to be released, got %+v", booking)
+	}
+
+	useFakeGateway(t, payments.NewFake())
+	if rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02","payment_token":"tok_visa"}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected the released seat to be booked, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// a payment failing on capture releases the seat too, and the authorization is voided
+func TestPostCreateBooking_DropInCaptureFails(t *testing.T) {
+	_, fake := setUpDropInStorage(t)
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","payment_token":"tok_capture_fails"}`); rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected status 402, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.Status != models.StatusReleased || booking.PaymentID != "pay_1" {
+		t.Errorf("expected the seat to be released, got %+v", booking)
+	}
+	if paid := fake.Payments(); len(paid) != 1 || paid[0].Status != payments.StatusVoided {
+		t.Errorf("expected the authorization to be voided, got %+v", paid)
+	}
+}
+
+// drop-ins are turned down without taking a seat while no payment gateway is configured
+func TestPostCreateBooking_DropInWithoutGateway(t *testing.T) {
+	setUpDropInStorage(t)
+	useFakeGateway(t, nil)
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","payment_token":"tok_visa"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(bookings["2024-10-02"]) != 0 {
+		t.Errorf("expected no seat to be taken, got %+v", bookings["2024-10-02"])
+	}
+}
+
+// cancelling a paid drop-in on time refunds the payment
+func TestPostCancelBooking_DropInRefund(t *testing.T) {
+	setUpCancellationPolicy(t)
+	_, fake := setUpDropInStorage(t)
+	postBooking(t, `{"name":"Meher","date":"2024-10-02","payment_token":"tok_visa"}`)
+
+	rec, response := cancelBooking(t, bookings["2024-10-02"][0].ID)
+	if rec.Code != http.StatusOK || response.RefundedCents != 2000 {
+		t.Fatalf("expected the payment to be refunded, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if status := bookings["2024-10-02"][0].PaymentStatus; status != models.PaymentRefunded {
+		t.Errorf("expected the booking to be refunded, got %s", status)
+	}
+	if paid := fake.Payments(); paid[0].Status != payments.StatusRefunded {
+		t.Errorf("expected the payment to be refunded, got %+v", paid)
+	}
+}
+
+// members whose plan covers the class dont pay for it
+func TestPostCreateBooking_DropInCoveredByPlan(t *testing.T) {
+	date, fake := setUpDropInStorage(t)
+	plans = map[string]models.Plan{"p1": {ID: "p1", Name: "Unlimited", Period: models.PeriodMonth}}
+	subscriptions = map[string][]models.Subscription{"meher": {{ID: "s1", Member: "Meher", PlanID: "p1", StartDate: date}}}
+	t.Cleanup(func() {
+		plans = make(map[string]models.Plan)
+		subscriptions = make(map[string][]models.Subscription)
+	})
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.PaymentStatus != "" || len(fake.Payments()) != 0 {
+		t.Errorf("expected no payment, got %+v", booking)
+	}
+}
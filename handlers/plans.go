@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// codes of the problem details returned for bookings the plan of the member does not cover
+const (
+	CodePlanLimitReached = "plan_limit_reached"
+	CodeClassNotInPlan   = "class_not_in_plan"
+)
+
+// struct to hold payload for creating a membership plan
+type PlanRequest struct {
+	Name string `json:"name"`
+	// Period is what bookings are counted over, week or month
+	Period string `json:"period"`
+	// MaxBookings is the number of bookings per period, unlimited when it is not given
+	MaxBookings int `json:"max_bookings,omitempty" validate:"optional"`
+	// ClassTypes are the names of the classes the plan covers, every class when it is not given
+	ClassTypes []string `json:"class_types,omitempty" validate:"optional"`
+}
+
+// PlanResponse is how a membership plan is shown by the API
+type PlanResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Period      string   `json:"period"`
+	MaxBookings int      `json:"max_bookings,omitempty"`
+	ClassTypes  []string `json:"class_types,omitempty"`
+}
+
+// struct to hold payload for subscribing a member to a plan
+type SubscriptionRequest struct {
+	PlanID    string `json:"plan_id"`
+	StartDate string `json:"start_date"`
+	// EndDate is the last day of the subscription, it runs until cancelled without it
+	EndDate string `json:"end_date,omitempty" validate:"optional"`
+}
+
+// PlanUsage is how much of the plan a member used in the current period
+type PlanUsage struct {
+	PeriodStart string `json:"period_start"`
+	PeriodEnd   string `json:"period_end"`
+	Used        int    `json:"used"`
+	// Remaining is left out for unlimited plans
+	Remaining *int `json:"remaining,omitempty"`
+}
+
+// SubscriptionResponse is how the subscription of a member is shown by the API
+type SubscriptionResponse struct {
+	ID        string `json:"id"`
+	Member    string `json:"member"`
+	PlanID    string `json:"plan_id"`
+	PlanName  string `json:"plan_name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date,omitempty"`
+	// Usage is only given for subscriptions which are active today
+	Usage *PlanUsage `json:"usage,omitempty"`
+}
+
+// EntitlementProblem is the problem details body for bookings the plan of the member does not cover
+type EntitlementProblem struct {
+	helpers.ProblemDetails
+	PlanID      string `json:"plan_id"`
+	PeriodStart string `json:"period_start,omitempty"`
+	PeriodEnd   string `json:"period_end,omitempty"`
+	MaxBookings int    `json:"max_bookings,omitempty"`
+	Used        int    `json:"used,omitempty"`
+}
+
+// the plans by id and the subscriptions of the members keyed by lower case name, guarded by storageMu
+var (
+	plans         = make(map[string]models.Plan)
+	subscriptions = make(map[string][]models.Subscription)
+)
+
+func newPlanResponse(plan models.Plan) PlanResponse {
+	return PlanResponse{ID: plan.ID, Name: plan.Name, Period: plan.Period, MaxBookings: plan.MaxBookings, ClassTypes: plan.ClassTypes}
+}
+
+// Handler for creating a membership plan
+func PostCreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req PlanRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Period != models.PeriodWeek && req.Period != models.PeriodMonth {
+		http.Error(w, "period must be week or month", http.StatusBadRequest)
+		return
+	}
+	if req.MaxBookings < 0 {
+		http.Error(w, "max_bookings cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	plan := models.Plan{ID: helpers.NewID(), Name: req.Name, Period: req.Period, MaxBookings: req.MaxBookings, ClassTypes: req.ClassTypes}
+
+	storageMu.Lock()
+	plans[plan.ID] = plan
+	storageMu.Unlock()
+
+	w.Header().Set("Location", "/v1/plans/"+plan.ID)
+	helpers.WriteJSON(w, newPlanResponse(plan), http.StatusCreated)
+}
+
+// Handler for listing the membership plans sorted by name
+func GetPlans(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	list := make([]PlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		list = append(list, newPlanResponse(plan))
+	}
+	storageMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	helpers.WriteJSON(w, list, http.StatusOK)
+}
+
+// Handler for fetching a single membership plan
+func GetPlan(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	plan, found := plans[chi.URLParam(r, "id")]
+	storageMu.RUnlock()
+
+	if !found {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, newPlanResponse(plan), http.StatusOK)
+}
+
+// Handler for deleting a membership plan, plans members are subscribed to cannot be deleted
+func DeletePlan(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	if _, found := plans[id]; !found {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	}
+	for _, subscribed := range subscriptions {
+		for _, subscription := range subscribed {
+			if subscription.PlanID == id {
+				http.Error(w, fmt.Sprintf("%s is subscribed to the plan", subscription.Member), http.StatusConflict)
+				return
+			}
+		}
+	}
+	delete(plans, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for subscribing a member to a plan
+func PostCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+
+	var req SubscriptionRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		http.Error(w, "Invalid start date format", http.StatusBadRequest)
+		return
+	}
+	subscription := models.Subscription{ID: helpers.NewID(), Member: member, PlanID: req.PlanID, StartDate: helpers.NormalizeDate(startDate)}
+	if req.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			http.Error(w, "Invalid end date format", http.StatusBadRequest)
+			return
+		}
+		subscription.EndDate = helpers.NormalizeDate(endDate)
+		if subscription.EndDate.Before(subscription.StartDate) {
+			http.Error(w, "start date cannot be after end date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	if _, found := plans[req.PlanID]; !found {
+		http.Error(w, "Plan not found", http.StatusBadRequest)
+		return
+	}
+	key := strings.ToLower(member)
+	subscriptions[key] = append(subscriptions[key], subscription)
+
+	w.Header().Set("Location", "/v1/members/"+member+"/subscriptions")
+	helpers.WriteJSON(w, newSubscriptionResponse(subscription, clk.Now()), http.StatusCreated)
+}
+
+// Handler for the subscriptions of a member, with the usage of the ones active today
+func GetMemberSubscriptions(w http.ResponseWriter, r *http.Request) {
+	member := chi.URLParam(r, "id")
+	current := clk.Now()
+
+	storageMu.RLock()
+	list := make([]SubscriptionResponse, 0, len(subscriptions[strings.ToLower(member)]))
+	for _, subscription := range subscriptions[strings.ToLower(member)] {
+		list = append(list, newSubscriptionResponse(subscription, current))
+	}
+	storageMu.RUnlock()
+
+	helpers.WriteJSON(w, list, http.StatusOK)
+}
+
+// newSubscriptionResponse describes the subscription with its usage at the given time, the caller must hold storageMu
+func newSubscriptionResponse(subscription models.Subscription, at time.Time) SubscriptionResponse {
+	plan := plans[subscription.PlanID]
+	response := SubscriptionResponse{
+		ID:        subscription.ID,
+		Member:    subscription.Member,
+		PlanID:    plan.ID,
+		PlanName:  plan.Name,
+		StartDate: subscription.StartDate.Format("2006-01-02"),
+	}
+	if !subscription.EndDate.IsZero() {
+		response.EndDate = subscription.EndDate.Format("2006-01-02")
+	}
+
+	today := helpers.NormalizeDate(at)
+	if subscription.Covers(today) {
+		start, end := plan.PeriodOf(subscription.StartDate, today)
+		usage := PlanUsage{
+			PeriodStart: start.Format("2006-01-02"),
+			PeriodEnd:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+			Used:        planBookings(bookings, subscription, start, end),
+		}
+		if plan.MaxBookings > 0 {
+			remaining := max(plan.MaxBookings-usage.Used, 0)
+			usage.Remaining = &remaining
+		}
+		response.Usage = &usage
+	}
+	return response
+}
+
+// planBookings counts the active bookings made under the subscription for classes between start and end (excluded)
+func planBookings(roster map[string][]models.Booking, subscription models.Subscription, start, end time.Time) int {
+	used := 0
+	for _, booked := range roster {
+		for _, booking := range booked {
+			if booking.SubscriptionID == subscription.ID && booking.Active() && !booking.Date.Before(start) && booking.Date.Before(end) {
+				used++
+			}
+		}
+	}
+	return used
+}
+
+// checkPlan makes sure the plan of the subscription covers the booking of the class given the bookings in roster
+func checkPlan(roster map[string][]models.Booking, plan models.Plan, subscription models.Subscription, class models.Class, booking models.Booking) error {
+	if !plan.Allows(class) {
+		message := fmt.Sprintf("Your %s plan does not include %s", plan.Name, class.ClassName)
+		return &requestError{
+			status:  http.StatusForbidden,
+			message: message,
+			reason:  metrics.RejectNotEntitled,
+			problem: EntitlementProblem{
+				ProblemDetails: helpers.NewProblem(http.StatusForbidden, CodeClassNotInPlan, message),
+				PlanID:         plan.ID,
+			},
+		}
+	}
+	if plan.MaxBookings == 0 {
+		return nil
+	}
+
+	start, end := plan.PeriodOf(subscription.StartDate, booking.Date)
+	used := planBookings(roster, subscription, start, end)
+	if used < plan.MaxBookings {
+		return nil
+	}
+	message := fmt.Sprintf("Your %s plan allows %d bookings per %s and you already made %d", plan.Name, plan.MaxBookings, plan.Period, used)
+	return &requestError{
+		status:  http.StatusForbidden,
+		message: message,
+		reason:  metrics.RejectNotEntitled,
+		problem: EntitlementProblem{
+			ProblemDetails: helpers.NewProblem(http.StatusForbidden, CodePlanLimitReached, message),
+			PlanID:         plan.ID,
+			PeriodStart:    start.Format("2006-01-02"),
+			PeriodEnd:      end.AddDate(0, 0, -1).Format("2006-01-02"),
+			MaxBookings:    plan.MaxBookings,
+			Used:           used,
+		},
+	}
+}
+
+// entitleBooking decides how the booking of the class is paid for and returns the booking with how it is paid.
+// Members with a subscription active on the date of the class book under their plan. When no plan covers the booking
+// it is paid with credits if the class has a credit cost, or with a drop-in payment if the class has a drop-in price.
+// The seat of a drop-in booking is held while the payment is pending. When the class needs credits the member does not
+// have, they are told why their plan does not cover the booking. Free classes are booked by everyone like before,
+// whether their plan covers them or not.
+// The caller must hold storageMu when passing our bookings and creditLedger.
+func entitleBooking(roster map[string][]models.Booking, ledger map[string][]models.CreditEntry, class models.Class, booking models.Booking) (models.Booking, error) {
+	var refusal error
+	for _, subscription := range subscriptions[strings.ToLower(booking.Name)] {
+		if !subscription.Covers(booking.Date) {
+			continue
+		}
+		err := checkPlan(roster, plans[subscription.PlanID], subscription, class, booking)
+		if err == nil {
+			booking.SubscriptionID = subscription.ID
+			return booking, nil
+		}
+		if refusal == nil {
+			refusal = err
+		}
+	}
+
+	if class.CreditCost > 0 {
+		err := debitCredits(ledger, class, booking)
+		if err == nil {
+			return booking, nil
+		}
+		if class.DropInPriceCents == 0 {
+			// the plan tells the member more than the missing credits
+			if refusal != nil {
+				return booking, refusal
+			}
+			return booking, err
+		}
+	}
+
+	if class.DropInPriceCents > 0 {
+		if gateway == nil {
+			return booking, paymentsUnavailable()
+		}
+		booking.Status = models.StatusPendingPayment
+		booking.PaymentStatus = models.PaymentPending
+		booking.AmountCents = class.DropInPriceCents
+		return booking, nil
+	}
+	// a plan does not make a member worse off than someone without one
+	return booking, nil
+}
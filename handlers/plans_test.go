@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// a yoga class every day of October and a member subscribed to a plan of two yoga classes per week from the 1st
+func setUpPlanStorage(t *testing.T) time.Time {
+	first := time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)
+	rooms = make(map[string]models.Room)
+	instructors = make(map[string]models.Instructor)
+	classStorage = make(map[time.Time][]models.Class)
+	addClass(classStorage, models.Class{ID: "c1", ClassName: "Yoga", StartDate: first, EndDate: first.AddDate(0, 0, 30), Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour})
+	bookings = make(map[string][]models.Booking)
+	creditLedger = make(map[string][]models.CreditEntry)
+	strikes = make(map[string][]time.Time)
+	suspensions = make(map[string]time.Time)
+
+	plans = map[string]models.Plan{"p1": {ID: "p1", Name: "Twice a week", Period: models.PeriodWeek, MaxBookings: 2, ClassTypes: []string{"yoga"}}}
+	subscriptions = map[string][]models.Subscription{"meher": {{ID: "s1", Member: "Meher", PlanID: "p1", StartDate: first}}}
+	t.Cleanup(func() {
+		plans = make(map[string]models.Plan)
+		subscriptions = make(map[string][]models.Subscription)
+	})
+	// booking windows are open for a week, so the first two weeks can be booked
+	useFakeClock(t, first.Add(-time.Hour))
+	return first
+}
+
+func postPlanBooking(t *testing.T, date string) (*httptest.ResponseRecorder, EntitlementProblem) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(`{"name":"Meher","date":"`+date+`"}`))
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreateBooking).ServeHTTP(rec, req)
+
+	var problem EntitlementProblem
+	if rec.Header().Get("Content-Type") == "application/problem+json" {
+		json.Unmarshal(rec.Body.Bytes(), &problem)
+	}
+	return rec, problem
+}
+
+// periods are counted from the start of the subscription
+func TestPlanPeriodOf(t *testing.T) {
+	start := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period     string
+		date       time.Time
+		start, end string
+	}{
+		{models.PeriodMonth, time.Date(2024, time.February, 20, 0, 0, 0, 0, time.UTC), "2024-02-15", "2024-03-15"},
+		{models.PeriodMonth, time.Date(2024, time.March, 14, 0, 0, 0, 0, time.UTC), "2024-02-15", "2024-03-15"},
+		{models.PeriodMonth, start, "2024-01-15", "2024-02-15"},
+		{models.PeriodWeek, time.Date(2024, time.January, 29, 0, 0, 0, 0, time.UTC), "2024-01-29", "2024-02-05"},
+		{models.PeriodWeek, time.Date(2024, time.January, 28, 0, 0, 0, 0, time.UTC), "2024-01-22", "2024-01-29"},
+	}
+	for _, test := range tests {
+		periodStart, periodEnd := models.Plan{Period: test.period}.PeriodOf(start, test.date)
+		if periodStart.Format("2006-01-02") != test.start || periodEnd.Format("2006-01-02") != test.end {
+			t.Errorf("%s %s: expected %s to %s, got %s to %s", test.period, test.date.Format("2006-01-02"),
+				test.start, test.end, periodStart.Format("2006-01-02"), periodEnd.Format("2006-01-02"))
+		}
+	}
+}
+
+// a monthly plan starting at the end of a month renews on the last day of the shorter months without drifting
+func TestPlanPeriodOf_MonthEnd(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		date       time.Time
+		start, end string
+	}{
+		{time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC), "2024-01-31", "2024-02-29"},
+		{time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC), "2024-02-29", "2024-03-31"},
+		{time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC), "2024-04-30", "2024-05-31"},
+		{time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), "2025-02-28", "2025-03-31"},
+	}
+	for _, test := range tests {
+		periodStart, periodEnd := models.Plan{Period: models.PeriodMonth}.PeriodOf(start, test.date)
+		if periodStart.Format("2006-01-02") != test.start || periodEnd.Format("2006-01-02") != test.end {
+			t.Errorf("%s: expected %s to %s, got %s to %s", test.date.Format("2006-01-02"),
+				test.start, test.end, periodStart.Format("2006-01-02"), periodEnd.Format("2006-01-02"))
+		}
+	}
+}
+
+// members cannot book more than their plan allows per period, cancelled bookings dont count
+func TestPostCreateBooking_PlanLimit(t *testing.T) {
+	setUpPlanStorage(t)
+	for date := range classStorage {
+		classStorage[date][0].CreditCost = 1
+	}
+
+	for _, date := range []string{"2024-10-01", "2024-10-02"} {
+		if rec, _ := postPlanBooking(t, date); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201 for %s, got %d: %s", date, rec.Code, rec.Body.String())
+		}
+	}
+	if booking := bookings["2024-10-01"][0]; booking.SubscriptionID != "s1" {
+		t.Errorf("expected the booking to be made under the subscription, got %+v", booking)
+	}
+
+	rec, problem := postPlanBooking(t, "2024-10-03")
+	if rec.Code != http.StatusForbidden || problem.Code != CodePlanLimitReached || problem.Used != 2 ||
+		problem.PeriodStart != "2024-10-01" || problem.PeriodEnd != "2024-10-07" {
+		t.Errorf("expected the plan limit to be reached, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the next week is a new period
+	if rec, _ := postPlanBooking(t, "2024-10-08"); rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201 in the next period, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	bookings["2024-10-02"][0].Status = models.StatusCancelled
+	if rec, _ := postPlanBooking(t, "2024-10-03"); rec.Code != http.StatusCreated {
+		t.Errorf("expected the cancelled booking to give the entitlement back, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// plans only cover their class types, and only while the subscription is active
+func TestPostCreateBooking_PlanCoverage(t *testing.T) {
+	first := setUpPlanStorage(t)
+	classStorage[first][0].ClassName = "Spin"
+	classStorage[first][0].CreditCost = 1
+
+	rec, problem := postPlanBooking(t, "2024-10-01")
+	if rec.Code != http.StatusForbidden || problem.Code != CodeClassNotInPlan || problem.PlanID != "p1" {
+		t.Errorf("expected the class not to be covered, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// members without an active subscription book like before
+	classStorage[first][0].CreditCost = 0
+	subscriptions["meher"][0].StartDate = first.AddDate(0, 0, 1)
+	if rec, _ := postPlanBooking(t, "2024-10-01"); rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201 before the subscription starts, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// free classes are booked like by members without a plan, even when the plan does not cover them
+func TestPostCreateBooking_PlanFreeClass(t *testing.T) {
+	first := setUpPlanStorage(t)
+	classStorage[first][0].ClassName = "Spin"
+
+	if rec, _ := postPlanBooking(t, "2024-10-01"); rec.Code != http.StatusCreated {
+		t.Errorf("expected the class outside the plan to be booked, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, date := range []string{"2024-10-02", "2024-10-03", "2024-10-04"} {
+		if rec, _ := postPlanBooking(t, date); rec.Code != http.StatusCreated {
+			t.Errorf("expected status 201 for %s once the plan limit is reached, got %d: %s", date, rec.Code, rec.Body.String())
+		}
+	}
+	if bookings["2024-10-01"][0].SubscriptionID != "" || bookings["2024-10-02"][0].SubscriptionID != "s1" || bookings["2024-10-04"][0].SubscriptionID != "" {
+		t.Errorf("expected only the covered bookings to use the plan, got %+v", bookings)
+	}
+}
+
+// bookings the plan does not cover are paid with credits for classes with a credit cost
+func TestPostCreateBooking_PlanFallsBackToCredits(t *testing.T) {
+	first := setUpPlanStorage(t)
+	for date := range classStorage {
+		classStorage[date][0].CreditCost = 1
+	}
+	plans["p1"] = models.Plan{ID: "p1", Name: "Once a week", Period: models.PeriodWeek, MaxBookings: 1}
+
+	postPlanBooking(t, "2024-10-01")
+	if rec, problem := postPlanBooking(t, "2024-10-02"); rec.Code != http.StatusForbidden || problem.Code != CodePlanLimitReached {
+		t.Errorf("expected the plan refusal without credits, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	creditLedger["meher"] = []models.CreditEntry{{ID: "pack", Member: "Meher", Kind: models.CreditPurchase, Credits: 1, ExpiresAt: first.AddDate(0, 1, 0)}}
+	if rec, _ := postPlanBooking(t, "2024-10-02"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected the booking to be paid with credits, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if booking := bookings["2024-10-02"][0]; booking.SubscriptionID != "" || remainingCredits(creditLedger["meher"])["pack"] != 0 {
+		t.Errorf("expected the booking to use the credit, got %+v", booking)
+	}
+}
+
+// plans are created and subscribed to through the API, and the usage of the current period is reported
+func TestPlansAndSubscriptions(t *testing.T) {
+	setUpPlanStorage(t)
+	plans = make(map[string]models.Plan)
+	subscriptions = make(map[string][]models.Subscription)
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreatePlan).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"Unlimited","period":"year"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown period, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(PostCreatePlan).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"Eight a month","period":"month","max_bookings":8}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var plan PlanResponse
+	json.Unmarshal(rec.Body.Bytes(), &plan)
+
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/members/Meher/subscriptions", strings.NewReader(`{"plan_id":"`+plan.ID+`","start_date":"2024-09-15"}`)), "id", "Meher")
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(PostCreateSubscription).ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec, _ := postPlanBooking(t, "2024-10-01"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodGet, "/members/meher/subscriptions", nil), "id", "meher")
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetMemberSubscriptions).ServeHTTP(rec, req)
+	var list []SubscriptionResponse
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Usage == nil || list[0].Usage.Used != 1 || *list[0].Usage.Remaining != 7 ||
+		list[0].Usage.PeriodStart != "2024-09-15" || list[0].Usage.PeriodEnd != "2024-10-14" {
+		t.Errorf("unexpected subscriptions %s", rec.Body.String())
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodDelete, "/plans/"+plan.ID, nil), "id", plan.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(DeletePlan).ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a plan in use, got %d", rec.Code)
+	}
+}
+
+// the subscription usage is not reported once the subscription ended
+func TestNewSubscriptionResponse_Ended(t *testing.T) {
+	first := setUpPlanStorage(t)
+	subscription := models.Subscription{ID: "s1", Member: "Meher", PlanID: "p1", StartDate: first, EndDate: first.AddDate(0, 0, 6)}
+
+	if response := newSubscriptionResponse(subscription, helpers.NormalizeDate(first.AddDate(0, 0, 7))); response.Usage != nil || response.EndDate != "2024-10-07" {
+		t.Errorf("unexpected subscription %+v", response)
+	}
+}
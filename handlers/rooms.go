@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/go-chi/chi"
+)
+
+// struct to hold payload for creating or updating a room
+type RoomRequest struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+// RoomResponse is how a room is shown by the API
+type RoomResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+// rooms are stored by id and guarded by storageMu like the classes they host
+var rooms = make(map[string]models.Room)
+
+func newRoomResponse(room models.Room) RoomResponse {
+	return RoomResponse{ID: room.ID, Name: room.Name, Capacity: room.Capacity}
+}
+
+// decodeRoomRequest reads and validates the payload of the room endpoints
+func decodeRoomRequest(w http.ResponseWriter, r *http.Request) (RoomRequest, bool) {
+	var req RoomRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return req, false
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	if req.Capacity < 0 {
+		http.Error(w, "capacity cannot be negative", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// Handler for creating a room
+func PostCreateRoom(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRoomRequest(w, r)
+	if !ok {
+		return
+	}
+
+	room := models.Room{ID: helpers.NewID(), Name: req.Name, Capacity: req.Capacity}
+
+	storageMu.Lock()
+	rooms[room.ID] = room
+	storageMu.Unlock()
+
+	w.Header().Set("Location", "/v1/rooms/"+room.ID)
+	helpers.WriteJSON(w, newRoomResponse(room), http.StatusCreated)
+}
+
+// Handler for listing the rooms sorted by name
+func GetRooms(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	list := make([]RoomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		list = append(list, newRoomResponse(room))
+	}
+	storageMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	helpers.WriteJSON(w, list, http.StatusOK)
+}
+
+// Handler for fetching a single room
+func GetRoom(w http.ResponseWriter, r *http.Request) {
+	storageMu.RLock()
+	room, found := rooms[chi.URLParam(r, "id")]
+	storageMu.RUnlock()
+
+	if !found {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, newRoomResponse(room), http.StatusOK)
+}
+
+// Handler for replacing the details of a room, the capacity cannot shrink below the capacity of its classes
+func PutRoom(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRoomRequest(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	if _, found := rooms[id]; !found {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	room := models.Room{ID: id, Name: req.Name, Capacity: req.Capacity}
+	for date, sessions := range classStorage {
+		for _, class := range sessions {
+			if class.RoomID == id && class.Capacity > room.Capacity {
+				http.Error(w, fmt.Sprintf("%s on %s has a capacity of %d which does not fit in the room",
+					class.ClassName, date.Format("2006-01-02"), class.Capacity), http.StatusConflict)
+				return
+			}
+		}
+	}
+	rooms[id] = room
+	helpers.WriteJSON(w, newRoomResponse(room), http.StatusOK)
+}
+
+// Handler for deleting a room, rooms which still host a class cannot be deleted
+func DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	if _, found := rooms[id]; !found {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	for date, sessions := range classStorage {
+		for _, class := range sessions {
+			if class.RoomID == id {
+				http.Error(w, fmt.Sprintf("Room hosts the class on %s", date.Format("2006-01-02")), http.StatusConflict)
+				return
+			}
+		}
+	}
+	delete(rooms, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkRoomCapacity makes sure the room of the class exists and fits the capacity of the class.
+// The caller must hold storageMu.
+func checkRoomCapacity(class models.Class) error {
+	if class.RoomID == "" {
+		return nil
+	}
+	room, found := rooms[class.RoomID]
+	if !found {
+		return &requestError{status: http.StatusBadRequest, message: "Room not found"}
+	}
+	if class.Capacity > room.Capacity {
+		return &requestError{
+			status:  http.StatusBadRequest,
+			message: fmt.Sprintf("capacity cannot exceed the capacity of %s (%d)", room.Name, room.Capacity),
+		}
+	}
+	return nil
+}
+
+// checkRoomAvailable makes sure no other class uses the room of the class on date at the same time.
+// Classes without a room share the studio, so they cannot overlap each other either.
+// The caller must hold storageMu when passing our classStorage.
+func checkRoomAvailable(classes map[time.Time][]models.Class, class models.Class, date time.Time) error {
+	for otherDate, sessions := range classes {
+		for _, other := range sessions {
+			if other.RoomID != class.RoomID || (otherDate.Equal(date) && other.ID == class.ID) {
+				continue
+			}
+			if !class.Overlaps(date, other, otherDate) {
+				continue
+			}
+			if class.RoomID == "" {
+				return &requestError{
+					status:  http.StatusConflict,
+					message: fmt.Sprintf("Class already exists on %v", otherDate.Format("2006-01-02")),
+				}
+			}
+			return &requestError{
+				status: http.StatusConflict,
+				message: fmt.Sprintf("%s is already used by %s on %s at that time",
+					rooms[class.RoomID].Name, other.ClassName, otherDate.Format("2006-01-02")),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// two rooms and a 9:00 yoga class in Studio A on the 2nd of October
+func setUpRoomStorage() time.Time {
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+	rooms = map[string]models.Room{
+		"r1": {ID: "r1", Name: "Studio A", Capacity: 20},
+		"r2": {ID: "r2", Name: "Studio B", Capacity: 8},
+	}
+	instructors = make(map[string]models.Instructor)
+	classStorage = map[time.Time][]models.Class{
+		date: {{ID: "c1", ClassName: "Yoga", StartDate: date, EndDate: date, Capacity: 15, StartTime: 9 * time.Hour, Duration: time.Hour, RoomID: "r1"}},
+	}
+	bookings = make(map[string][]models.Booking)
+	strikes = make(map[string][]time.Time)
+	suspensions = make(map[string]time.Time)
+	return date
+}
+
+// creating a room should return it with a location, and it should be listed and fetched afterwards
+func TestRoomsCRUD(t *testing.T) {
+	rooms = make(map[string]models.Room)
+	classStorage = make(map[time.Time][]models.Class)
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreateRoom).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rooms", strings.NewReader(`{"name":"Studio A","capacity":20}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created RoomResponse
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.ID == "" || created.Capacity != 20 || rec.Header().Get("Location") != "/v1/rooms/"+created.ID {
+		t.Fatalf("unexpected room %+v at %q", created, rec.Header().Get("Location"))
+	}
+
+	req := withURLParam(httptest.NewRequest(http.MethodPut, "/rooms/"+created.ID, strings.NewReader(`{"name":"Big Studio","capacity":25}`)), "id", created.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(PutRoom).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetRooms).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rooms", nil))
+	var list []RoomResponse
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Name != "Big Studio" || list[0].Capacity != 25 {
+		t.Errorf("unexpected rooms %+v", list)
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodDelete, "/rooms/"+created.ID, nil), "id", created.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(DeleteRoom).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rec.Code)
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodGet, "/rooms/"+created.ID, nil), "id", created.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetRoom).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", rec.Code)
+	}
+}
+
+// a room hosting a class cannot shrink below the capacity of the class or be deleted
+func TestRoomInUse(t *testing.T) {
+	setUpRoomStorage()
+
+	req := withURLParam(httptest.NewRequest(http.MethodPut, "/rooms/r1", strings.NewReader(`{"name":"Studio A","capacity":10}`)), "id", "r1")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PutRoom).ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when shrinking the room, got %d", rec.Code)
+	}
+
+	req = withURLParam(httptest.NewRequest(http.MethodDelete, "/rooms/r1", nil), "id", "r1")
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(DeleteRoom).ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when deleting the room, got %d", rec.Code)
+	}
+}
+
+// a class cannot have more places than its room, and the room must exist
+func TestAddClass_RoomCapacity(t *testing.T) {
+	date := setUpRoomStorage().AddDate(0, 0, 1)
+
+	class := models.Class{ClassName: "Pilates", StartDate: date, EndDate: date, Capacity: 10, StartTime: 9 * time.Hour, Duration: time.Hour, RoomID: "r2"}
+	if _, err := addClass(classStorage, class); err == nil || err.Error() != "capacity cannot exceed the capacity of Studio B (8)" {
+		t.Errorf("expected the capacity to be rejected, got %v", err)
+	}
+
+	class.RoomID = "unknown"
+	if _, err := addClass(classStorage, class); err == nil || err.Error() != "Room not found" {
+		t.Errorf("expected an unknown room to be rejected, got %v", err)
+	}
+}
+
+// classes conflict when they overlap in the same room, other rooms can be used at the same time
+func TestAddClass_RoomConflict(t *testing.T) {
+	date := setUpRoomStorage()
+
+	class := models.Class{ClassName: "Pilates", StartDate: date, EndDate: date, Capacity: 8, StartTime: 9*time.Hour + 30*time.Minute, Duration: time.Hour, RoomID: "r1"}
+	if _, err := addClass(classStorage, class); err == nil || err.Error() != "Studio A is already used by Yoga on 2024-10-02 at that time" {
+		t.Errorf("expected a room conflict, got %v", err)
+	}
+
+	class.RoomID = "r2"
+	if _, err := addClass(classStorage, class); err != nil {
+		t.Fatalf("expected the class to be added in the other room, got %v", err)
+	}
+
+	class.StartTime, class.RoomID = 10*time.Hour, "r1"
+	if _, err := addClass(classStorage, class); err != nil {
+		t.Fatalf("expected the class to be added after the yoga class, got %v", err)
+	}
+
+	sessions := classStorage[date]
+	if len(sessions) != 3 || sessions[0].ClassName != "Yoga" || sessions[1].RoomID != "r2" || sessions[2].StartTime != 10*time.Hour {
+		t.Errorf("expected three sessions sorted by start time, got %+v", sessions)
+	}
+}
+
+// members pick the class with class_id when several run on the same day
+func TestPostCreateBooking_SeveralClasses(t *testing.T) {
+	date := setUpRoomStorage()
+	useFakeClock(t, date.Add(-12*time.Hour))
+	classStorage[date] = append(classStorage[date], models.Class{
+		ID: "c2", ClassName: "Pilates", StartDate: date, EndDate: date, Capacity: 8, StartTime: 9 * time.Hour, Duration: time.Hour, RoomID: "r2",
+	})
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a class id, got %d", rec.Code)
+	}
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","class_id":"unknown"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown class, got %d", rec.Code)
+	}
+
+	for _, id := range []string{"c2", "c1"} {
+		if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","class_id":"`+id+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201 for %s, got %d: %s", id, rec.Code, rec.Body.String())
+		}
+	}
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","class_id":"c2"}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a second booking of the same class, got %d", rec.Code)
+	}
+
+	booked := bookings["2024-10-02"]
+	if len(booked) != 2 || booked[0].ClassID != "c2" || booked[1].ClassID != "c1" {
+		t.Errorf("expected a booking for each class, got %+v", booked)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// findClass returns the class with the id on the date and its index in the sessions of that day.
+// Without an id the class is only found when it is the only one that day.
+func findClass(classes map[time.Time][]models.Class, date time.Time, classID string) (models.Class, int, error) {
+	sessions := classes[date]
+	if len(sessions) == 0 {
+		return models.Class{}, -1, &requestError{status: http.StatusBadRequest, message: "We don't have a class on this day", reason: metrics.RejectNoClass}
+	}
+
+	if classID == "" {
+		if len(sessions) > 1 {
+			return models.Class{}, -1, &requestError{
+				status:  http.StatusBadRequest,
+				message: "There are several classes on this day, please pick one with class_id",
+			}
+		}
+		return sessions[0], 0, nil
+	}
+
+	for i, class := range sessions {
+		if class.ID == classID {
+			return class, i, nil
+		}
+	}
+	return models.Class{}, -1, &requestError{status: http.StatusBadRequest, message: "We don't have this class on this day", reason: metrics.RejectNoClass}
+}
+
+// addSession adds the session of the class on the date, keeping the sessions of the day sorted by start time
+func addSession(classes map[time.Time][]models.Class, date time.Time, class models.Class) {
+	sessions := append(classes[date], class)
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].StartTime < sessions[j].StartTime })
+	classes[date] = sessions
+}
+
+// removeClass drops the sessions of the class from classes, the caller must hold storageMu when passing our classStorage
+func removeClass(classes map[time.Time][]models.Class, classID string) {
+	for date, sessions := range classes {
+		kept := sessions[:0]
+		for _, session := range sessions {
+			if session.ID != classID {
+				kept = append(kept, session)
+			}
+		}
+		if len(kept) == 0 {
+			delete(classes, date)
+		} else {
+			classes[date] = kept
+		}
+	}
+}
+
+// bookingClass returns the class of a booking, the caller must hold storageMu
+func bookingClass(booking models.Booking) (models.Class, bool) {
+	for _, class := range classStorage[booking.Date] {
+		if class.ID == booking.ClassID {
+			return class, true
+		}
+	}
+	return models.Class{}, false
+}
+
+// classRoster returns the bookings of the day which are for the class
+func classRoster(booked []models.Booking, class models.Class) []models.Booking {
+	roster := make([]models.Booking, 0, len(booked))
+	for _, booking := range booked {
+		if booking.ClassID == class.ID {
+			roster = append(roster, booking)
+		}
+	}
+	return roster
+}
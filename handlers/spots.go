@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// assignSpot returns the spot a new booking of the class gets given the active bookings of the session.
+// The preferred spot is used when it is free, without a preference the first free spot is picked.
+// Classes without numbered spots always give zero.
+func assignSpot(class models.Class, active []models.Booking, preferred int) (int, error) {
+	if class.Spots == 0 {
+		if preferred != 0 {
+			return 0, &requestError{status: http.StatusBadRequest, message: "This class does not have numbered spots"}
+		}
+		return 0, nil
+	}
+	if preferred < 0 || preferred > class.Spots {
+		return 0, &requestError{status: http.StatusBadRequest, message: fmt.Sprintf("spot must be between 1 and %d", class.Spots)}
+	}
+
+	free := freeSpots(class, active)
+	if preferred == 0 {
+		if len(free) == 0 {
+			return 0, &requestError{status: http.StatusConflict, message: "Class is full", reason: metrics.RejectFull}
+		}
+		return free[0], nil
+	}
+	for _, spot := range free {
+		if spot == preferred {
+			return spot, nil
+		}
+	}
+	return 0, &requestError{
+		status:  http.StatusConflict,
+		message: fmt.Sprintf("Spot %d is already taken", preferred),
+		reason:  metrics.RejectSpotTaken,
+	}
+}
+
+// freeSpots returns the spots of the class nobody holds in the active bookings of a session, in order
+func freeSpots(class models.Class, active []models.Booking) []int {
+	taken := make(map[int]bool, len(active))
+	for _, booking := range active {
+		taken[booking.Spot] = true
+	}
+	free := make([]int, 0, class.Spots)
+	for spot := 1; spot <= class.Spots; spot++ {
+		if !taken[spot] {
+			free = append(free, spot)
+		}
+	}
+	return free
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+)
+
+// a reformer class with four numbered reformers for three members on the 2nd of October
+func setUpSpotStorage(t *testing.T) time.Time {
+	date := setUpRoomStorage()
+	classStorage[date][0].Capacity, classStorage[date][0].Spots = 3, 4
+	useFakeClock(t, date.Add(-12*time.Hour))
+	return date
+}
+
+// members get the spot they ask for or the first free one
+func TestPostCreateBooking_Spots(t *testing.T) {
+	setUpSpotStorage(t)
+
+	for _, body := range []string{
+		`{"name":"Meher","date":"2024-10-02","spot":2}`,
+		`{"name":"Ravi","date":"2024-10-02"}`,
+		`{"name":"Anu","date":"2024-10-02"}`,
+	} {
+		if rec, _ := postBooking(t, body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	spots := map[string]int{}
+	for _, booking := range bookings["2024-10-02"] {
+		spots[booking.Name] = booking.Spot
+	}
+	if spots["Meher"] != 2 || spots["Ravi"] != 1 || spots["Anu"] != 3 {
+		t.Errorf("unexpected spots %v", spots)
+	}
+}
+
+// a spot can only be held by one member, cancelling a booking frees its spot
+func TestPostCreateBooking_SpotTaken(t *testing.T) {
+	setUpSpotStorage(t)
+
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","spot":4}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02","spot":4}`)
+	if rec.Code != http.StatusConflict || rec.Body.String() != "Spot 4 is already taken\n" {
+		t.Errorf("expected the spot to be taken, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	bookings["2024-10-02"][0].Status = models.StatusCancelled
+	if rec, _ := postBooking(t, `{"name":"Ravi","date":"2024-10-02","spot":4}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected the spot of the cancelled booking to be free, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// spots outside of the spot map and spots in classes without one are rejected
+func TestPostCreateBooking_InvalidSpot(t *testing.T) {
+	date := setUpSpotStorage(t)
+
+	for _, body := range []string{
+		`{"name":"Meher","date":"2024-10-02","spot":5}`,
+		`{"name":"Meher","date":"2024-10-02","spot":-1}`,
+	} {
+		if rec, _ := postBooking(t, body); rec.Code != http.StatusBadRequest || rec.Body.String() != "spot must be between 1 and 4\n" {
+			t.Errorf("%s: expected status 400, got %d: %s", body, rec.Code, rec.Body.String())
+		}
+	}
+
+	classStorage[date][0].Spots = 0
+	if rec, _ := postBooking(t, `{"name":"Meher","date":"2024-10-02","spot":1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a class without spots, got %d", rec.Code)
+	}
+}
+
+// the roster is sorted by spot and the schedule lists the spots still free
+func TestSpotsInRosterAndSchedule(t *testing.T) {
+	date := setUpSpotStorage(t)
+	bookings["2024-10-02"] = []models.Booking{
+		{ClassID: "c1", Name: "Meher", Date: date, Spot: 3},
+		{ClassID: "c1", Name: "Ravi", Date: date, Spot: 1},
+	}
+
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(GetExportRosters).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export/rosters", nil))
+	var rosters []ExportRoster
+	if err := json.Unmarshal(rec.Body.Bytes(), &rosters); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if len(rosters) != 1 || len(rosters[0].Attendees) != 2 || rosters[0].Attendees[0].Name != "Ravi" || rosters[0].Attendees[1].Spot != 3 {
+		t.Errorf("unexpected rosters %+v", rosters)
+	}
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetClasses).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/classes", nil))
+	var sessions []ClassSession
+	json.Unmarshal(rec.Body.Bytes(), &sessions)
+	if len(sessions) != 1 || len(sessions[0].FreeSpots) != 2 || sessions[0].FreeSpots[0] != 2 || sessions[0].FreeSpots[1] != 4 {
+		t.Errorf("unexpected sessions %+v", sessions)
+	}
+}
+
+// a class needs a spot for every member it can take
+func TestNewClass_Spots(t *testing.T) {
+	req := CreateClassRequest{ClassName: "Reformer", StartDate: "2024-10-01", EndDate: "2024-10-01", Capacity: 8, Spots: 6}
+	if _, err := newClass(req); err == nil || err.Error() != "spots cannot be fewer than the capacity" {
+		t.Errorf("expected too few spots to be rejected, got %v", err)
+	}
+
+	req.Spots = 8
+	if class, err := newClass(req); err != nil || class.Spots != 8 {
+		t.Errorf("expected a class with 8 spots, got %+v, %v", class, err)
+	}
+}
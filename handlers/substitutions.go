@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+	"github.com/go-chi/chi"
+)
+
+// struct to hold payload for substituting the instructor of one or more sessions
+type SubstitutionRequest struct {
+	InstructorID string   `json:"instructor_id"`
+	Dates        []string `json:"dates"`
+	Reason       string   `json:"reason,omitempty" validate:"optional"`
+	// ClassID picks the class on dates which have several
+	ClassID string `json:"class_id,omitempty" validate:"optional"`
+}
+
+// SubstitutedSession describes the change made to a session by a substitution
+type SubstitutedSession struct {
+	ClassID              string `json:"class_id"`
+	Date                 string `json:"date"`
+	ClassName            string `json:"class_name"`
+	PreviousInstructorID string `json:"previous_instructor_id,omitempty"`
+	InstructorID         string `json:"instructor_id"`
+	// Notified is the number of enrolled members who are notified
+	Notified int `json:"notified"`
+}
+
+// SubstitutionResponse is the response of the substitution endpoint
+type SubstitutionResponse struct {
+	Message  string               `json:"message"`
+	Sessions []SubstitutedSession `json:"sessions"`
+}
+
+// InstructorChangeResponse is an entry of the instructor history of a session
+type InstructorChangeResponse struct {
+	ClassID          string    `json:"class_id"`
+	Date             string    `json:"date"`
+	ClassName        string    `json:"class_name"`
+	FromInstructorID string    `json:"from_instructor_id,omitempty"`
+	ToInstructorID   string    `json:"to_instructor_id"`
+	Reason           string    `json:"reason,omitempty"`
+	ChangedAt        time.Time `json:"changed_at"`
+}
+
+// instructorHistory records every change of instructor in the order they were made, guarded by storageMu
+var instructorHistory []models.InstructorChange
+
+// notifications queues the messages to members, RunNotifications delivers them
+var notifications = notify.NewQueue(notify.LogNotifier{}, 1024)
+
+// SetNotifier replaces the notifier used to deliver the messages to members, it must be called before RunNotifications.
+// Messages which are still queued are dropped.
+func SetNotifier(n notify.Notifier) {
+	notifications = notify.NewQueue(n, 1024)
+}
+
+// RunNotifications delivers the queued notifications until the context is done
+func RunNotifications(ctx context.Context) {
+	notifications.Run(ctx)
+}
+
+// Handler for assigning a substitute instructor to one or more sessions.
+// Either every session is changed or none is, the members enrolled in the changed sessions are notified from the class.changed events.
+func PostSubstituteInstructor(w http.ResponseWriter, r *http.Request) {
+	var req SubstitutionRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Dates) == 0 {
+		http.Error(w, "Missing or invalid value for field: Dates", http.StatusBadRequest)
+		return
+	}
+
+	dates := make([]time.Time, 0, len(req.Dates))
+	seen := make(map[time.Time]bool)
+	for _, raw := range req.Dates {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			http.Error(w, "invalid date format", http.StatusBadRequest)
+			return
+		}
+		date := helpers.NormalizeDate(parsed)
+		if !seen[date] {
+			seen[date] = true
+			dates = append(dates, date)
+		}
+	}
+
+	current := clk.Now()
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	substitute, found := instructors[req.InstructorID]
+	if !found {
+		http.Error(w, "Instructor not found", http.StatusBadRequest)
+		return
+	}
+
+	// checking every session against a copy first, so that the sessions of the request are checked against each other too
+	classes, _ := copyStorage()
+	response := SubstitutionResponse{Sessions: make([]SubstitutedSession, 0, len(dates))}
+	indexes := make([]int, 0, len(dates))
+	for _, date := range dates {
+		class, i, err := findClass(classes, date, req.ClassID)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		if !current.Before(class.Start(date)) {
+			http.Error(w, fmt.Sprintf("The class on %s already started", date.Format("2006-01-02")), http.StatusConflict)
+			return
+		}
+		if class.InstructorID == substitute.ID {
+			http.Error(w, fmt.Sprintf("%s is already teaching the class on %s", substitute.Name, date.Format("2006-01-02")), http.StatusConflict)
+			return
+		}
+
+		previous := class.InstructorID
+		class.InstructorID = substitute.ID
+		if err := checkInstructorAvailable(classes, class, date); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		classes[date][i] = class
+		indexes = append(indexes, i)
+		response.Sessions = append(response.Sessions, SubstitutedSession{
+			ClassID:              class.ID,
+			Date:                 date.Format("2006-01-02"),
+			ClassName:            class.ClassName,
+			PreviousInstructorID: previous,
+			InstructorID:         substitute.ID,
+		})
+	}
+
+	// the members are notified from the events, nothing is changed when they cannot be emitted
+	changed := make([]events.Event, 0, len(dates))
+	for n, date := range dates {
+		class := classes[date][indexes[n]]
+		enrolled := enrolledBookings(date, class)
+		response.Sessions[n].Notified = len(enrolled)
+		changed = append(changed, events.ClassChanged{
+			Meta:                 events.NewMeta(current),
+			Class:                class,
+			Date:                 date,
+			PreviousInstructorID: response.Sessions[n].PreviousInstructorID,
+			Bookings:             enrolled,
+		})
+	}
+	if err := emit(changed...); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	for n, date := range dates {
+		class := classes[date][indexes[n]]
+		classStorage[date][indexes[n]] = class
+		recordInstructorChange(date, class, response.Sessions[n].PreviousInstructorID, req.Reason, current)
+	}
+
+	response.Message = fmt.Sprintf("%s is substituting %d sessions", substitute.Name, len(dates))
+	helpers.WriteJSON(w, response, http.StatusOK)
+}
+
+// Handler for the history of the instructors of the session on a date
+func GetClassInstructorHistory(w http.ResponseWriter, r *http.Request) {
+	parsed, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		http.Error(w, "invalid date format", http.StatusBadRequest)
+		return
+	}
+	date := helpers.NormalizeDate(parsed)
+
+	storageMu.RLock()
+	found := len(classStorage[date]) > 0
+	history := []InstructorChangeResponse{}
+	for _, change := range instructorHistory {
+		if change.Date.Equal(date) {
+			history = append(history, InstructorChangeResponse{
+				ClassID:          change.ClassID,
+				Date:             change.Date.Format("2006-01-02"),
+				ClassName:        change.ClassName,
+				FromInstructorID: change.FromInstructorID,
+				ToInstructorID:   change.ToInstructorID,
+				Reason:           change.Reason,
+				ChangedAt:        change.ChangedAt,
+			})
+		}
+	}
+	storageMu.RUnlock()
+
+	if !found {
+		http.Error(w, "We don't have a class on this day", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, history, http.StatusOK)
+}
+
+// recordInstructorChange adds the change of instructor of the session to the history, the caller must hold storageMu
+func recordInstructorChange(date time.Time, class models.Class, from, reason string, at time.Time) {
+	instructorHistory = append(instructorHistory, models.InstructorChange{
+		Date:             date,
+		ClassID:          class.ID,
+		ClassName:        class.ClassName,
+		FromInstructorID: from,
+		ToInstructorID:   class.InstructorID,
+		Reason:           reason,
+		ChangedAt:        at.UTC(),
+	})
+}
+
+// enrolledBookings returns the bookings of the members enrolled in the session, the caller must hold storageMu
+func enrolledBookings(date time.Time, class models.Class) []models.Booking {
+	var enrolled []models.Booking
+	for _, booking := range classRoster(bookings[date.Format("2006-01-02")], class) {
+		if booking.Status == models.StatusBooked {
+			enrolled = append(enrolled, booking)
+		}
+	}
+	return enrolled
+}
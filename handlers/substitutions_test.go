@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/notify"
+	"github.com/MeherKandukuri/studioClasses_API/outbox"
+)
+
+// recordingNotifier keeps the messages it was asked to deliver, after failing the first failures ones
+type recordingNotifier struct {
+	messages []notify.Message
+	failures int
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("451 mail server is busy")
+	}
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func useRecordingNotifier(t *testing.T) *recordingNotifier {
+	t.Helper()
+	recorder := &recordingNotifier{}
+	SetNotifier(recorder)
+	t.Cleanup(func() { SetNotifier(notify.LogNotifier{}) })
+	return recorder
+}
+
+func substitute(body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostSubstituteInstructor).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/classes/substitutions", strings.NewReader(body)))
+	return rec
+}
+
+// the substitute takes over the sessions, the change is recorded and the enrolled members are notified
+func TestPostSubstituteInstructor(t *testing.T) {
+	date := setUpInstructorStorage()
+	instructorHistory = nil
+	bookings["2024-10-02"] = []models.Booking{
+		{ID: "b1", Name: "Meher", Date: date, Status: models.StatusBooked},
+		{ID: "b2", Name: "Ravi", Date: date, Status: models.StatusCancelled},
+	}
+	useFakeClock(t, date.Add(-24*time.Hour))
+	flushEvents()
+	recorder := useRecordingNotifier(t)
+
+	rec := substitute(`{"instructor_id":"i2","dates":["2024-10-02","2024-10-03"],"reason":"sick"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response SubstitutionResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.Sessions) != 2 || response.Sessions[0].PreviousInstructorID != "i1" || response.Sessions[0].Notified != 1 {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if classStorage[date][0].InstructorID != "i2" || classStorage[date.AddDate(0, 0, 1)][0].InstructorID != "i2" {
+		t.Errorf("expected Bruno to teach both sessions")
+	}
+
+	// only Meher is still enrolled, cancelled bookings are not notified
+	flushEvents()
+	notifications.Flush(context.Background())
+	if len(recorder.messages) != 1 || recorder.messages[0].To != "Meher" ||
+		recorder.messages[0].Body != "Bruno will teach Yoga on 2024-10-02 at 09:00 instead of Asha." ||
+		recorder.messages[0].Details.Instructor != "Bruno" || recorder.messages[0].Details.PreviousInstructor != "Asha" {
+		t.Errorf("unexpected notifications %+v", recorder.messages)
+	}
+
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/classes/2024-10-02/instructor/history", nil), "date", "2024-10-02")
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetClassInstructorHistory).ServeHTTP(rec, req)
+	var history []InstructorChangeResponse
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if len(history) != 1 || history[0].FromInstructorID != "i1" || history[0].ToInstructorID != "i2" || history[0].Reason != "sick" {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+// nothing is changed when one of the sessions cannot be substituted
+func TestPostSubstituteInstructor_AllOrNothing(t *testing.T) {
+	date := setUpInstructorStorage()
+	instructorHistory = nil
+	useFakeClock(t, date.Add(-24*time.Hour))
+	flushEvents()
+	recorder := useRecordingNotifier(t)
+
+	if rec := substitute(`{"instructor_id":"i2","dates":["2024-10-02","2024-10-09"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+	if rec := substitute(`{"instructor_id":"i1","dates":["2024-10-02"]}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for the same instructor, got %d", rec.Code)
+	}
+	if rec := substitute(`{"instructor_id":"i3","dates":["2024-10-02"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown instructor, got %d", rec.Code)
+	}
+
+	if classStorage[date][0].InstructorID != "i1" || len(instructorHistory) != 0 {
+		t.Errorf("expected nothing to change, got %+v and %+v", classStorage[date], instructorHistory)
+	}
+	flushEvents()
+	if notifications.Flush(context.Background()); len(recorder.messages) != 0 {
+		t.Errorf("expected no notifications, got %+v", recorder.messages)
+	}
+
+	// the members cannot be told about a change whose event is not saved, so it is not made
+	useOutboxStore(t, failingStore{outbox.NewMemoryStore()})
+	if rec := substitute(`{"instructor_id":"i2","dates":["2024-10-02"]}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 when the outbox fails, got %d", rec.Code)
+	}
+	if classStorage[date][0].InstructorID != "i1" || len(instructorHistory) != 0 {
+		t.Errorf("expected nothing to change, got %+v and %+v", classStorage[date], instructorHistory)
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/models"
+	"github.com/MeherKandukuri/studioClasses_API/webhooks"
+	"github.com/go-chi/chi"
+)
+
+// WebhookRequest subscribes a URL to events
+type WebhookRequest struct {
+	URL string `json:"url"`
+	// Events are the event types sent to the URL, e.g booking.created
+	Events []string `json:"events"`
+	// Secret signs the deliveries, one is generated when it is not given
+	Secret string `json:"secret,omitempty" validate:"optional"`
+}
+
+// WebhookResponse is a webhook subscription, the secret is only returned when the subscription is created
+type WebhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDeliveryResponse is an attempt to deliver an event to a webhook
+type WebhookDeliveryResponse struct {
+	ID         string    `json:"id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Succeeded  bool      `json:"succeeded"`
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// ClassEvent is the data of class events
+type ClassEvent struct {
+	ClassID         string `json:"class_id"`
+	ClassName       string `json:"class_name"`
+	StartDate       string `json:"start_date"`
+	EndDate         string `json:"end_date"`
+	StartTime       string `json:"start_time"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Capacity        int    `json:"capacity"`
+	InstructorID    string `json:"instructor_id,omitempty"`
+	RoomID          string `json:"room_id,omitempty"`
+}
+
+// BookingEvent is the data of booking events
+type BookingEvent struct {
+	BookingID string `json:"booking_id"`
+	Name      string `json:"name"`
+	ClassID   string `json:"class_id"`
+	ClassName string `json:"class_name"`
+	Date      string `json:"date"`
+	Status    string `json:"status"`
+	Spot      int    `json:"spot,omitempty"`
+	// LateCancel is set for booking.cancelled events of late cancellations
+	LateCancel bool `json:"late_cancel,omitempty"`
+}
+
+// webhookDispatcher delivers the events to the webhooks, RunWebhooks runs its worker
+var webhookDispatcher = webhooks.NewDispatcher(&http.Client{Timeout: 10 * time.Second}, webhooks.DefaultRetryPolicy(), 1024)
+
+// SetWebhookDispatcher replaces the dispatcher of the webhooks, it must be called before RunWebhooks.
+// The subscriptions and the events which are still queued are dropped.
+func SetWebhookDispatcher(d *webhooks.Dispatcher) {
+	webhookDispatcher = d
+}
+
+// RunWebhooks delivers the published events until the context is done
+func RunWebhooks(ctx context.Context) {
+	webhookDispatcher.Run(ctx)
+}
+
+// publishWebhook queues the event for the webhooks subscribed to its type, it is an asynchronous subscriber of the bus
+// acknowledging the event once the deliveries are over. It fails when the queue is full, so that the outbox retries the event.
+func publishWebhook(ctx context.Context, event events.Event, ack events.Ack) error {
+	var data any
+	switch e := event.(type) {
+	case events.ClassCreated:
+		data = newClassEvent(e.Class)
+	case events.BookingCreated:
+		data = newBookingEvent(e.Booking, e.Class)
+	case events.BookingCancelled:
+		cancelled := newBookingEvent(e.Booking, e.Class)
+		cancelled.LateCancel = e.LateCancel
+		data = cancelled
+	default:
+		ack(nil)
+		return nil
+	}
+	// the webhook keeps the id of the event, so that receivers can drop the events the outbox delivers again
+	meta := event.Metadata()
+	_, err := webhookDispatcher.Publish(webhooks.Event{ID: meta.ID, Type: event.Type(), CreatedAt: meta.At, Data: data, Key: event.Key()}, ack)
+	return err
+}
+
+func newClassEvent(class models.Class) ClassEvent {
+	return ClassEvent{
+		ClassID:         class.ID,
+		ClassName:       class.ClassName,
+		StartDate:       class.StartDate.Format("2006-01-02"),
+		EndDate:         class.EndDate.Format("2006-01-02"),
+		StartTime:       class.Start(class.StartDate).Format("15:04"),
+		DurationMinutes: int(class.Duration / time.Minute),
+		Capacity:        class.Capacity,
+		InstructorID:    class.InstructorID,
+		RoomID:          class.RoomID,
+	}
+}
+
+func newBookingEvent(booking models.Booking, class models.Class) BookingEvent {
+	return BookingEvent{
+		BookingID: booking.ID,
+		Name:      booking.Name,
+		ClassID:   booking.ClassID,
+		ClassName: class.ClassName,
+		Date:      booking.Date.Format("2006-01-02"),
+		Status:    booking.Status,
+		Spot:      booking.Spot,
+	}
+}
+
+func newWebhookResponse(subscription webhooks.Subscription) WebhookResponse {
+	return WebhookResponse{ID: subscription.ID, URL: subscription.URL, Events: subscription.Events, CreatedAt: subscription.CreatedAt}
+}
+
+// Handler for subscribing a URL to events
+func PostCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if !helpers.DecodeJSONPayload(w, r, &req) {
+		return
+	}
+	if err := helpers.CheckRequiredFields(req, []string{"checkZeroValue"}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+	if len(req.Events) == 0 {
+		http.Error(w, "events cannot be empty", http.StatusBadRequest)
+		return
+	}
+	for _, event := range req.Events {
+		if !knownEventType(event) {
+			http.Error(w, "Unknown event type: "+event, http.StatusBadRequest)
+			return
+		}
+	}
+
+	subscription := webhooks.Subscription{
+		ID:        helpers.NewID(),
+		URL:       target.String(),
+		Events:    req.Events,
+		Secret:    req.Secret,
+		CreatedAt: clk.Now().UTC(),
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = helpers.NewID() + helpers.NewID()
+	}
+	webhookDispatcher.Subscribe(subscription)
+
+	response := newWebhookResponse(subscription)
+	response.Secret = subscription.Secret
+	w.Header().Set("Location", "/v1/webhooks/"+subscription.ID)
+	helpers.WriteJSON(w, response, http.StatusCreated)
+}
+
+// Handler for listing the webhooks
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	list := []WebhookResponse{}
+	for _, subscription := range webhookDispatcher.Subscriptions() {
+		list = append(list, newWebhookResponse(subscription))
+	}
+	helpers.WriteJSON(w, list, http.StatusOK)
+}
+
+// Handler for fetching a webhook
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, found := webhookDispatcher.Subscription(chi.URLParam(r, "id"))
+	if !found {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	helpers.WriteJSON(w, newWebhookResponse(subscription), http.StatusOK)
+}
+
+// Handler for removing a webhook, the events which are being delivered to it are not retried anymore
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !webhookDispatcher.Unsubscribe(chi.URLParam(r, "id")) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for the delivery log of a webhook, the oldest attempt first
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, found := webhookDispatcher.Subscription(id); !found {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	list := []WebhookDeliveryResponse{}
+	for _, delivery := range webhookDispatcher.Deliveries(id) {
+		list = append(list, WebhookDeliveryResponse{
+			ID:         delivery.ID,
+			EventID:    delivery.EventID,
+			EventType:  delivery.EventType,
+			Attempt:    delivery.Attempt,
+			StatusCode: delivery.StatusCode,
+			Error:      delivery.Error,
+			Succeeded:  delivery.Succeeded,
+			At:         delivery.At,
+			DurationMs: delivery.Duration.Milliseconds(),
+		})
+	}
+	helpers.WriteJSON(w, list, http.StatusOK)
+}
+
+func knownEventType(eventType string) bool {
+	for _, known := range webhooks.EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
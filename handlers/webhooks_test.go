@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/webhooks"
+)
+
+// webhookReceiver verifies the signature of the deliveries it gets and records their events
+type webhookReceiver struct {
+	mu     sync.Mutex
+	secret string
+	events []webhooks.Event
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !webhooks.Verify(rec.secret, r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), body) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	var event webhooks.Event
+	json.Unmarshal(body, &event)
+	rec.mu.Lock()
+	rec.events = append(rec.events, event)
+	rec.mu.Unlock()
+}
+
+// useWebhookDispatcher publishes the events to a fresh dispatcher for the duration of the test,
+// the events other tests left on the bus go to the previous one
+func useWebhookDispatcher(t *testing.T) *webhooks.Dispatcher {
+	t.Helper()
+	flushEvents()
+	previous := webhookDispatcher
+	d := webhooks.NewDispatcher(http.DefaultClient, webhooks.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, 16)
+	SetWebhookDispatcher(d)
+	t.Cleanup(func() { SetWebhookDispatcher(previous) })
+	return d
+}
+
+func postWebhook(t *testing.T, body string) (*httptest.ResponseRecorder, WebhookResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(PostCreateWebhook).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+	var response WebhookResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response
+}
+
+// webhooks get signed deliveries of the booking events they subscribed to
+func TestWebhooks_BookingEvents(t *testing.T) {
+	setUpCancellationPolicy(t)
+	setUpHoldStorage(t)
+	d := useWebhookDispatcher(t)
+	receiver := &webhookReceiver{secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	for _, body := range []string{`{"url":"ftp://example.com","events":["booking.created"]}`, `{"url":"` + server.URL + `","events":["booking.moved"]}`} {
+		if rec, _ := postWebhook(t, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", body, rec.Code)
+		}
+	}
+	rec, webhook := postWebhook(t, `{"url":"`+server.URL+`","events":["booking.created","booking.cancelled"],"secret":"s3cret"}`)
+	if rec.Code != http.StatusCreated || webhook.Secret != "s3cret" {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	postBooking(t, `{"name":"Meher","date":"2024-10-02"}`)
+	cancelBooking(t, bookings["2024-10-02"][0].ID)
+	flushEvents()
+	if delivered := d.Flush(context.Background()); delivered != 2 {
+		t.Fatalf("expected 2 deliveries, got %d: %+v", delivered, d.Deliveries(webhook.ID))
+	}
+
+	if len(receiver.events) != 2 || receiver.events[0].Type != events.TypeBookingCreated || receiver.events[1].Type != events.TypeBookingCancelled {
+		t.Fatalf("unexpected events %+v", receiver.events)
+	}
+	data, _ := receiver.events[0].Data.(map[string]any)
+	if data["booking_id"] != bookings["2024-10-02"][0].ID || data["class_name"] != "Yoga" {
+		t.Errorf("unexpected event data %+v", data)
+	}
+
+	req := withURLParam(httptest.NewRequest(http.MethodGet, "/webhooks/"+webhook.ID+"/deliveries", nil), "id", webhook.ID)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(GetWebhookDeliveries).ServeHTTP(rec, req)
+	var deliveries []WebhookDeliveryResponse
+	json.Unmarshal(rec.Body.Bytes(), &deliveries)
+	if len(deliveries) != 2 || !deliveries[0].Succeeded || deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("unexpected delivery log %s", rec.Body.String())
+	}
+}
+
+// creating a class sends class.created, and webhooks which were removed get nothing
+func TestWebhooks_ClassCreated(t *testing.T) {
+	setUpRoomStorage()
+	d := useWebhookDispatcher(t)
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	_, webhook := postWebhook(t, `{"url":"`+server.URL+`","events":["class.created"]}`)
+	receiver.secret = webhook.Secret
+	_, removed := postWebhook(t, `{"url":"`+server.URL+`","events":["class.created"]}`)
+	req := withURLParam(httptest.NewRequest(http.MethodDelete, "/webhooks/"+removed.ID, nil), "id", removed.ID)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(DeleteWebhook).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(PostCreateClass).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/classes",
+		strings.NewReader(`{"class_name":"Spin","start_date":"2024-11-01","end_date":"2024-11-03","capacity":10,"start_time":"18:30"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	flushEvents()
+	d.Flush(context.Background())
+
+	if len(receiver.events) != 1 || receiver.events[0].Type != events.TypeClassCreated {
+		t.Fatalf("expected a single class.created event, got %+v", receiver.events)
+	}
+	if data, _ := receiver.events[0].Data.(map[string]any); data["class_name"] != "Spin" || data["start_time"] != "18:30" || data["end_date"] != "2024-11-03" {
+		t.Errorf("unexpected event data %+v", data)
+	}
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeCSVRecord fills the struct pointed to by dst from a CSV record.
+// Columns are matched to the struct fields by their json tag using the header row, so a CSV file
+// uses the same field names as the JSON payloads. Only string and int fields (and pointers to them) are supported,
+// an empty cell leaves the field untouched.
+func DecodeCSVRecord(header, record []string, dst any) error {
+	val := reflect.ValueOf(dst)
+	if val.Kind() != reflect.Pointer || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a struct, got %T", dst)
+	}
+	val = val.Elem()
+	t := val.Type()
+
+	// mapping the json names to the field index
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		fields[name] = i
+	}
+
+	for col, column := range header {
+		i, ok := fields[strings.TrimSpace(column)]
+		if !ok || col >= len(record) {
+			continue
+		}
+		value := strings.TrimSpace(record[col])
+		if value == "" {
+			continue
+		}
+		field := val.Field(i)
+
+		// optional fields are pointers so that an explicit zero can be told apart from a missing value
+		if field.Kind() == reflect.Pointer {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q for column %s", value, column)
+			}
+			field.SetInt(n)
+		default:
+			return fmt.Errorf("unsupported type %s for column %s", field.Kind(), column)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import "testing"
+
+type testClass struct {
+	ClassName string `json:"class_name"`
+	Capacity  int    `json:"capacity"`
+	Closes    *int   `json:"closes,omitempty"`
+}
+
+// columns are matched by json name whatever their order, unknown columns are ignored
+func TestDecodeCSVRecord(t *testing.T) {
+	var class testClass
+	err := DecodeCSVRecord([]string{"capacity", "notes", " class_name"}, []string{"15", "bring a mat", "Yoga "}, &class)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := testClass{ClassName: "Yoga", Capacity: 15}
+	if class != expected {
+		t.Errorf("expected %+v, got %+v", expected, class)
+	}
+}
+
+// pointer fields are only set when the cell has a value, so that an explicit zero is kept
+func TestDecodeCSVRecord_PointerField(t *testing.T) {
+	var class testClass
+	if err := DecodeCSVRecord([]string{"class_name", "closes"}, []string{"Yoga", "0"}, &class); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if class.Closes == nil || *class.Closes != 0 {
+		t.Errorf("expected closes to be set to 0, got %v", class.Closes)
+	}
+
+	class = testClass{}
+	if err := DecodeCSVRecord([]string{"class_name", "closes"}, []string{"Yoga", ""}, &class); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if class.Closes != nil {
+		t.Errorf("expected closes to be left empty, got %v", *class.Closes)
+	}
+}
+
+// invalid numbers should be reported with the column name
+func TestDecodeCSVRecord_InvalidNumber(t *testing.T) {
+	var class testClass
+	err := DecodeCSVRecord([]string{"class_name", "capacity"}, []string{"Yoga", "fifteen"}, &class)
+	if err == nil || err.Error() != `invalid number "fifteen" for column capacity` {
+		t.Errorf("expected an invalid number error, got %v", err)
+	}
+}
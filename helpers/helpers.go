@@ -1,10 +1,15 @@
 package helpers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,11 +36,25 @@ func DecodeJSONPayload(w http.ResponseWriter, r *http.Request, reqVariable any)
 	return true
 }
 
+// MessageResponse is the body written by WriteJSONResponse
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
 // helper function to write jsonresponse to Response writer with a required status code
 func WriteJSONResponse(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(map[string]string{"message": message}); err != nil {
+	if err := json.NewEncoder(w).Encode(MessageResponse{Message: message}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// helper function to write any value as a json response with a required status code
+func WriteJSON(w http.ResponseWriter, value any, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
@@ -43,7 +62,24 @@ func WriteJSONResponse(w http.ResponseWriter, message string, statusCode int) {
 // helper function can be used to validate a slice of checks.
 // acceptable values in checks slice are :
 // 1) "checkZeroValue": used to check if user didnt fill the fields or may be few fileds are missing.
+// Fields tagged with `validate:"optional"` are skipped by this check.
 func ValidateRequiredFields(w http.ResponseWriter, reqPayload any, checks []string) bool {
+	err := CheckRequiredFields(reqPayload, checks)
+	if err == nil {
+		return true
+	}
+	if err != errUnsupportedPayload {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+// errUnsupportedPayload is returned when none of the checks could be run on the payload
+var errUnsupportedPayload = errors.New("unsupported payload for validation")
+
+// CheckRequiredFields runs the same checks as ValidateRequiredFields but returns the failure as an error
+// instead of writing it, so that it can be used when validating many payloads at once (e.g imports).
+func CheckRequiredFields(reqPayload any, checks []string) error {
 	for _, checkType := range checks {
 
 		switch checkType {
@@ -58,19 +94,27 @@ func ValidateRequiredFields(w http.ResponseWriter, reqPayload any, checks []stri
 				for i := 0; i < val.NumField(); i++ {
 					fieldValue := val.Field(i)
 
+					if IsOptionalField(t.Field(i)) {
+						continue
+					}
+
 					if isZero(fieldValue) {
 						fieldName := t.Field(i).Name
-						http.Error(w, "Missing or invalid value for field: "+fieldName, http.StatusBadRequest)
-						return false
+						return fmt.Errorf("Missing or invalid value for field: %s", fieldName)
 					}
 
 				}
-				return true
+				return nil
 			}
 		}
 
 	}
-	return false
+	return errUnsupportedPayload
+}
+
+// IsOptionalField reports whether the field is tagged with `validate:"optional"` and can be left empty
+func IsOptionalField(field reflect.StructField) bool {
+	return field.Tag.Get("validate") == "optional"
 }
 
 // As we cannot compare the zero value of a data type with reflect value directly,
@@ -79,3 +123,116 @@ func isZero(v reflect.Value) bool {
 	// check if the value is zero for its type
 	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
 }
+
+// ProblemDetails is the application/problem+json (RFC 7807) body we send for errors that need more than a plain message
+type ProblemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Code is a machine readable error code for clients which need to react to a specific error
+	Code string `json:"code,omitempty"`
+}
+
+// NewProblem returns the problem details for the status with the default type and title filled in
+func NewProblem(status int, code, detail string) ProblemDetails {
+	return ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// helper function to write a problem details response, Type and Title are filled in from the status when left empty
+func WriteProblem(w http.ResponseWriter, problem ProblemDetails) {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	WriteProblemBody(w, problem.Status, problem)
+}
+
+// WriteProblemBody writes a problem details response from any body,
+// so that a struct embedding ProblemDetails can add its own extension members
+func WriteProblemBody(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// NegotiateContentType picks the offer the client prefers according to its Accept header.
+// The first offer is returned when the client doesnt send one and "" when none of the offers are acceptable.
+func NegotiateContentType(r *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQuality, bestSpecificity := "", 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(key) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			specificity := mediaRangeMatch(mediaRange, offer)
+			if specificity < 0 {
+				continue
+			}
+			// preferring the higher quality, then the more specific range, then the order of the offers
+			if quality > bestQuality || (quality == bestQuality && specificity > bestSpecificity) {
+				best, bestQuality, bestSpecificity = offer, quality, specificity
+			}
+			break
+		}
+	}
+	return best
+}
+
+// mediaRangeMatch returns how specific the media range is when it matches the offer and -1 when it doesnt
+func mediaRangeMatch(mediaRange, offer string) int {
+	switch {
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		if strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")) {
+			return 1
+		}
+	case mediaRange == offer:
+		return 2
+	}
+	return -1
+}
+
+// NewID returns a random identifier made of 16 hex characters
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on the platforms we run on, so this is a programming error
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
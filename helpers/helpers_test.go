@@ -158,3 +158,80 @@ func TestValidateRequiredFields(t *testing.T) {
 	}
 
 }
+
+// checking that problem details are written with the right content type and defaults
+func TestWriteProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	WriteProblem(rec, ProblemDetails{Status: http.StatusInternalServerError, Detail: "something went wrong"})
+
+	if rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected content type 'application/problem+json', got '%s'", rec.Header().Get("Content-Type"))
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status code 500, got %d", rec.Code)
+	}
+
+	var problem ProblemDetails
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	expected := ProblemDetails{
+		Type:   "about:blank",
+		Title:  "Internal Server Error",
+		Status: http.StatusInternalServerError,
+		Detail: "something went wrong",
+	}
+	if problem != expected {
+		t.Errorf("expected %+v, got %+v", expected, problem)
+	}
+}
+
+// checking that we pick the offer the client prefers
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"text/csv", "text/csv"},
+		{"text/csv;q=0.5, application/json", "application/json"},
+		{"text/*", "text/csv"},
+		{"*/*;q=0.1, text/csv", "text/csv"},
+		{"application/xml", ""},
+		{"text/csv;q=0", ""},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/export/classes", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		if got := NegotiateContentType(req, "application/json", "text/csv"); got != tc.expected {
+			t.Errorf("Accept %q: expected %q, got %q", tc.accept, tc.expected, got)
+		}
+	}
+}
+
+// optional fields can be left empty
+func TestValidateRequiredFields_OptionalField(t *testing.T) {
+	checklist := []string{"checkZeroValue"}
+	rec := httptest.NewRecorder()
+	reqPayload := struct {
+		Name string
+		Spot int `validate:"optional"`
+	}{Name: "Meher"}
+
+	if !ValidateRequiredFields(rec, reqPayload, checklist) {
+		t.Errorf("expected the optional field to be skipped, got %s", rec.Body.String())
+	}
+}
+
+// ids should be random hex strings
+func TestNewID(t *testing.T) {
+	first, second := NewID(), NewID()
+	if len(first) != 16 || first == second {
+		t.Errorf("expected two different 16 character ids, got %s and %s", first, second)
+	}
+}
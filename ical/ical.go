@@ -0,0 +1,116 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event statuses defined by RFC 5545
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Calendar is an RFC 5545 VCALENDAR holding VEVENTs
+type Calendar struct {
+	// ProdID identifies the product that generated the calendar
+	ProdID string
+	// Name is shown by calendar apps when subscribing to the feed
+	Name   string
+	Events []Event
+}
+
+// Event is a single VEVENT. The UID must be stable across feeds so that calendar apps
+// replace an event when it changes instead of adding a duplicate.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	// Start and End of the event, for all day events only the dates are used and End is exclusive
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+	// Status is one of the Status constants, cancelled events are removed from the calendar by the apps
+	Status string
+	// Sequence must be increased every time the event changes
+	Sequence int
+	// Stamp is when this version of the event was generated
+	Stamp time.Time
+}
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+	maxLineOctets  = 75
+)
+
+// Write writes the calendar as an iCalendar stream with CRLF line endings and folded lines
+func (c Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escapeText(c.Name))
+	}
+
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", e.Stamp.UTC().Format(dateTimeFormat))
+		if e.AllDay {
+			line("DTSTART;VALUE=DATE", e.Start.Format(dateFormat))
+			line("DTEND;VALUE=DATE", e.End.Format(dateFormat))
+		} else {
+			line("DTSTART", e.Start.UTC().Format(dateTimeFormat))
+			line("DTEND", e.End.UTC().Format(dateTimeFormat))
+		}
+		line("SUMMARY", escapeText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escapeText(e.Description))
+		}
+		if e.Status != "" {
+			line("STATUS", e.Status)
+		}
+		line("SEQUENCE", fmt.Sprint(e.Sequence))
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escapeText escapes TEXT values as per RFC 5545 section 3.3.11
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writeFolded writes a content line, folding it every 75 octets without splitting a UTF-8 character
+func writeFolded(w *bufio.Writer, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// continuation lines start with a space which counts towards the limit
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,81 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// checking the calendar is written with CRLF line endings and escaped values
+func TestCalendar_Write(t *testing.T) {
+	stamp := time.Date(2024, time.October, 1, 8, 30, 0, 0, time.UTC)
+	date := time.Date(2024, time.October, 2, 0, 0, 0, 0, time.UTC)
+
+	cal := Calendar{
+		ProdID: "-//Studio Classes//EN",
+		Name:   "Studio classes",
+		Events: []Event{{
+			UID:     "class-20241002@studio",
+			Summary: "Yoga, beginners; bring a mat",
+			Start:   date,
+			End:     date.AddDate(0, 0, 1),
+			AllDay:  true,
+			Status:  StatusConfirmed,
+			Stamp:   stamp,
+		}},
+	}
+
+	var sb strings.Builder
+	if err := cal.Write(&sb); err != nil {
+		t.Fatalf("could not write the calendar: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Studio Classes//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Studio classes",
+		"BEGIN:VEVENT",
+		"UID:class-20241002@studio",
+		"DTSTAMP:20241001T083000Z",
+		"DTSTART;VALUE=DATE:20241002",
+		"DTEND;VALUE=DATE:20241003",
+		`SUMMARY:Yoga\, beginners\; bring a mat`,
+		"STATUS:CONFIRMED",
+		"SEQUENCE:0",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+
+	if sb.String() != expected {
+		t.Errorf("unexpected calendar, got:\n%q\nexpected:\n%q", sb.String(), expected)
+	}
+}
+
+// long lines must be folded to 75 octets without breaking multi byte characters
+func TestCalendar_WriteFoldsLongLines(t *testing.T) {
+	cal := Calendar{Events: []Event{{Summary: strings.Repeat("é", 100)}}}
+
+	var sb strings.Builder
+	if err := cal.Write(&sb); err != nil {
+		t.Fatalf("could not write the calendar: %v", err)
+	}
+
+	var unfolded strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(sb.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + line)
+	}
+
+	if !strings.Contains(unfolded.String(), "SUMMARY:"+strings.Repeat("é", 100)+"\n") {
+		t.Errorf("expected the summary to survive folding, got %q", unfolded.String())
+	}
+}
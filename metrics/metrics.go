@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector is anything that can write itself in the Prometheus text exposition format
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds every metric that is exposed on the /metrics endpoint
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry returns an empty registry, mostly useful for tests
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the metric to the registry so it shows up on the next scrape
+func (reg *Registry) Register(c collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.collectors = append(reg.collectors, c)
+}
+
+// Write writes all the registered metrics in the Prometheus text exposition format
+func (reg *Registry) Write(w io.Writer) {
+	reg.mu.Lock()
+	collectors := append([]collector(nil), reg.collectors...)
+	reg.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the registry in the Prometheus text exposition format
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.Write(w)
+	})
+}
+
+// CounterVec is a counter partitioned by a set of labels
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec creates a counter with the given label names
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*counterSeries)}
+}
+
+// Inc increments the counter for the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter for the given label values by v. Counters can only go up so negative values are ignored.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	checkLabels(c.name, c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := seriesKey(labelValues)
+	s, ok := c.values[key]
+	if !ok {
+		s = &counterSeries{labelValues: labelValues}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value of the counter for the given label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[seriesKey(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, s.labelValues), formatValue(s.value))
+	}
+}
+
+// HistogramVec is a histogram partitioned by a set of labels
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// DefaultBuckets are the bucket upper bounds (in seconds) used for request durations
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewHistogramVec creates a histogram with the given buckets and label names
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &HistogramVec{name: name, help: help, labels: labels, buckets: sorted, values: make(map[string]*histogramSeries)}
+}
+
+// Observe records a single observation for the given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	checkLabels(h.name, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := seriesKey(labelValues)
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for i, upperBound := range h.buckets {
+		if v <= upperBound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations made for the given label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[seriesKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		for i, upperBound := range h.buckets {
+			values := append(append([]string(nil), s.labelValues...), formatValue(upperBound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), s.counts[i])
+		}
+		values := append(append([]string(nil), s.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labelValues), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
+
+// GaugeValue is a single sample returned by a GaugeFunc
+type GaugeValue struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge whose values are computed at scrape time,
+// which is handy for values that are derived from our storage like fill ratios
+type GaugeFunc struct {
+	name   string
+	help   string
+	labels []string
+	fn     func() []GaugeValue
+}
+
+// NewGaugeFunc creates a gauge that calls fn on every scrape
+func NewGaugeFunc(name, help string, labels []string, fn func() []GaugeValue) *GaugeFunc {
+	return &GaugeFunc{name: name, help: help, labels: labels, fn: fn}
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+
+	values := g.fn()
+	sort.Slice(values, func(i, j int) bool {
+		return seriesKey(values[i].LabelValues) < seriesKey(values[j].LabelValues)
+	})
+	for _, v := range values {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, v.LabelValues), formatValue(v.Value))
+	}
+}
+
+// checkLabels panics on a programming error where the number of label values doesnt match the label names
+func checkLabels(name string, labels, labelValues []string) {
+	if len(labels) != len(labelValues) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", name, len(labels), len(labelValues)))
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// seriesKey joins label values with a separator that cannot appear in valid UTF-8 text
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels, labelValues []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label, labelValueEscaper.Replace(labelValues[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
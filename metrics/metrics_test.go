@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+// checking that counters are written in the text exposition format with their labels
+func TestCounterVec_Write(t *testing.T) {
+	reg := NewRegistry()
+	counter := NewCounterVec("test_total", "A test counter.", "reason")
+	reg.Register(counter)
+
+	counter.Inc("full")
+	counter.Inc("full")
+	counter.Inc(`say "hi"`)
+
+	var sb strings.Builder
+	reg.Write(&sb)
+
+	expected := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{reason="full"} 2
+test_total{reason="say \"hi\""} 1
+`
+	if sb.String() != expected {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s", sb.String(), expected)
+	}
+}
+
+// checking that observations end up in every bucket they fit in
+func TestHistogramVec_Write(t *testing.T) {
+	reg := NewRegistry()
+	histogram := NewHistogramVec("test_seconds", "A test histogram.", []float64{1, 0.1}, "route")
+	reg.Register(histogram)
+
+	histogram.Observe(0.05, "/classes")
+	histogram.Observe(0.5, "/classes")
+
+	var sb strings.Builder
+	reg.Write(&sb)
+
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{route="/classes",le="0.1"} 1
+test_seconds_bucket{route="/classes",le="1"} 2
+test_seconds_bucket{route="/classes",le="+Inf"} 2
+test_seconds_sum{route="/classes"} 0.55
+test_seconds_count{route="/classes"} 2
+`
+	if sb.String() != expected {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s", sb.String(), expected)
+	}
+}
+
+// gauge funcs are evaluated on scrape and written sorted by their labels
+func TestGaugeFunc_Write(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(NewGaugeFunc("test_ratio", "A test gauge.", []string{"date"}, func() []GaugeValue {
+		return []GaugeValue{
+			{LabelValues: []string{"2024-10-03"}, Value: 1},
+			{LabelValues: []string{"2024-10-02"}, Value: 0.25},
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", rec.Header().Get("Content-Type"))
+	}
+
+	expected := `# HELP test_ratio A test gauge.
+# TYPE test_ratio gauge
+test_ratio{date="2024-10-02"} 0.25
+test_ratio{date="2024-10-03"} 1
+`
+	if rec.Body.String() != expected {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s", rec.Body.String(), expected)
+	}
+}
+
+// the middleware should label requests with the route pattern and not the raw path
+func TestInstrument(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(Instrument)
+	mux.Get("/members/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	before := HTTPRequests.Value(http.MethodGet, "/members/{id}", "418")
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/members/42", nil))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/members/43", nil))
+
+	if got := HTTPRequests.Value(http.MethodGet, "/members/{id}", "418") - before; got != 2 {
+		t.Errorf("expected 2 requests to be counted, got %v", got)
+	}
+	if HTTPRequestDuration.Count(http.MethodGet, "/members/{id}", "418") < 2 {
+		t.Errorf("expected durations to be observed for the route")
+	}
+}
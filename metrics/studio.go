@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// Reasons used to label rejected bookings
+const (
+	RejectDuplicate       = "duplicate"
+	RejectNoClass         = "no_class"
+	RejectFull            = "full"
+	RejectSuspended       = "suspended"
+	RejectPast            = "past"
+	RejectNotOpen         = "not_open"
+	RejectClosed          = "closed"
+	RejectSpotTaken       = "spot_taken"
+	RejectNoCredits       = "no_credits"
+	RejectNotEntitled     = "not_entitled"
+	RejectPaymentRequired = "payment_required"
+	RejectPaymentFailed   = "payment_failed"
+)
+
+// Default is the registry served by the /metrics endpoint
+var Default = NewRegistry()
+
+var (
+	// HTTPRequests counts every request we served by method, route pattern and status code
+	HTTPRequests = NewCounterVec("studio_http_requests_total",
+		"Number of HTTP requests handled, partitioned by method, route and status code.",
+		"method", "route", "status")
+
+	// HTTPRequestDuration tracks how long we took to respond by method, route pattern and status code
+	HTTPRequestDuration = NewHistogramVec("studio_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, partitioned by method, route and status code.",
+		DefaultBuckets, "method", "route", "status")
+
+	// ClassesCreated counts the number of daily class sessions created
+	ClassesCreated = NewCounterVec("studio_classes_created_total",
+		"Number of daily class sessions created.")
+
+	// BookingsCreated counts successful bookings
+	BookingsCreated = NewCounterVec("studio_bookings_created_total",
+		"Number of bookings created.")
+
+	// BookingsRejected counts bookings we turned down, partitioned by reason
+	BookingsRejected = NewCounterVec("studio_bookings_rejected_total",
+		"Number of bookings rejected, partitioned by reason (duplicate, no_class, full, suspended, past, not_open, closed, spot_taken, no_credits, not_entitled, payment_required, payment_failed).",
+		"reason")
+)
+
+func init() {
+	Default.Register(HTTPRequests)
+	Default.Register(HTTPRequestDuration)
+	Default.Register(ClassesCreated)
+	Default.Register(BookingsCreated)
+	Default.Register(BookingsRejected)
+}
+
+// Handler serves the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// Instrument is a middleware recording the request counter and duration histogram for every request.
+// The route label uses the chi route pattern rather than the raw path so that we dont get a series per URL.
+func Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		labels := []string{r.Method, route, strconv.Itoa(status)}
+		HTTPRequests.Inc(labels...)
+		HTTPRequestDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
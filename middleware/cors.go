@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures which cross origin requests browsers are allowed to make
+type CORSOptions struct {
+	// AllowedOrigins is the list of origins allowed to call us, e.g "https://widget.example.com".
+	// A "*" in place of the first label of the host allows every subdomain ("https://*.example.com")
+	// and a lone "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in preflight requests
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in preflight requests, matched case insensitively
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers the browser lets the calling script read
+	ExposedHeaders []string
+	// AllowCredentials lets the browser send cookies and authorization headers, it cannot be combined with a lone "*" origin
+	AllowCredentials bool
+	// MaxAge is how long the browser can cache the result of a preflight request
+	MaxAge time.Duration
+}
+
+// CORS returns a middleware adding the CORS headers for allowed origins.
+// Preflight requests are answered directly so they never reach the router, which would respond with a 405.
+// It panics when credentials are allowed for any origin, as every site could then make requests with the cookies
+// of our users, so that a bad configuration stops the server when it starts.
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	if opts.AllowCredentials && containsFold(opts.AllowedOrigins, "*") {
+		panic("middleware: CORS cannot allow credentials for any origin, list the allowed origins instead")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// requests from the same origin or from non browser clients dont need any CORS headers
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if !opts.originAllowed(origin) {
+				if preflight {
+					http.Error(w, "Origin not allowed", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", opts.allowOriginValue(origin))
+			if opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(opts.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := r.Header.Get("Access-Control-Request-Method")
+			if !containsFold(opts.AllowedMethods, method) {
+				http.Error(w, "Method not allowed by CORS policy: "+method, http.StatusForbidden)
+				return
+			}
+			for _, header := range splitHeaderList(r.Header.Get("Access-Control-Request-Headers")) {
+				if !containsFold(opts.AllowedHeaders, header) {
+					http.Error(w, "Header not allowed by CORS policy: "+header, http.StatusForbidden)
+					return
+				}
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+			if len(opts.AllowedHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+			}
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed checks the origin against the allowed list, supporting wildcard subdomains
+func (opts CORSOptions) originAllowed(origin string) bool {
+	for _, allowed := range opts.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return true
+		case strings.EqualFold(allowed, origin):
+			return true
+		case strings.Contains(allowed, "://*."):
+			if matchWildcardOrigin(allowed, origin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowOriginValue returns "*" only when any origin is allowed and no credentials are involved,
+// browsers reject the wildcard for credentialed requests so we echo the origin in every other case
+func (opts CORSOptions) allowOriginValue(origin string) string {
+	if !opts.AllowCredentials && len(opts.AllowedOrigins) == 1 && opts.AllowedOrigins[0] == "*" {
+		return "*"
+	}
+	return origin
+}
+
+// matchWildcardOrigin matches "https://*.example.com" against an origin like "https://widget.example.com".
+// The scheme and port must match and the bare domain itself is not matched.
+func matchWildcardOrigin(pattern, origin string) bool {
+	p, err := url.Parse(strings.Replace(pattern, "*.", "wildcard.", 1))
+	if err != nil {
+		return false
+	}
+	o, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(p.Scheme, o.Scheme) || p.Port() != o.Port() {
+		return false
+	}
+	suffix := strings.TrimPrefix(strings.ToLower(p.Hostname()), "wildcard")
+	host := strings.ToLower(o.Hostname())
+	return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHeaderList(value string) []string {
+	var headers []string
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
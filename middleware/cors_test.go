@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+var widgetCORS = CORSOptions{
+	AllowedOrigins:   []string{"https://studio.example.com", "https://*.widgets.example.com"},
+	AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+	AllowedHeaders:   []string{"Content-Type", APIKeyHeader},
+	ExposedHeaders:   []string{"Retry-After"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+// router with only a POST route so that an OPTIONS request would normally get a 405
+func newCORSRouter(opts CORSOptions) http.Handler {
+	mux := chi.NewRouter()
+	mux.Use(CORS(opts))
+	mux.Post("/bookings", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	return mux
+}
+
+func preflightRequest(origin, method, headers string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, "/bookings", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return req
+}
+
+// preflight from a wildcard subdomain should be answered by the middleware
+func TestCORS_Preflight(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newCORSRouter(widgetCORS).ServeHTTP(rec, preflightRequest("https://book.widgets.example.com", http.MethodPost, "content-type, x-api-key"))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+
+	expectedHeaders := map[string]string{
+		"Access-Control-Allow-Origin":      "https://book.widgets.example.com",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type, X-API-Key",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	}
+	for header, expected := range expectedHeaders {
+		if got := rec.Header().Get(header); got != expected {
+			t.Errorf("expected %s to be %q, got %q", header, expected, got)
+		}
+	}
+}
+
+// preflight requests which are not allowed by the policy should be rejected
+func TestCORS_PreflightRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *http.Request
+	}{
+		{"unknown origin", preflightRequest("https://evil.example.org", http.MethodPost, "")},
+		{"bare domain of wildcard", preflightRequest("https://widgets.example.com", http.MethodPost, "")},
+		{"wrong scheme", preflightRequest("http://book.widgets.example.com", http.MethodPost, "")},
+		{"method", preflightRequest("https://studio.example.com", http.MethodDelete, "")},
+		{"header", preflightRequest("https://studio.example.com", http.MethodPost, "X-Secret")},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		newCORSRouter(widgetCORS).ServeHTTP(rec, tc.request)
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %d", tc.name, rec.Code)
+		}
+		if rec.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Errorf("%s: expected no Access-Control-Allow-Methods header", tc.name)
+		}
+	}
+}
+
+// simple requests go through to the handler with the CORS headers added
+func TestCORS_SimpleRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/bookings", nil)
+	req.Header.Set("Origin", "https://studio.example.com")
+	rec := httptest.NewRecorder()
+	newCORSRouter(widgetCORS).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://studio.example.com" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "Retry-After" {
+		t.Errorf("expected Retry-After to be exposed, got %q", got)
+	}
+
+	// requests from other origins still reach the handler but without the CORS headers, so the browser blocks them
+	req = httptest.NewRequest(http.MethodPost, "/bookings", nil)
+	req.Header.Set("Origin", "https://evil.example.org")
+	rec = httptest.NewRecorder()
+	newCORSRouter(widgetCORS).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin header, got %q", got)
+	}
+}
+
+// allowing any origin without credentials should use the wildcard value
+func TestCORS_AnyOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/bookings", nil)
+	req.Header.Set("Origin", "https://anything.example.org")
+	rec := httptest.NewRecorder()
+	newCORSRouter(CORSOptions{AllowedOrigins: []string{"*"}}).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected *, got %q", got)
+	}
+}
+
+// credentials cannot be allowed for any origin, the origin would be echoed back to every site
+func TestCORS_AnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected credentials for any origin to be refused")
+		}
+	}()
+	CORS(CORSOptions{AllowedOrigins: []string{"https://studio.example.com", "*"}, AllowCredentials: true})
+}
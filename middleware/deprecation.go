@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DeprecationOptions describes when a set of routes was deprecated and when it will be removed
+type DeprecationOptions struct {
+	// DeprecatedAt is sent in the Deprecation header (RFC 9745)
+	DeprecatedAt time.Time
+	// Sunset is when the routes will stop working, sent in the Sunset header (RFC 8594)
+	Sunset time.Time
+	// SuccessorPrefix is prepended to the request path to point clients at the replacement route
+	SuccessorPrefix string
+}
+
+// Deprecation returns a middleware marking the responses of the wrapped routes as deprecated,
+// with a Link header pointing at the same path under the successor prefix.
+func Deprecation(opts DeprecationOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", opts.DeprecatedAt.Unix()))
+			if !opts.Sunset.IsZero() {
+				w.Header().Set("Sunset", opts.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if opts.SuccessorPrefix != "" {
+				w.Header().Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, opts.SuccessorPrefix, r.URL.Path))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
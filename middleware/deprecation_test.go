@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// checking the headers sent for deprecated routes
+func TestDeprecation(t *testing.T) {
+	handler := Deprecation(DeprecationOptions{
+		DeprecatedAt:    time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:          time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+		SuccessorPrefix: "/v1",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/bookings", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+
+	expectedHeaders := map[string]string{
+		"Deprecation": "@1792368000",
+		"Sunset":      "Mon, 19 Apr 2027 00:00:00 GMT",
+		"Link":        `</v1/bookings>; rel="successor-version"`,
+	}
+	for header, expected := range expectedHeaders {
+		if got := rec.Header().Get(header); got != expected {
+			t.Errorf("expected %s to be %q, got %q", header, expected, got)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+)
+
+// APIKeyHeader is the header clients can use to identify themselves, it takes priority over the client IP
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitOptions configures a token bucket rate limiter.
+// A client starts with Requests tokens, every request takes one and the bucket refills completely over Per.
+type RateLimitOptions struct {
+	// Requests is the size of the bucket, i.e the number of requests a client can make in a burst
+	Requests int
+	// Per is the time it takes for an empty bucket to be full again
+	Per time.Duration
+	// IdleTTL is how long we keep the bucket of a client that stopped sending requests, defaults to Per
+	IdleTTL time.Duration
+	// Clock lets tests control the time, defaults to the wall clock
+	Clock clock.Clock
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type rateLimiter struct {
+	opts RateLimitOptions
+	rate float64 // tokens added per second
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// RateLimit returns a middleware limiting the requests of each client (API key or IP) with a token bucket.
+// Limited requests get a 429 with Retry-After, and every response carries the RateLimit-* headers.
+// It panics when Requests or Per is not positive, as the limiter could not compute a rate, so that a bad
+// configuration stops the server when it starts.
+func RateLimit(opts RateLimitOptions) func(http.Handler) http.Handler {
+	if opts.Requests <= 0 || opts.Per <= 0 {
+		panic(fmt.Sprintf("middleware: rate limit needs positive Requests and Per, got %d per %s", opts.Requests, opts.Per))
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = opts.Per
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real{}
+	}
+	limiter := &rateLimiter{
+		opts:    opts,
+		rate:    float64(opts.Requests) / opts.Per.Seconds(),
+		buckets: make(map[string]*bucket),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, remaining, retryAfter, reset := limiter.take(clientKey(r))
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(opts.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+				http.Error(w, "Too many requests, please try again later", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take tries to take a token out of the bucket of the client.
+// It returns whether the request is allowed, how many tokens are left,
+// how long to wait for the next token and how long until the bucket is full again.
+func (l *rateLimiter) take(key string) (bool, int, time.Duration, time.Duration) {
+	now := l.opts.Clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.opts.Requests), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// refilling the bucket for the time passed since the last request
+	b.tokens = math.Min(float64(l.opts.Requests), b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	retryAfter := time.Duration(0)
+	if b.tokens < 1 {
+		retryAfter = l.timeToRefill(1 - b.tokens)
+	}
+	reset := l.timeToRefill(float64(l.opts.Requests) - b.tokens)
+
+	return allowed, int(b.tokens), retryAfter, reset
+}
+
+// sweep drops the buckets of clients we havent heard from in IdleTTL so that memory doesnt grow forever
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.opts.IdleTTL {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.opts.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *rateLimiter) timeToRefill(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// clientKey identifies the client by API key when it is sent and falls back to the client IP
+func clientKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds rounds a duration up to whole seconds as the headers dont accept fractions
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
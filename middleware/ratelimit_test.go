@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+)
+
+// newFakeClock returns a clock which the test can move forward
+func newFakeClock() *clock.Fake {
+	return clock.NewFake(time.Date(2024, time.October, 2, 9, 0, 0, 0, time.UTC))
+}
+
+func newLimitedHandler(opts RateLimitOptions) http.Handler {
+	return RateLimit(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+}
+
+func bookingRequest(remoteAddr, apiKey string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/bookings", nil)
+	req.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		req.Header.Set(APIKeyHeader, apiKey)
+	}
+	return req
+}
+
+// once the bucket is empty we should get a 429 with a Retry-After until it refills
+func TestRateLimit(t *testing.T) {
+	fake := newFakeClock()
+	handler := newLimitedHandler(RateLimitOptions{Requests: 2, Per: time.Minute, Clock: fake})
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, bookingRequest("10.0.0.1:1234", ""))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("request %d: expected status 201, got %d", i, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, bookingRequest("10.0.0.1:1234", ""))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+
+	// a token is added every 30 seconds
+	expectedHeaders := map[string]string{
+		"Retry-After":         "30",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+	}
+	for header, expected := range expectedHeaders {
+		if got := rec.Header().Get(header); got != expected {
+			t.Errorf("expected %s to be %s, got %s", header, expected, got)
+		}
+	}
+
+	// after waiting for a token we should be let through again
+	fake.Advance(30 * time.Second)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, bookingRequest("10.0.0.1:1234", ""))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201 after the bucket refilled, got %d", rec.Code)
+	}
+}
+
+// clients are limited separately by API key and by IP
+func TestRateLimit_PerClient(t *testing.T) {
+	fake := newFakeClock()
+	handler := newLimitedHandler(RateLimitOptions{Requests: 1, Per: time.Minute, Clock: fake})
+
+	requests := []*http.Request{
+		bookingRequest("10.0.0.1:1234", ""),
+		bookingRequest("10.0.0.2:1234", ""),
+		bookingRequest("10.0.0.1:1234", "studio-widget"),
+	}
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Errorf("expected status 201 for %s %s, got %d", req.RemoteAddr, req.Header.Get(APIKeyHeader), rec.Code)
+		}
+	}
+}
+
+// buckets of clients which went quiet should be dropped
+func TestRateLimit_ExpiresIdleBuckets(t *testing.T) {
+	fake := newFakeClock()
+	limiter := &rateLimiter{
+		opts:    RateLimitOptions{Requests: 1, Per: time.Minute, IdleTTL: 5 * time.Minute, Clock: fake},
+		rate:    1.0 / 60,
+		buckets: make(map[string]*bucket),
+	}
+
+	limiter.take("ip:10.0.0.1")
+	fake.Advance(time.Minute)
+	limiter.take("ip:10.0.0.2")
+
+	fake.Advance(5 * time.Minute)
+	limiter.take("ip:10.0.0.2")
+
+	if _, ok := limiter.buckets["ip:10.0.0.1"]; ok {
+		t.Errorf("expected the idle bucket to be expired")
+	}
+	if _, ok := limiter.buckets["ip:10.0.0.2"]; !ok {
+		t.Errorf("expected the active bucket to be kept")
+	}
+}
+
+// a limiter without a positive rate would compute NaN tokens, it is refused when it is built
+func TestRateLimit_InvalidOptions(t *testing.T) {
+	for _, opts := range []RateLimitOptions{{Requests: 5}, {Requests: 0, Per: time.Minute}, {Requests: -1, Per: time.Minute}, {Requests: 5, Per: -time.Second}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %+v to be refused", opts)
+				}
+			}()
+			RateLimit(opts)
+		}()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	chimiddleware "github.com/go-chi/chi/middleware"
+)
+
+// Recoverer is a middleware that recovers from panics in the handlers further down the chain.
+// It logs the panic along with the request ID and the stack trace and responds with a 500 problem details body,
+// so that the client gets a proper response instead of a dropped connection.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			// http.ErrAbortHandler is used to abort the response on purpose, so we let net/http deal with it
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+
+			requestID := chimiddleware.GetReqID(r.Context())
+			log.Printf("panic serving %s %s (request_id=%s): %v\n%s", r.Method, r.URL.Path, requestID, rvr, debug.Stack())
+
+			// we can only send our body if the handler didnt start writing the response already
+			if ww.Status() != 0 {
+				return
+			}
+			helpers.WriteProblem(ww, helpers.ProblemDetails{
+				Status:    http.StatusInternalServerError,
+				Detail:    "The server encountered an unexpected error while handling the request",
+				Instance:  r.URL.Path,
+				RequestID: requestID,
+			})
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
+)
+
+// mounting a panicking handler and making sure we get a 500 problem details response and a log with the stack
+func TestRecoverer(t *testing.T) {
+	// capturing the logs so that we can check what was logged
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	mux := chi.NewRouter()
+	mux.Use(chimiddleware.RequestID)
+	mux.Use(Recoverer)
+	mux.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var class *struct{ Capacity int }
+		_ = class.Capacity // nil pointer dereference
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(chimiddleware.RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected content type application/problem+json, got %s", rec.Header().Get("Content-Type"))
+	}
+
+	var problem helpers.ProblemDetails
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if problem.Status != http.StatusInternalServerError || problem.Title != "Internal Server Error" {
+		t.Errorf("unexpected problem details: %+v", problem)
+	}
+	if problem.RequestID != "req-123" {
+		t.Errorf("expected request id req-123, got %s", problem.RequestID)
+	}
+
+	// the log should have both the request ID and the stack trace
+	if !strings.Contains(logs.String(), "request_id=req-123") {
+		t.Errorf("expected the log to contain the request id, got %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "goroutine") {
+		t.Errorf("expected the log to contain the stack trace, got %s", logs.String())
+	}
+}
+
+// handlers which dont panic should not be affected
+func TestRecoverer_NoPanic(t *testing.T) {
+	handler := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		helpers.WriteJSONResponse(w, "ok", http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+}
@@ -1,17 +1,272 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
-// used to store class data
+// used to store class data, every session of a class shares it
 type Class struct {
+	ID        string
 	ClassName string
 	StartDate time.Time
 	EndDate   time.Time
 	Capacity  int
+	// StartTime is the time of day the class starts at, as an offset from midnight UTC
+	StartTime time.Duration
+	// Duration is how long each session of the class lasts
+	Duration time.Duration
+	// BookingOpensBefore is how long before the start of a session members can start booking it, zero means any time
+	BookingOpensBefore time.Duration
+	// BookingClosesBefore is how long before the start of a session bookings close, zero means at the start
+	BookingClosesBefore time.Duration
+	// InstructorID is the instructor teaching the session, empty when nobody is assigned yet
+	InstructorID string
+	// RoomID is the room the class takes place in, classes without a room share the studio
+	RoomID string
+	// Spots is the number of numbered spots (mats, reformers, bikes) members book, zero when they just book a seat
+	Spots int
+	// CreditCost is the number of credits a booking of a session costs, zero for free classes
+	CreditCost int
+	// DropInPriceCents is what members pay for a booking no plan or credits cover, zero when drop-ins dont pay
+	DropInPriceCents int
 }
 
+// Start returns when the session of the class on the given date starts
+func (c Class) Start(date time.Time) time.Time {
+	return date.Add(c.StartTime)
+}
+
+// End returns when the session of the class on the given date ends
+func (c Class) End(date time.Time) time.Time {
+	return c.Start(date).Add(c.Duration)
+}
+
+// BookingOpens returns when members can start booking the session on the given date,
+// the zero time is returned when the class has no opening restriction
+func (c Class) BookingOpens(date time.Time) time.Time {
+	if c.BookingOpensBefore == 0 {
+		return time.Time{}
+	}
+	return c.Start(date).Add(-c.BookingOpensBefore)
+}
+
+// BookingCloses returns when bookings for the session on the given date close
+func (c Class) BookingCloses(date time.Time) time.Time {
+	return c.Start(date).Add(-c.BookingClosesBefore)
+}
+
+// used to store room data
+type Room struct {
+	ID   string
+	Name string
+	// Capacity is the number of people the room physically fits, no class in the room can have a larger capacity
+	Capacity int
+}
+
+// used to store instructor data
+type Instructor struct {
+	ID    string
+	Name  string
+	Email string
+	Bio   string
+}
+
+// used to store a change of the instructor teaching a class session
+type InstructorChange struct {
+	Date             time.Time
+	ClassID          string
+	ClassName        string
+	FromInstructorID string
+	ToInstructorID   string
+	// Reason is given for substitutions, e.g "sick"
+	Reason    string
+	ChangedAt time.Time
+}
+
+// Overlaps reports whether the session of the class on date overlaps the session of other on otherDate
+func (c Class) Overlaps(date time.Time, other Class, otherDate time.Time) bool {
+	return c.Start(date).Before(other.End(otherDate)) && other.Start(otherDate).Before(c.End(date))
+}
+
+// statuses a booking goes through
+const (
+	// StatusBooked is the status of a booking until the member checks in or the class ends
+	StatusBooked = "booked"
+	// StatusCheckedIn is set by the front desk when the member shows up
+	StatusCheckedIn = "checked_in"
+	// StatusNoShow is set automatically for bookings which were not checked in by the end of the class
+	StatusNoShow = "no_show"
+	// StatusCancelled is set when the member cancels, the seat is given back to the class
+	StatusCancelled = "cancelled"
+	// StatusPendingPayment holds the seat of a drop-in booking while it is paid for, it becomes booked once paid
+	StatusPendingPayment = "pending_payment"
+	// StatusReleased is set when the payment of a drop-in booking failed or a hold is let go, the seat is given back to the class
+	StatusReleased = "released"
+	// StatusHeld reserves the seat for a few minutes while the member checks out, it becomes booked once converted
+	StatusHeld = "held"
+	// StatusExpired is set by the hold sweeper for holds which were not converted in time, the seat is given back to the class
+	StatusExpired = "expired"
+)
+
+// statuses of the payment of a drop-in booking
+const (
+	PaymentPending  = "pending"
+	PaymentCaptured = "captured"
+	PaymentFailed   = "failed"
+	PaymentRefunded = "refunded"
+)
+
 // used to store booking data
 type Booking struct {
-	Name string
-	Date time.Time
+	ID string
+	// ClassID is the class booked on Date, as there can be several classes per day
+	ClassID string
+	Name    string
+	// Email is where the notifications of the booking are emailed, empty when the member did not give one
+	Email string
+	Date  time.Time
+	// Spot is the numbered spot the member holds, zero when the class has none
+	Spot int
+	// SubscriptionID is the subscription the booking was made under, empty when it was not covered by a plan
+	SubscriptionID string
+	// PaymentStatus, PaymentID and AmountCents describe the payment of drop-in bookings, they are empty for the others
+	PaymentStatus string
+	PaymentID     string
+	AmountCents   int
+	// HoldExpiresAt is when the seat of a booking made from a hold is given back if the hold is not converted, zero for the others
+	HoldExpiresAt time.Time
+	Status        string
+	CreatedAt     time.Time
+	CheckedInAt   time.Time
+	CancelledAt   time.Time
+}
+
+// Active reports whether the booking still holds a seat in the class
+func (b Booking) Active() bool {
+	return b.Status != StatusCancelled && b.Status != StatusReleased && b.Status != StatusExpired
+}
+
+// kinds of entries in the credits ledger
+const (
+	// CreditPurchase adds the credits of a class pack
+	CreditPurchase = "purchase"
+	// CreditDebit takes the credits of a booking
+	CreditDebit = "debit"
+	// CreditRefund gives the credits of a cancelled booking back
+	CreditRefund = "refund"
+	// CreditExpiry removes the credits left in a pack once it expired
+	CreditExpiry = "expiry"
+)
+
+// used to store a movement in the credits ledger of a member
+type CreditEntry struct {
+	ID     string
+	Member string
+	Kind   string
+	// Credits is positive for purchases and refunds, negative for debits and expiries
+	Credits int
+	// PackID is the purchase the credits of a debit, refund or expiry belong to
+	PackID    string
+	BookingID string
+	// ExpiresAt is when the credits of a purchase expire
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// reasons of the fees members owe
+const (
+	FeeLateCancel = "late_cancel"
+)
+
+// used to store a fee a member owes the studio, settled at the front desk
+type Fee struct {
+	ID        string
+	Member    string
+	BookingID string
+	// Reason is one of the Fee constants
+	Reason      string
+	AmountCents int
+	CreatedAt   time.Time
+}
+
+// periods membership plans count bookings over
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// used to store a membership plan
+type Plan struct {
+	ID     string
+	Name   string
+	Period string
+	// MaxBookings is the number of bookings a member can make per period, zero for unlimited
+	MaxBookings int
+	// ClassTypes are the names of the classes members of the plan can book, empty for every class
+	ClassTypes []string
+}
+
+// Allows reports whether members of the plan can book the class
+func (p Plan) Allows(class Class) bool {
+	if len(p.ClassTypes) == 0 {
+		return true
+	}
+	for _, classType := range p.ClassTypes {
+		if strings.EqualFold(classType, class.ClassName) {
+			return true
+		}
+	}
+	return false
+}
+
+// PeriodOf returns the start and end of the period containing date for a subscription starting on start.
+// Periods are counted from the start of the subscription, e.g a monthly plan starting on the 15th renews every 15th.
+// A monthly plan starting on a day some months dont have renews on the last day of those months.
+func (p Plan) PeriodOf(start, date time.Time) (time.Time, time.Time) {
+	period := func(n int) time.Time {
+		if p.Period == PeriodWeek {
+			return start.AddDate(0, 0, 7*n)
+		}
+		return addMonths(start, n)
+	}
+
+	// a first guess which is corrected below, as months dont have the same length
+	n := (date.Year()-start.Year())*12 + int(date.Month()) - int(start.Month())
+	if p.Period == PeriodWeek {
+		n = int(date.Sub(start).Hours() / (24 * 7))
+	}
+	for period(n).After(date) {
+		n--
+	}
+	for !period(n + 1).After(date) {
+		n++
+	}
+	return period(n), period(n + 1)
+}
+
+// addMonths adds n months to t, keeping its day unless the month is shorter, in which case it is the last day of the month.
+// AddDate would normalize the 31st of January plus a month into the 2nd of March.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// used to store the subscription of a member to a plan
+type Subscription struct {
+	ID        string
+	Member    string
+	PlanID    string
+	StartDate time.Time
+	// EndDate is the last day of the subscription, zero when it runs until cancelled
+	EndDate time.Time
+}
+
+// Covers reports whether the subscription is active on date
+func (s Subscription) Covers(date time.Time) bool {
+	return !date.Before(s.StartDate) && (s.EndDate.IsZero() || !date.After(s.EndDate))
 }
@@ -0,0 +1,138 @@
+// Package notify delivers messages to members through a pluggable Notifier.
+// Handlers only queue the messages, a worker running the Queue delivers them in the background
+// so that a slow mail server never holds up a request. The sender is told once a message was delivered.
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// kinds of messages we send, the email templates are named after them
+const (
+	KindBookingConfirmed = "booking_confirmed"
+	KindBookingCancelled = "booking_cancelled"
+	// KindWaitlistPromoted is for a member getting the seat they were waiting for,
+	// classes have no waitlist yet so nothing sends it
+	KindWaitlistPromoted = "waitlist_promoted"
+	// KindSubstitution is for a change of the instructor of a class the member booked
+	KindSubstitution = "substitution"
+)
+
+// Kinds lists the kinds of messages
+var Kinds = []string{KindBookingConfirmed, KindBookingCancelled, KindWaitlistPromoted, KindSubstitution}
+
+// Message is a notification for a member
+type Message struct {
+	// Kind tells what the notification is about, e.g KindSubstitution
+	Kind string
+	// To is the member the message is for
+	To string
+	// Email is the address of the member, empty when they did not give one
+	Email string
+	// Subject and Body are the plain text of the message, email notifiers render their templates from Details instead
+	Subject string
+	Body    string
+	Details Details
+}
+
+// Details describes the class session a message is about
+type Details struct {
+	BookingID string
+	ClassName string
+	// Date is formatted as 2006-01-02 and StartTime as 15:04
+	Date            string
+	StartTime       string
+	DurationMinutes int
+	Instructor      string
+	// PreviousInstructor is the instructor who was replaced, for substitutions
+	PreviousInstructor string
+	Room               string
+	// Spot is the numbered spot of the member, zero when the class has none
+	Spot int
+	// LateCancel tells whether a cancellation was made after the cutoff of the cancellation policy
+	LateCancel bool
+}
+
+// Notifier delivers a message to a member
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes the messages to the log, it is the default until a real channel is configured
+type LogNotifier struct{}
+
+// Notify logs the message
+func (LogNotifier) Notify(ctx context.Context, msg Message) error {
+	log.Printf("notify %s: %s: %s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// Queue buffers messages until a worker delivers them with its notifier
+type Queue struct {
+	notifier Notifier
+	messages chan queued
+}
+
+// queued is a message waiting in the queue
+type queued struct {
+	msg Message
+	// done is called once the message was delivered or could not be
+	done func(err error)
+}
+
+// NewQueue returns a queue holding up to size undelivered messages
+func NewQueue(notifier Notifier, size int) *Queue {
+	return &Queue{notifier: notifier, messages: make(chan queued, size)}
+}
+
+// Enqueue queues the message without blocking, it reports false when the queue is full and the message was dropped.
+// done, when not nil, is called once the queued message was delivered, with the error of the notifier when it could not be.
+func (q *Queue) Enqueue(msg Message, done func(err error)) bool {
+	select {
+	case q.messages <- queued{msg: msg, done: done}:
+		return true
+	default:
+		log.Printf("notify: queue is full, dropping %s message for %s", msg.Kind, msg.To)
+		return false
+	}
+}
+
+// Run delivers the queued messages until the context is done
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-q.messages:
+			q.deliver(ctx, m)
+		}
+	}
+}
+
+// Flush delivers the messages which are queued right now and returns how many were delivered,
+// it is used on shutdown and by tests which dont run a worker
+func (q *Queue) Flush(ctx context.Context) int {
+	delivered := 0
+	for {
+		select {
+		case m := <-q.messages:
+			if q.deliver(ctx, m) {
+				delivered++
+			}
+		default:
+			return delivered
+		}
+	}
+}
+
+func (q *Queue) deliver(ctx context.Context, m queued) bool {
+	err := q.notifier.Notify(ctx, m.msg)
+	if err != nil {
+		log.Printf("notify: could not deliver %s message to %s: %v", m.msg.Kind, m.msg.To, err)
+	}
+	if m.done != nil {
+		m.done(err)
+	}
+	return err == nil
+}
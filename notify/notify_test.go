@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type recorder struct {
+	messages []Message
+	fail     bool
+}
+
+func (r *recorder) Notify(ctx context.Context, msg Message) error {
+	if r.fail {
+		return errors.New("mail server is down")
+	}
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// queued messages are delivered in order by Flush and the queue drops messages once it is full
+func TestQueue(t *testing.T) {
+	rec := &recorder{}
+	q := NewQueue(rec, 2)
+
+	if !q.Enqueue(Message{To: "Meher"}, nil) || !q.Enqueue(Message{To: "Ravi"}, nil) {
+		t.Fatalf("expected the messages to be queued")
+	}
+	if q.Enqueue(Message{To: "Anu"}, nil) {
+		t.Errorf("expected the message to be dropped when the queue is full")
+	}
+
+	if delivered := q.Flush(context.Background()); delivered != 2 {
+		t.Errorf("expected 2 messages to be delivered, got %d", delivered)
+	}
+	if len(rec.messages) != 2 || rec.messages[0].To != "Meher" || rec.messages[1].To != "Ravi" {
+		t.Errorf("unexpected messages %+v", rec.messages)
+	}
+}
+
+// failed deliveries are not counted, the sender is told why they failed
+func TestQueue_FailedDelivery(t *testing.T) {
+	q := NewQueue(&recorder{fail: true}, 1)
+	var failure error
+	q.Enqueue(Message{To: "Meher"}, func(err error) { failure = err })
+
+	if delivered := q.Flush(context.Background()); delivered != 0 {
+		t.Errorf("expected no messages to be delivered, got %d", delivered)
+	}
+	if failure == nil || failure.Error() != "mail server is down" {
+		t.Errorf("expected the sender to be told about the failure, got %v", failure)
+	}
+}
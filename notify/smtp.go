@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTPConfig tells SMTPNotifier which server to send the emails through
+type SMTPConfig struct {
+	// Addr is the host:port of the server, e.g smtp.example.com:587
+	Addr string
+	// Username and Password authenticate with PLAIN auth, no auth is done without a username.
+	// net/smtp only sends them over TLS or to localhost.
+	Username string
+	Password string
+	// From is the sender of the emails, e.g "Studio <hello@studio.example.com>"
+	From string
+	// Timeout bounds a whole delivery, 30 seconds when it is zero
+	Timeout time.Duration
+}
+
+// SMTPNotifier emails the messages to the members through an SMTP server, rendering them with its templates.
+// STARTTLS is used when the server offers it. Members without an email address are skipped.
+type SMTPNotifier struct {
+	config    SMTPConfig
+	templates *Templates
+}
+
+// NewSMTPNotifier returns a notifier sending through the server of config, with the default templates when templates is nil
+func NewSMTPNotifier(config SMTPConfig, templates *Templates) *SMTPNotifier {
+	if templates == nil {
+		templates = DefaultTemplates()
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &SMTPNotifier{config: config, templates: templates}
+}
+
+// Notify renders the message and sends it to the member
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Email == "" {
+		log.Printf("notify: %s has no email address, skipping %s message", msg.To, msg.Kind)
+		return nil
+	}
+	email, err := n.templates.Render(msg)
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(n.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", n.config.From, err)
+	}
+	to := &mail.Address{Name: msg.To, Address: msg.Email}
+	body, err := buildEmail(from, to, email)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.config.Timeout)
+	defer cancel()
+	return n.send(ctx, from.Address, to.Address, body)
+}
+
+// send delivers the email in a single SMTP session
+func (n *SMTPNotifier) send(ctx context.Context, from, to string, body []byte) error {
+	host, _, err := net.SplitHostPort(n.config.Addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.config.Addr)
+	if err != nil {
+		return err
+	}
+	// the deadline of the context also bounds the conversation with the server
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildEmail writes the email as a multipart/alternative message with its text and HTML versions
+func buildEmail(from, to *mail.Address, email Email) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+
+	// mail clients show the last version they understand, so the HTML one goes last
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", email.Text},
+		{"text/html; charset=utf-8", email.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
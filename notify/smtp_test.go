@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// received is an email the fake SMTP server got
+type received struct {
+	from, to string
+	data     string
+}
+
+// fakeSMTPServer speaks just enough SMTP to accept emails, it listens on localhost for the duration of the test
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	emails   []received
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	s := &fakeSMTPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 fake ESMTP")
+	var email received
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			email = received{from: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			email.to = strings.Trim(line[len("RCPT TO:"):], "<>")
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			email.data = data.String()
+			s.mu.Lock()
+			s.emails = append(s.emails, email)
+			s.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]received(nil), s.emails...)
+}
+
+func confirmation() Message {
+	return Message{
+		Kind: KindBookingConfirmed, To: "Meher <3", Email: "meher@example.com",
+		Details: Details{BookingID: "b1", ClassName: "Yoga", Date: "2024-10-02", StartTime: "09:00", DurationMinutes: 60, Instructor: "Asha", Spot: 4},
+	}
+}
+
+// the email is sent through the server with a text and an HTML version rendered from the default templates
+func TestSMTPNotifier(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	n := NewSMTPNotifier(SMTPConfig{Addr: server.listener.Addr().String(), From: "Studio <hello@studio.example.com>"}, nil)
+
+	if err := n.Notify(context.Background(), confirmation()); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+	emails := server.received()
+	if len(emails) != 1 || emails[0].from != "hello@studio.example.com" || emails[0].to != "meher@example.com" {
+		t.Fatalf("unexpected emails %+v", emails)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+	if err != nil {
+		t.Fatalf("could not read the email: %v", err)
+	}
+	if subject := msg.Header.Get("Subject"); subject != "You are booked for Yoga on 2024-10-02" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		bodies = append(bodies, string(body))
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected a text and an HTML part, got %d", len(bodies))
+	}
+	if !strings.Contains(bodies[0], "Hi Meher <3,") || !strings.Contains(bodies[0], "Your spot: 4") {
+		t.Errorf("unexpected text part:\n%s", bodies[0])
+	}
+	if !strings.Contains(bodies[1], "Hi Meher &lt;3,") || !strings.Contains(bodies[1], "<strong>Yoga</strong>") {
+		t.Errorf("expected the HTML part to be escaped:\n%s", bodies[1])
+	}
+}
+
+// members without an email address are skipped, and a server which is down fails the delivery
+func TestSMTPNotifier_NotSent(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	n := NewSMTPNotifier(SMTPConfig{Addr: server.listener.Addr().String(), From: "hello@studio.example.com"}, nil)
+	msg := confirmation()
+	msg.Email = ""
+	if err := n.Notify(context.Background(), msg); err != nil || len(server.received()) != 0 {
+		t.Errorf("expected nothing to be sent, got %v %+v", err, server.received())
+	}
+
+	server.listener.Close()
+	if err := n.Notify(context.Background(), confirmation()); err == nil {
+		t.Errorf("expected an error when the server is down")
+	}
+}
+
+// the studio can replace some of the templates, the others are the defaults
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "booking_confirmed.txt.tmpl"), []byte(`{{define "subject"}}See you at {{.Details.StartTime}}{{end}}Namaste {{.To}}`), 0o644)
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("could not load the templates: %v", err)
+	}
+
+	email, err := templates.Render(confirmation())
+	if err != nil || email.Subject != "See you at 09:00" || email.Text != "Namaste Meher <3" || !strings.Contains(email.HTML, "<strong>Yoga</strong>") {
+		t.Errorf("unexpected email %+v %v", email, err)
+	}
+	cancelled := confirmation()
+	cancelled.Kind = KindBookingCancelled
+	if email, _ := templates.Render(cancelled); email.Subject != "Your booking for Yoga on 2024-10-02 is cancelled" {
+		t.Errorf("expected the default cancellation template, got %q", email.Subject)
+	}
+
+	// templates without a subject or which do not parse are refused when they are loaded
+	os.WriteFile(filepath.Join(dir, "substitution.txt.tmpl"), []byte(`{{.To}}`), 0o644)
+	if _, err := LoadTemplates(dir); err == nil {
+		t.Errorf("expected an error for a template without a subject")
+	}
+	os.WriteFile(filepath.Join(dir, "substitution.txt.tmpl"), []byte(`{{define "subject"}}{{end}}{{.To`), 0o644)
+	if _, err := LoadTemplates(dir); err == nil {
+		t.Errorf("expected an error for a template which does not parse")
+	}
+}
+
+// every kind of message renders with the default templates
+func TestDefaultTemplates(t *testing.T) {
+	templates := DefaultTemplates()
+	for _, kind := range Kinds {
+		msg := confirmation()
+		msg.Kind = kind
+		if email, err := templates.Render(msg); err != nil || email.Subject == "" || email.Text == "" || email.HTML == "" {
+			t.Errorf("%s: unexpected email %+v %v", kind, email, err)
+		}
+	}
+}
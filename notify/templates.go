@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// defaultTemplates are the emails we send when the studio did not customize them
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Templates renders the emails of each kind of message. Every kind has a text/template file <kind>.txt.tmpl,
+// which also defines the "subject" template, and an html/template file <kind>.html.tmpl.
+// The templates get the Message as their data, e.g {{.To}} or {{.Details.ClassName}}.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// Email is a rendered message
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// DefaultTemplates returns the templates shipped with the studio
+func DefaultTemplates() *Templates {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// LoadTemplates parses the templates of dir, the files dir does not have are taken from the defaults.
+// An empty dir loads the defaults only.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{text: make(map[string]*texttemplate.Template), html: make(map[string]*htmltemplate.Template)}
+	for _, kind := range Kinds {
+		content, err := readTemplate(dir, kind+".txt.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		text, err := texttemplate.New(kind).Option("missingkey=error").Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s.txt.tmpl: %w", kind, err)
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s.txt.tmpl does not define the subject template", kind)
+		}
+		t.text[kind] = text
+
+		content, err = readTemplate(dir, kind+".html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if t.html[kind], err = htmltemplate.New(kind).Option("missingkey=error").Parse(content); err != nil {
+			return nil, fmt.Errorf("parsing %s.html.tmpl: %w", kind, err)
+		}
+	}
+	return t, nil
+}
+
+// readTemplate reads the file from dir, or from the defaults when dir does not have it
+func readTemplate(dir, name string) (string, error) {
+	if dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	content, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// Render renders the email of the message
+func (t *Templates) Render(msg Message) (Email, error) {
+	text, found := t.text[msg.Kind]
+	if !found {
+		return Email{}, fmt.Errorf("no template for %s messages", msg.Kind)
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", msg); err != nil {
+		return Email{}, err
+	}
+	if err := text.Execute(&body, msg); err != nil {
+		return Email{}, err
+	}
+	if err := t.html[msg.Kind].Execute(&html, msg); err != nil {
+		return Email{}, err
+	}
+	// a subject spanning several lines would break the headers
+	return Email{Subject: strings.Join(strings.Fields(subject.String()), " "), Text: body.String(), HTML: html.String()}, nil
+}
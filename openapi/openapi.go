@@ -0,0 +1,281 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the OpenAPI specification our documents follow
+const Version = "3.1.0"
+
+// Document is the root of an OpenAPI document, we only model the parts we use
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem holds the operations of a path keyed by the lower case method
+type PathItem map[string]*Operation
+
+// Components holds the schemas referenced from operations
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Operation describes a single method on a path
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the payload an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body for a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Route documents a single route of the router.
+// Request and the response bodies are zero values of the Go types, their schemas are generated by reflection.
+type Route struct {
+	Method     string
+	Path       string
+	Summary    string
+	Deprecated bool
+	// Query lists the query parameters the route accepts
+	Query []Parameter
+	// Request is the body the route accepts, nil when it takes none
+	Request any
+	// RequestContentTypes are the content types the body can be sent as, defaults to application/json.
+	// For row based formats like CSV the Request type describes a single row.
+	RequestContentTypes []string
+	// Responses maps status codes to their description and body
+	Responses map[int]ResponseSpec
+}
+
+// ResponseSpec documents one response of a Route
+type ResponseSpec struct {
+	Description string
+	// ContentType defaults to application/json when a Body is given
+	ContentType string
+	// Body is a zero value of the response Go type, a string for plain text responses or nil for no body
+	Body any
+	// Alternatives are other representations of the body keyed by content type, picked with the Accept header
+	Alternatives map[string]any
+}
+
+// Build generates the document for the given routes
+func Build(info Info, routes []Route) *Document {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+
+	for _, route := range routes {
+		op := &Operation{
+			Summary:    route.Summary,
+			Deprecated: route.Deprecated,
+			Parameters: append(pathParameters(route.Path), route.Query...),
+			Responses:  make(map[string]*Response),
+		}
+
+		if route.Request != nil {
+			contentTypes := route.RequestContentTypes
+			if len(contentTypes) == 0 {
+				contentTypes = []string{"application/json"}
+			}
+			op.RequestBody = &RequestBody{Required: true, Content: make(map[string]MediaType)}
+			for _, contentType := range contentTypes {
+				op.RequestBody.Content[contentType] = MediaType{Schema: doc.schemaFor(reflect.TypeOf(route.Request))}
+			}
+		}
+
+		for status, spec := range route.Responses {
+			response := &Response{Description: spec.Description}
+			if spec.Body != nil {
+				contentType := spec.ContentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				response.Content = map[string]MediaType{contentType: {Schema: doc.schemaFor(reflect.TypeOf(spec.Body))}}
+			}
+			for contentType, body := range spec.Alternatives {
+				if response.Content == nil {
+					response.Content = make(map[string]MediaType)
+				}
+				response.Content[contentType] = MediaType{Schema: doc.schemaFor(reflect.TypeOf(body))}
+			}
+			op.Responses[strconv.Itoa(status)] = response
+		}
+
+		item, ok := doc.Paths[route.Path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+// HasOperation reports whether the document describes the method on the path
+func (doc *Document) HasOperation(method, path string) bool {
+	_, ok := doc.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// Handler serves the document as JSON
+func Handler(doc *Document) http.Handler {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "Failed to encode the OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// pathParameters documents the {name} segments of a chi route pattern, which uses the same syntax as OpenAPI
+func pathParameters(path string) []Parameter {
+	var params []Parameter
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, Parameter{
+				Name:     strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	return params
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the schema of a Go type, named structs are added to the components and referenced
+func (doc *Document) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := doc.Components.Schemas[t.Name()]; !ok {
+			// registering a placeholder first so that recursive types dont loop forever
+			doc.Components.Schemas[t.Name()] = &Schema{}
+			*doc.Components.Schemas[t.Name()] = *doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Struct:
+		return doc.structSchema(t)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schemaFor(t.Elem())}
+	}
+	return &Schema{}
+}
+
+// structSchema builds an object schema from the json tags of the struct fields.
+// Fields are required unless they are tagged with omitempty or validate:"optional".
+// Embedded structs without a json name are flattened like encoding/json does.
+func (doc *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// the fields of embedded structs are promoted even when the embedded type itself is unexported
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := doc.structSchema(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				if _, ok := schema.Properties[property]; !ok {
+					schema.Properties[property] = propertySchema
+				}
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = doc.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Tag.Get("validate") != "optional" {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
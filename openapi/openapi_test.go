@@ -0,0 +1,94 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testBooking struct {
+	Name     string    `json:"name"`
+	Date     string    `json:"date"`
+	Spot     int       `json:"spot,omitempty"`
+	Internal string    `json:"-"`
+	Created  time.Time `json:"created_at"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// checking that schemas are generated from the json tags of the types
+func TestBuild(t *testing.T) {
+	doc := Build(Info{Title: "Test", Version: "1"}, []Route{
+		{
+			Method:  http.MethodPost,
+			Path:    "/members/{id}/bookings",
+			Request: testBooking{},
+			Responses: map[int]ResponseSpec{
+				http.StatusCreated:    {Description: "created", Body: testBooking{}},
+				http.StatusBadRequest: {Description: "invalid", ContentType: "text/plain", Body: ""},
+			},
+		},
+	})
+
+	if !doc.HasOperation(http.MethodPost, "/members/{id}/bookings") {
+		t.Fatalf("expected the operation to be documented")
+	}
+	if doc.HasOperation(http.MethodGet, "/members/{id}/bookings") {
+		t.Errorf("expected GET not to be documented")
+	}
+
+	op := doc.Paths["/members/{id}/bookings"]["post"]
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" {
+		t.Errorf("expected the id path parameter, got %+v", op.Parameters)
+	}
+	if ref := op.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/testBooking" {
+		t.Errorf("expected the request to reference testBooking, got %s", ref)
+	}
+	if op.Responses["400"].Content["text/plain"].Schema.Type != "string" {
+		t.Errorf("expected a plain text 400 response")
+	}
+
+	schema := doc.Components.Schemas["testBooking"]
+	expectedRequired := []string{"created_at", "date", "name"}
+	if !reflect.DeepEqual(schema.Required, expectedRequired) {
+		t.Errorf("expected required %v, got %v", expectedRequired, schema.Required)
+	}
+	if _, ok := schema.Properties["Internal"]; ok {
+		t.Errorf("expected fields tagged with - to be skipped")
+	}
+	if schema.Properties["created_at"].Format != "date-time" {
+		t.Errorf("expected time fields to be date-time strings")
+	}
+	if schema.Properties["tags"].Items.Type != "string" {
+		t.Errorf("expected tags to be an array of strings")
+	}
+}
+
+type testProblem struct {
+	testBooking
+	Code string `json:"code"`
+}
+
+// embedded structs are flattened into the schema of the outer struct like encoding/json does
+func TestBuild_EmbeddedStruct(t *testing.T) {
+	doc := Build(Info{Title: "Test", Version: "1"}, []Route{
+		{
+			Method:    http.MethodGet,
+			Path:      "/problem",
+			Responses: map[int]ResponseSpec{http.StatusConflict: {Description: "conflict", Body: testProblem{}}},
+		},
+	})
+
+	schema := doc.Components.Schemas["testProblem"]
+	if schema == nil {
+		t.Fatalf("expected testProblem to be in the components")
+	}
+	if _, ok := schema.Properties["testBooking"]; ok {
+		t.Errorf("expected the embedded struct to be flattened, got %v", schema.Properties)
+	}
+	for _, name := range []string{"name", "date", "created_at", "code"} {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("expected property %s, got %v", name, schema.Properties)
+		}
+	}
+}
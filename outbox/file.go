@@ -0,0 +1,190 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// operations written to the journal of a FileStore
+const (
+	opAdd       = "add"
+	opDelivered = "delivered"
+	opRemoved   = "removed"
+	opFailed    = "failed"
+)
+
+// entry is a line of the journal, the records of an add are written on a single line so that they are added together
+type entry struct {
+	Op            string    `json:"op"`
+	Records       []Record  `json:"records,omitempty"`
+	ID            string    `json:"id,omitempty"`
+	Consumer      string    `json:"consumer,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// FileStore keeps the records in a journal file, one JSON line per change, so that they survive a restart.
+// Every change is synced to disk before it is acknowledged. The journal is compacted when it is opened.
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	memory *MemoryStore
+}
+
+// OpenFileStore opens the journal at path, creating it if needed, and loads the records which were not delivered
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, memory: NewMemoryStore()}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+	return s, nil
+}
+
+// load replays the journal into memory
+func (s *FileStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// the process can die while writing the last line, the change it was writing was never acknowledged
+			log.Printf("outbox: skipping line %d of %s: %v", line, s.path, err)
+			continue
+		}
+		s.apply(e)
+	}
+	return scanner.Err()
+}
+
+func (s *FileStore) apply(e entry) {
+	switch e.Op {
+	case opAdd:
+		s.memory.Add(e.Records...)
+	case opDelivered:
+		// the journals written before the deliveries were tracked by consumer removed the record
+		if e.Consumer == "" {
+			s.memory.Remove(e.ID)
+		} else {
+			s.memory.MarkDelivered(e.ID, e.Consumer)
+		}
+	case opRemoved:
+		s.memory.Remove(e.ID)
+	case opFailed:
+		s.memory.MarkFailed(e.ID, e.Error, e.NextAttemptAt)
+	}
+}
+
+// compact rewrites the journal with only the pending records, replacing it atomically
+func (s *FileStore) compact() error {
+	records, _ := s.memory.Pending()
+	tmp := s.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, record := range records {
+		line, err := json.Marshal(entry{Op: opAdd, Records: []Record{record}})
+		if err != nil {
+			file.Close()
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// write appends the entry to the journal and syncs it, then applies it in memory
+func (s *FileStore) write(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("outbox: %s is closed", s.path)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.apply(e)
+	return nil
+}
+
+// Add appends the records to the journal
+func (s *FileStore) Add(records ...Record) error {
+	return s.write(entry{Op: opAdd, Records: records})
+}
+
+// Pending returns the records which were not delivered yet
+func (s *FileStore) Pending() ([]Record, error) {
+	return s.memory.Pending()
+}
+
+// MarkDelivered writes the delivery of the record to the consumer to the journal
+func (s *FileStore) MarkDelivered(id, consumer string) error {
+	return s.write(entry{Op: opDelivered, ID: id, Consumer: consumer})
+}
+
+// Remove writes the removal of the record to the journal
+func (s *FileStore) Remove(id string) error {
+	return s.write(entry{Op: opRemoved, ID: id})
+}
+
+// MarkFailed writes the failed attempt of the record to the journal
+func (s *FileStore) MarkFailed(id string, reason string, next time.Time) error {
+	return s.write(entry{Op: opFailed, ID: id, Error: reason, NextAttemptAt: next})
+}
+
+// Close closes the journal, the store cannot be used anymore
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
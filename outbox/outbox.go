@@ -0,0 +1,407 @@
+// Package outbox keeps the events of the storage changes until they are delivered.
+// The events are added to the outbox in the same critical section as the change they describe, and a Dispatcher
+// hands them to their consumers in the background. A record is kept until every consumer acknowledged it, which
+// asynchronous consumers only do once their work went out, and a consumer which failed is the only one which gets the
+// record again, so that an event is never lost between the change and its delivery nor handled twice by a consumer
+// because another one failed. With a FileStore the records which were not delivered yet are delivered again after a
+// restart to the consumers which did not acknowledge them, which makes the delivery at-least-once: the consumers
+// should drop the events whose id they have already seen.
+//
+// Only the events are kept: the storage change they describe is not, it is lost with the process unless the storage
+// itself is persisted.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+)
+
+// Record is an event waiting in the outbox
+type Record struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Key orders the records, a record is only delivered once the records with the same key before it were
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Delivered are the consumers which acknowledged the record, the others get it again
+	Delivered []string `json:"delivered,omitempty"`
+	// Attempts, LastError and NextAttemptAt describe the failed deliveries of the record
+	Attempts      int       `json:"attempts,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// Store keeps the records until they are delivered
+type Store interface {
+	// Add stores the records after the ones already there, either all of them or none
+	Add(records ...Record) error
+	// Pending returns the records which were not delivered yet, in the order they were added
+	Pending() ([]Record, error)
+	// MarkDelivered records that the consumer acknowledged the record
+	MarkDelivered(id, consumer string) error
+	// Remove removes the record once every consumer acknowledged it
+	Remove(id string) error
+	// MarkFailed records a failed delivery of the record and when to attempt it again
+	MarkFailed(id string, reason string, next time.Time) error
+}
+
+// MemoryStore keeps the records in memory, they are lost with the process
+type MemoryStore struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add appends the records
+func (s *MemoryStore) Add(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Pending returns a copy of the records
+func (s *MemoryStore) Pending() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Record(nil), s.records...), nil
+}
+
+// MarkDelivered adds the consumer to the consumers of the record, unknown ids are ignored
+func (s *MemoryStore) MarkDelivered(id, consumer string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.records {
+		if s.records[i].ID == id && !contains(s.records[i].Delivered, consumer) {
+			s.records[i].Delivered = append(s.records[i].Delivered, consumer)
+			break
+		}
+	}
+	return nil
+}
+
+// Remove removes the record, unknown ids are ignored
+func (s *MemoryStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, record := range s.records {
+		if record.ID == id {
+			s.records = append(s.records[:i], s.records[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// MarkFailed counts the failed attempt of the record, unknown ids are ignored
+func (s *MemoryStore) MarkFailed(id string, reason string, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.records {
+		if s.records[i].ID == id {
+			s.records[i].Attempts++
+			s.records[i].LastError = reason
+			s.records[i].NextAttemptAt = next
+			break
+		}
+	}
+	return nil
+}
+
+// Consumers hand the records to the consumers of their type, e.g the subscribers of an event bus
+type Consumers interface {
+	// Names returns the consumers of the records of the type
+	Names(recordType string) []string
+	// Deliver hands the record to the consumer, which calls ack once it is done with it, possibly later from another
+	// goroutine. ack is called with the error which failed the record, the record is then attempted again.
+	Deliver(ctx context.Context, consumer string, record Record, ack func(err error))
+}
+
+// backoff between the attempts of a record which failed, doubling from MinBackoff up to MaxBackoff
+const (
+	MinBackoff = time.Second
+	MaxBackoff = 5 * time.Minute
+)
+
+// Dispatcher delivers the records of a store in the order they were added
+type Dispatcher struct {
+	store     Store
+	consumers Consumers
+	clock     clock.Clock
+	// wake is signalled by Add so that Run delivers new records right away instead of at the next tick
+	wake chan struct{}
+	// mu makes sure only one DeliverPending runs at a time, so that the records of a key stay in order
+	mu sync.Mutex
+
+	// progress tracks the records handed to their consumers, the acks update it from other goroutines
+	progressMu sync.Mutex
+	progress   map[string]*progress
+}
+
+// progress is where the delivery of a record to its consumers is at
+type progress struct {
+	consumers []string
+	// done are the consumers which acknowledged the record, inflight the ones it was handed to which did not yet
+	done     map[string]bool
+	inflight map[string]bool
+	// failed are the consumers which failed the record since it was last handed to them
+	failed   map[string]bool
+	attempts int
+	// removed is set once the record was removed from the store
+	removed bool
+}
+
+// finished reports whether every consumer acknowledged the record
+func (p *progress) finished() bool {
+	for _, consumer := range p.consumers {
+		if !p.done[consumer] {
+			return false
+		}
+	}
+	return true
+}
+
+// NewDispatcher returns a dispatcher delivering the records of store to their consumers
+func NewDispatcher(store Store, consumers Consumers, clk clock.Clock) *Dispatcher {
+	return &Dispatcher{
+		store:     store,
+		consumers: consumers,
+		clock:     clk,
+		wake:      make(chan struct{}, 1),
+		progress:  make(map[string]*progress),
+	}
+}
+
+// Add stores the records and wakes up Run to deliver them
+func (d *Dispatcher) Add(records ...Record) error {
+	if err := d.store.Add(records...); err != nil {
+		return err
+	}
+	d.signal()
+	return nil
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the pending records when it starts, when records are added and every interval
+// to retry the failed ones, until the context is done
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := d.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.DeliverPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C():
+		}
+	}
+}
+
+// DeliverPending hands the records which are due to the consumers which did not acknowledge them yet, and returns
+// how many records every consumer is done with. A record which failed holds back the records with the same key after it
+// for the consumers which failed it, until they took it. A record handed to an asynchronous consumer does not, the
+// consumer is expected to keep the records it is given in order.
+func (d *Dispatcher) DeliverPending(ctx context.Context) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	records, err := d.store.Pending()
+	if err != nil {
+		log.Printf("outbox: could not read the pending records: %v", err)
+		return 0
+	}
+	d.forgetRemoved(records)
+
+	now := d.clock.Now()
+	// held are the keys held back for a consumer, by key and consumer
+	held := make(map[[2]string]bool)
+	delivered := 0
+	for _, record := range records {
+		if ctx.Err() != nil {
+			break
+		}
+		p := d.track(record)
+
+		d.progressMu.Lock()
+		if p.removed {
+			d.progressMu.Unlock()
+			continue
+		}
+		var remaining, todo []string
+		for _, consumer := range p.consumers {
+			if p.done[consumer] {
+				continue
+			}
+			remaining = append(remaining, consumer)
+			if !p.inflight[consumer] {
+				todo = append(todo, consumer)
+			}
+		}
+		d.progressMu.Unlock()
+
+		if len(remaining) == 0 {
+			d.remove(record.ID, p)
+			delivered++
+			continue
+		}
+		if now.Before(record.NextAttemptAt) {
+			for _, consumer := range remaining {
+				held[[2]string{record.Key, consumer}] = true
+			}
+			continue
+		}
+
+		for _, consumer := range todo {
+			if held[[2]string{record.Key, consumer}] {
+				continue
+			}
+			d.progressMu.Lock()
+			p.inflight[consumer] = true
+			delete(p.failed, consumer)
+			d.progressMu.Unlock()
+
+			d.consumers.Deliver(ctx, consumer, record, d.ack(record, consumer))
+
+			// synchronous consumers are done with the record already
+			d.progressMu.Lock()
+			if p.failed[consumer] {
+				held[[2]string{record.Key, consumer}] = true
+			}
+			d.progressMu.Unlock()
+		}
+
+		d.progressMu.Lock()
+		removed := p.removed
+		d.progressMu.Unlock()
+		if removed {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// track returns the progress of the record, starting it from what the store knows when the record is new to us
+func (d *Dispatcher) track(record Record) *progress {
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+	if p, found := d.progress[record.ID]; found {
+		return p
+	}
+	p := &progress{
+		consumers: d.consumers.Names(record.Type),
+		done:      make(map[string]bool),
+		inflight:  make(map[string]bool),
+		failed:    make(map[string]bool),
+		attempts:  record.Attempts,
+	}
+	for _, consumer := range record.Delivered {
+		p.done[consumer] = true
+	}
+	d.progress[record.ID] = p
+	return p
+}
+
+// forgetRemoved drops the progress of the records which are not pending anymore. The progress of a removed record
+// is kept until then, so that a record read before it was removed is not handed to its consumers again.
+func (d *Dispatcher) forgetRemoved(pending []Record) {
+	ids := make(map[string]bool, len(pending))
+	for _, record := range pending {
+		ids[record.ID] = true
+	}
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+	for id, p := range d.progress {
+		if p.removed && !ids[id] {
+			delete(d.progress, id)
+		}
+	}
+}
+
+// ack returns the function the consumer calls once it is done with the record, only its first call counts
+func (d *Dispatcher) ack(record Record, consumer string) func(err error) {
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { d.acknowledge(record, consumer, err) })
+	}
+}
+
+// acknowledge records that the consumer is done with the record, and removes the record once every consumer is
+func (d *Dispatcher) acknowledge(record Record, consumer string, err error) {
+	d.progressMu.Lock()
+	p := d.progress[record.ID]
+	delete(p.inflight, consumer)
+	if err != nil {
+		p.failed[consumer] = true
+		p.attempts++
+		attempts := p.attempts
+		d.progressMu.Unlock()
+
+		next := d.clock.Now().Add(Backoff(attempts))
+		log.Printf("outbox: %s could not take %s record %s, attempt %d: %v", consumer, record.Type, record.ID, attempts, err)
+		if err := d.store.MarkFailed(record.ID, consumer+": "+err.Error(), next); err != nil {
+			log.Printf("outbox: could not mark record %s as failed: %v", record.ID, err)
+		}
+		return
+	}
+	p.done[consumer] = true
+	finished := p.finished()
+	d.progressMu.Unlock()
+
+	// a record we could not mark is delivered again to the consumer later, which at-least-once allows
+	if err := d.store.MarkDelivered(record.ID, consumer); err != nil {
+		log.Printf("outbox: could not mark record %s as delivered to %s: %v", record.ID, consumer, err)
+	}
+	if finished {
+		d.remove(record.ID, p)
+	}
+}
+
+// remove removes the record every consumer is done with
+func (d *Dispatcher) remove(id string, p *progress) {
+	if err := d.store.Remove(id); err != nil {
+		log.Printf("outbox: could not remove record %s: %v", id, err)
+		return
+	}
+	d.progressMu.Lock()
+	p.removed = true
+	d.progressMu.Unlock()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns how long to wait before attempting a record again after its failed attempt
+func Backoff(attempt int) time.Duration {
+	backoff := MinBackoff
+	for i := 1; i < attempt && backoff < MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxBackoff {
+		return MaxBackoff
+	}
+	return backoff
+}
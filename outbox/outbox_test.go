@@ -0,0 +1,235 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/clock"
+)
+
+func record(id, key string) Record {
+	return Record{ID: id, Type: "booking.created", Key: key, Payload: []byte(`{"name":"` + id + `"}`)}
+}
+
+// recorder is a consumer recording the records it is given, failing the ids in fail.
+// An asynchronous recorder leaves the acks to the test.
+type recorder struct {
+	delivered []string
+	fail      map[string]bool
+	async     bool
+	acks      []func(error)
+}
+
+func (r *recorder) deliver(record Record, ack func(error)) {
+	if r.fail[record.ID] {
+		ack(errors.New("receiver is down"))
+		return
+	}
+	r.delivered = append(r.delivered, record.ID)
+	if r.async {
+		r.acks = append(r.acks, ack)
+		return
+	}
+	ack(nil)
+}
+
+// consumers hands the records to the recorders by name
+type consumers map[string]*recorder
+
+func (c consumers) Names(recordType string) []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c consumers) Deliver(ctx context.Context, consumer string, record Record, ack func(error)) {
+	c[consumer].deliver(record, ack)
+}
+
+// records are delivered in order and removed once delivered
+func TestDispatcher_DeliverPending(t *testing.T) {
+	store := NewMemoryStore()
+	rec := &recorder{}
+	d := NewDispatcher(store, consumers{"recorder": rec}, clock.NewFake(time.Now()))
+	for _, id := range []string{"r1", "r2", "r3"} {
+		d.Add(record(id, "c1"))
+	}
+
+	if delivered := d.DeliverPending(context.Background()); delivered != 3 || fmt.Sprint(rec.delivered) != "[r1 r2 r3]" {
+		t.Errorf("expected the records in order, got %d %v", delivered, rec.delivered)
+	}
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("expected no pending records, got %+v", pending)
+	}
+}
+
+// a failed record holds back the records of its key until it is delivered after its backoff
+func TestDispatcher_FailedRecord(t *testing.T) {
+	store := NewMemoryStore()
+	rec := &recorder{fail: map[string]bool{"r1": true}}
+	fake := clock.NewFake(time.Now())
+	d := NewDispatcher(store, consumers{"recorder": rec}, fake)
+	d.Add(record("r1", "c1"))
+	d.Add(record("r2", "c2"))
+	d.Add(record("r3", "c1"))
+
+	if delivered := d.DeliverPending(context.Background()); delivered != 1 || fmt.Sprint(rec.delivered) != "[r2]" {
+		t.Fatalf("expected only the other class to be delivered, got %v", rec.delivered)
+	}
+	pending, _ := store.Pending()
+	if len(pending) != 2 || pending[0].Attempts != 1 || pending[0].LastError != "recorder: receiver is down" {
+		t.Fatalf("unexpected pending records %+v", pending)
+	}
+
+	// nothing is attempted before the backoff is over
+	rec.fail = nil
+	if delivered := d.DeliverPending(context.Background()); delivered != 0 {
+		t.Errorf("expected the backoff to be respected, got %d deliveries", delivered)
+	}
+	fake.Advance(MinBackoff)
+	if delivered := d.DeliverPending(context.Background()); delivered != 2 || fmt.Sprint(rec.delivered) != "[r2 r1 r3]" {
+		t.Errorf("expected the class to be delivered in order, got %v", rec.delivered)
+	}
+}
+
+// a record is only delivered again to the consumers which failed it
+func TestDispatcher_FailedConsumer(t *testing.T) {
+	store := NewMemoryStore()
+	metrics, webhooks := &recorder{}, &recorder{fail: map[string]bool{"r1": true}}
+	fake := clock.NewFake(time.Now())
+	d := NewDispatcher(store, consumers{"metrics": metrics, "webhooks": webhooks}, fake)
+	d.Add(record("r1", "c1"))
+	d.Add(record("r2", "c1"))
+
+	if delivered := d.DeliverPending(context.Background()); delivered != 0 || fmt.Sprint(metrics.delivered) != "[r1 r2]" {
+		t.Fatalf("expected the other consumer to take both records, got %d %v", delivered, metrics.delivered)
+	}
+	pending, _ := store.Pending()
+	if len(pending) != 2 || fmt.Sprint(pending[0].Delivered) != "[metrics]" || pending[0].Attempts != 1 {
+		t.Fatalf("unexpected pending records %+v", pending)
+	}
+
+	webhooks.fail = nil
+	fake.Advance(MinBackoff)
+	if delivered := d.DeliverPending(context.Background()); delivered != 2 || fmt.Sprint(webhooks.delivered) != "[r1 r2]" {
+		t.Errorf("expected the failed consumer to get the records in order, got %d %v", delivered, webhooks.delivered)
+	}
+	if fmt.Sprint(metrics.delivered) != "[r1 r2]" {
+		t.Errorf("expected the other consumer not to get the records again, got %v", metrics.delivered)
+	}
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("expected no pending records, got %+v", pending)
+	}
+}
+
+// a record stays in the outbox until the asynchronous consumers acknowledged it, and is not handed to them twice meanwhile
+func TestDispatcher_Ack(t *testing.T) {
+	store := NewMemoryStore()
+	fake := clock.NewFake(time.Now())
+	mail := &recorder{async: true}
+	d := NewDispatcher(store, consumers{"mail": mail}, fake)
+	d.Add(record("r1", "c1"))
+
+	d.DeliverPending(context.Background())
+	d.DeliverPending(context.Background())
+	if pending, _ := store.Pending(); len(pending) != 1 || len(mail.delivered) != 1 {
+		t.Fatalf("expected the record to wait for its ack, got %+v %v", pending, mail.delivered)
+	}
+
+	mail.acks[0](errors.New("mail server is down"))
+	fake.Advance(MinBackoff)
+	d.DeliverPending(context.Background())
+	if len(mail.delivered) != 2 {
+		t.Fatalf("expected the failed record to be handed again, got %v", mail.delivered)
+	}
+	mail.acks[1](nil)
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("expected the record to be removed once acknowledged, got %+v", pending)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: MaxBackoff} {
+		if backoff := Backoff(attempt); backoff != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, backoff)
+		}
+	}
+}
+
+// the records which were not delivered are still there after reopening the file, and the journal is compacted
+func TestFileStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("could not open the store: %v", err)
+	}
+	next := time.Date(2024, time.October, 1, 12, 0, 0, 0, time.UTC)
+	store.Add(record("r1", "c1"))
+	store.Add(record("r2", "c1"))
+	store.Add(record("r3", "c2"))
+	store.MarkDelivered("r1", "metrics")
+	store.Remove("r1")
+	store.MarkDelivered("r2", "metrics")
+	store.MarkFailed("r2", "receiver is down", next)
+	store.Close()
+
+	// a crash while writing leaves half a line at the end of the journal
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	file.WriteString(`{"op":"add","records":[{"id":"r4"`)
+	file.Close()
+
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("could not reopen the store: %v", err)
+	}
+	defer store.Close()
+
+	pending, _ := store.Pending()
+	if len(pending) != 2 || pending[0].ID != "r2" || pending[0].Attempts != 1 || !pending[0].NextAttemptAt.Equal(next) ||
+		fmt.Sprint(pending[0].Delivered) != "[metrics]" ||
+		pending[1].ID != "r3" || string(pending[1].Payload) != `{"name":"r3"}` {
+		t.Fatalf("unexpected pending records %+v", pending)
+	}
+	content, _ := os.ReadFile(path)
+	if lines := strings.Count(string(content), "\n"); lines != 2 {
+		t.Errorf("expected the journal to be compacted to 2 lines, got %d:\n%s", lines, content)
+	}
+
+	// the reopened store keeps writing to the journal
+	store.Remove("r3")
+	store.Close()
+	store, _ = OpenFileStore(path)
+	defer store.Close()
+	if pending, _ := store.Pending(); len(pending) != 1 || pending[0].ID != "r2" {
+		t.Errorf("unexpected pending records %+v", pending)
+	}
+}
+
+// the records added together are written on one line, a crash while writing it loses all of them and none is half added
+func TestFileStore_AddTogether(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	store, _ := OpenFileStore(path)
+	if err := store.Add(record("r1", "c1"), record("r2", "c2")); err != nil {
+		t.Fatalf("could not add the records: %v", err)
+	}
+	store.Close()
+
+	content, _ := os.ReadFile(path)
+	os.WriteFile(path, append(content, content[:len(content)/2]...), 0o644)
+
+	store, _ = OpenFileStore(path)
+	defer store.Close()
+	if pending, _ := store.Pending(); len(pending) != 2 || pending[0].ID != "r1" || pending[1].ID != "r2" {
+		t.Errorf("expected only the records of the complete line, got %+v", pending)
+	}
+}
@@ -0,0 +1,133 @@
+// Package payments takes payments from members through a pluggable PaymentGateway.
+// A payment is authorized first, which reserves the amount, and captured once the booking it pays for is confirmed.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// errors returned by the gateways
+var (
+	// ErrDeclined is returned when the payment method of the member was declined
+	ErrDeclined = errors.New("payment declined")
+	// ErrNotFound is returned for a payment the gateway does not know
+	ErrNotFound = errors.New("payment not found")
+	// ErrInvalidState is returned when a payment cannot be captured or refunded in its current state
+	ErrInvalidState = errors.New("payment cannot be changed in its current state")
+)
+
+// Charge describes a payment to authorize
+type Charge struct {
+	AmountCents int
+	// Token identifies the payment method of the member at the provider
+	Token string
+	// Reference is our id for what is paid, e.g the booking id
+	Reference   string
+	Description string
+}
+
+// PaymentGateway takes payments through a payment provider
+type PaymentGateway interface {
+	// Authorize reserves the amount of the charge and returns the id of the payment
+	Authorize(ctx context.Context, charge Charge) (string, error)
+	// Capture takes the amount authorized for the payment
+	Capture(ctx context.Context, paymentID string) error
+	// Void releases the amount authorized for a payment which was not captured
+	Void(ctx context.Context, paymentID string) error
+	// Refund gives the captured amount of the payment back
+	Refund(ctx context.Context, paymentID string) error
+}
+
+// tokens understood by the Fake gateway, any other token is accepted
+const (
+	// TokenDeclined is declined when authorizing
+	TokenDeclined = "tok_declined"
+	// TokenCaptureFails is authorized but fails when captured
+	TokenCaptureFails = "tok_capture_fails"
+)
+
+// statuses of the payments of the Fake gateway
+const (
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusRefunded   = "refunded"
+	StatusVoided     = "voided"
+)
+
+// Payment is a payment made through the Fake gateway
+type Payment struct {
+	ID     string
+	Charge Charge
+	Status string
+}
+
+// Fake is a deterministic in-process gateway for local development and tests.
+// Payment ids are numbered in the order the payments were authorized and the outcome only depends on the token.
+type Fake struct {
+	mu       sync.Mutex
+	payments []Payment
+}
+
+// NewFake returns a fake gateway without any payment
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Authorize declines TokenDeclined and authorizes anything else
+func (f *Fake) Authorize(ctx context.Context, charge Charge) (string, error) {
+	if charge.Token == TokenDeclined {
+		return "", ErrDeclined
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment := Payment{ID: fmt.Sprintf("pay_%d", len(f.payments)+1), Charge: charge, Status: StatusAuthorized}
+	f.payments = append(f.payments, payment)
+	return payment.ID, nil
+}
+
+// Capture captures an authorized payment, payments authorized with TokenCaptureFails are declined
+func (f *Fake) Capture(ctx context.Context, paymentID string) error {
+	return f.transition(paymentID, StatusAuthorized, StatusCaptured)
+}
+
+// Void voids an authorized payment
+func (f *Fake) Void(ctx context.Context, paymentID string) error {
+	return f.transition(paymentID, StatusAuthorized, StatusVoided)
+}
+
+// Refund refunds a captured payment
+func (f *Fake) Refund(ctx context.Context, paymentID string) error {
+	return f.transition(paymentID, StatusCaptured, StatusRefunded)
+}
+
+func (f *Fake) transition(paymentID, from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.payments {
+		payment := &f.payments[i]
+		if payment.ID != paymentID {
+			continue
+		}
+		if payment.Status != from {
+			return ErrInvalidState
+		}
+		if to == StatusCaptured && payment.Charge.Token == TokenCaptureFails {
+			return ErrDeclined
+		}
+		payment.Status = to
+		return nil
+	}
+	return ErrNotFound
+}
+
+// Payments returns a copy of the payments made through the gateway in the order they were authorized
+func (f *Fake) Payments() []Payment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Payment(nil), f.payments...)
+}
@@ -0,0 +1,67 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// a payment goes from authorized to captured to refunded
+func TestFake_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	id, err := fake.Authorize(ctx, Charge{AmountCents: 2000, Token: "tok_visa", Reference: "b1"})
+	if err != nil || id != "pay_1" {
+		t.Fatalf("expected the first payment to be authorized, got %q, %v", id, err)
+	}
+	if err := fake.Refund(ctx, id); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected an authorized payment not to be refunded, got %v", err)
+	}
+	if err := fake.Capture(ctx, id); err != nil {
+		t.Fatalf("expected the payment to be captured, got %v", err)
+	}
+	if err := fake.Refund(ctx, id); err != nil {
+		t.Fatalf("expected the payment to be refunded, got %v", err)
+	}
+
+	payments := fake.Payments()
+	if len(payments) != 1 || payments[0].Status != StatusRefunded || payments[0].Charge.Reference != "b1" {
+		t.Errorf("unexpected payments %+v", payments)
+	}
+	if err := fake.Capture(ctx, "pay_2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unknown payment not to be found, got %v", err)
+	}
+}
+
+// the outcome of a payment only depends on its token
+func TestFake_Tokens(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	if _, err := fake.Authorize(ctx, Charge{AmountCents: 2000, Token: TokenDeclined}); !errors.Is(err, ErrDeclined) {
+		t.Errorf("expected the payment to be declined, got %v", err)
+	}
+
+	id, err := fake.Authorize(ctx, Charge{AmountCents: 2000, Token: TokenCaptureFails})
+	if err != nil {
+		t.Fatalf("expected the payment to be authorized, got %v", err)
+	}
+	if err := fake.Capture(ctx, id); !errors.Is(err, ErrDeclined) {
+		t.Errorf("expected the capture to be declined, got %v", err)
+	}
+	if payments := fake.Payments(); len(payments) != 1 || payments[0].Status != StatusAuthorized {
+		t.Errorf("unexpected payments %+v", payments)
+	}
+
+	// the authorization which could not be captured is released
+	if err := fake.Void(ctx, id); err != nil {
+		t.Fatalf("expected the payment to be voided, got %v", err)
+	}
+	if err := fake.Capture(ctx, id); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected a voided payment not to be captured, got %v", err)
+	}
+	if payments := fake.Payments(); payments[0].Status != StatusVoided {
+		t.Errorf("unexpected payments %+v", payments)
+	}
+}
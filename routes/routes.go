@@ -2,20 +2,165 @@ package routes
 
 import (
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/MeherKandukuri/studioClasses_API/handlers"
+	"github.com/MeherKandukuri/studioClasses_API/metrics"
+	"github.com/MeherKandukuri/studioClasses_API/middleware"
+	"github.com/MeherKandukuri/studioClasses_API/openapi"
 	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
 )
 
+// Config holds the settings used while building the router
+type Config struct {
+	// CORS configures which browser origins (e.g the booking widget) can call the API
+	CORS middleware.CORSOptions
+
+	// RateLimits limits how often a single client can call a route, keyed by the method and pattern of the route
+	// without its version prefix, e.g "POST /bookings". Every route has its own buckets, routes without an entry are not limited.
+	RateLimits map[string]middleware.RateLimitOptions
+
+	// LegacyDeprecation is advertised on the unprefixed routes which alias /v1
+	LegacyDeprecation middleware.DeprecationOptions
+}
+
+// DefaultConfig returns the settings we run with in production.
+// The allowed CORS origins are read from the comma separated CORS_ALLOWED_ORIGINS environment variable.
+func DefaultConfig() Config {
+	return Config{
+		CORS: middleware.CORSOptions{
+			AllowedOrigins: allowedOriginsFromEnv(),
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+			AllowedHeaders: []string{"Content-Type", middleware.APIKeyHeader},
+			ExposedHeaders: []string{"Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+				"Deprecation", "Sunset", "Link"},
+			MaxAge: 10 * time.Minute,
+		},
+		RateLimits: map[string]middleware.RateLimitOptions{
+			// one client cannot grab every seat
+			RouteCreateBooking: {Requests: 10, Per: time.Minute, IdleTTL: 10 * time.Minute},
+			// holds take seats like bookings
+			RouteCreateHold:  {Requests: 10, Per: time.Minute, IdleTTL: 10 * time.Minute},
+			RouteConvertHold: {Requests: 10, Per: time.Minute, IdleTTL: 10 * time.Minute},
+		},
+		LegacyDeprecation: middleware.DeprecationOptions{
+			DeprecatedAt:    time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+			Sunset:          time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+			SuccessorPrefix: "/v1",
+		},
+	}
+}
+
 // Routes initializes and returns an HTTP handler with all the routes for the application.
 func Routes() http.Handler {
+	return NewRouter(DefaultConfig())
+}
+
+// NewRouter builds the HTTP handler with all the routes for the application using the given config.
+func NewRouter(cfg Config) http.Handler {
 	mux := chi.NewRouter()
 
-	// -POST / classes: Handles the creating of class
-	mux.Post("/classes", handlers.PostCreateClass)
+	// tagging every request with an ID so that we can correlate logs
+	mux.Use(chimiddleware.RequestID)
 
-	// -POSt /bookings: Handles the bookings for a class
-	mux.Post("/bookings", handlers.PostCreateBooking)
+	// recording request counts and durations for every route
+	mux.Use(metrics.Instrument)
+
+	// turning panics in handlers into 500 responses instead of dropped connections
+	mux.Use(middleware.Recoverer)
+
+	// answering preflight requests and adding CORS headers for the booking widget
+	mux.Use(middleware.CORS(cfg.CORS))
+
+	// -GET /metrics: exposes the metrics in Prometheus text format
+	mux.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	// -GET /openapi.json: the OpenAPI document generated from the route specs
+	mux.Method(http.MethodGet, "/openapi.json", openapi.Handler(Spec()))
+
+	// the limiters are shared by every version so that a client cannot double its quota by switching paths
+	shared := sharedMiddleware{rateLimits: make(map[string]func(http.Handler) http.Handler, len(cfg.RateLimits))}
+	for route, opts := range cfg.RateLimits {
+		shared.rateLimits[route] = middleware.RateLimit(opts)
+	}
+
+	// mounting every version of the API under its own prefix
+	for _, version := range apiVersions() {
+		mux.Route(version.prefix, version.routes(shared))
+	}
+
+	// the unprefixed paths we started with are kept as deprecated aliases of /v1, the routes added since are only under /v1.
+	// The calendar feed was announced as /classes.ics, so the calendars subscribed to it keep working.
+	mux.Group(func(r chi.Router) {
+		r.Use(middleware.Deprecation(cfg.LegacyDeprecation))
+		r.Post("/classes", handlers.PostCreateClass)
+		r.With(shared.rateLimit(RouteCreateBooking)).Post("/bookings", handlers.PostCreateBooking)
+		r.Get("/classes.ics", handlers.GetClassesCalendar)
+	})
 
 	return mux
 }
+
+// legacyRoutes are the routes registered without a prefix, they are documented as deprecated
+var legacyRoutes = []struct{ method, path string }{
+	{http.MethodPost, "/classes"},
+	{http.MethodPost, "/bookings"},
+	{http.MethodGet, "/classes.ics"},
+}
+
+// isLegacyRoute reports whether the route is also served without a prefix
+func isLegacyRoute(method, path string) bool {
+	for _, legacy := range legacyRoutes {
+		if legacy.method == method && legacy.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// the rate limited routes, as keyed in Config.RateLimits
+const (
+	RouteCreateBooking = "POST /bookings"
+	RouteCreateHold    = "POST /holds"
+	RouteConvertHold   = "POST /holds/{id}/booking"
+)
+
+// sharedMiddleware holds middleware that has state which should be shared across API versions
+type sharedMiddleware struct {
+	rateLimits map[string]func(http.Handler) http.Handler
+}
+
+// rateLimit returns the limiter of the route, or a middleware doing nothing when the route is not limited
+func (s sharedMiddleware) rateLimit(route string) func(http.Handler) http.Handler {
+	if limit, found := s.rateLimits[route]; found {
+		return limit
+	}
+	return func(next http.Handler) http.Handler { return next }
+}
+
+// apiVersion is a version of the API mounted under its own prefix.
+// Each version registers its own routes and documents them in its spec, so a /v2 can use different request types next to /v1.
+type apiVersion struct {
+	prefix string
+	routes func(shared sharedMiddleware) func(r chi.Router)
+	spec   func() []openapi.Route
+}
+
+func apiVersions() []apiVersion {
+	return []apiVersion{
+		{prefix: "/v1", routes: v1Routes, spec: v1Spec},
+	}
+}
+
+func allowedOriginsFromEnv() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
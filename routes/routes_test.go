@@ -1,8 +1,15 @@
 package routes
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/MeherKandukuri/studioClasses_API/middleware"
 	"github.com/go-chi/chi"
 )
 
@@ -17,4 +24,191 @@ func TestRoutes(t *testing.T) {
 	default:
 		t.Errorf("Type mismatch: Expected *chi.Mux, got %T", v)
 	}
-}
\ No newline at end of file
+}
+
+// the metrics endpoint should be mounted and expose our domain metrics
+func TestRoutes_Metrics(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	for _, name := range []string{"studio_bookings_created_total", "studio_bookings_rejected_total", "studio_class_fill_ratio"} {
+		if !strings.Contains(rec.Body.String(), "# TYPE "+name) {
+			t.Errorf("expected %s to be exposed", name)
+		}
+	}
+}
+
+// the bookings route should be rate limited with the configured options
+func TestNewRouter_BookingsRateLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits[RouteCreateBooking] = middleware.RateLimitOptions{Requests: 1, Per: time.Minute}
+
+	mux := NewRouter(cfg)
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(`{}`))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+
+	// the first request is let through to the handler which rejects the empty payload
+	if codes[0] != http.StatusBadRequest || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("expected status codes [400 429], got %v", codes)
+	}
+}
+
+// the preflight for the booking widget should not get a 405 from the router
+func TestNewRouter_CORSPreflight(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CORS.AllowedOrigins = []string{"https://*.example.com"}
+
+	req := httptest.NewRequest(http.MethodOptions, "/bookings", nil)
+	req.Header.Set("Origin", "https://widget.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rec := httptest.NewRecorder()
+	NewRouter(cfg).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://widget.example.com" {
+		t.Errorf("expected the widget origin to be allowed, got %q", got)
+	}
+}
+
+// origins are read from the environment
+func TestDefaultConfig_CORSOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://studio.example.com, https://*.widgets.example.com,")
+
+	expected := []string{"https://studio.example.com", "https://*.widgets.example.com"}
+	if got := DefaultConfig().CORS.AllowedOrigins; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+// the API is served under /v1 and the legacy paths are deprecated aliases
+func TestNewRouter_Versions(t *testing.T) {
+	mux := Routes()
+
+	tests := []struct {
+		path       string
+		deprecated bool
+	}{
+		{"/v1/classes", false},
+		{"/classes", true},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{}`))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// the empty payload reaching the handler is rejected by validation
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", tc.path, rec.Code)
+		}
+
+		gotDeprecated := rec.Header().Get("Deprecation") != "" && rec.Header().Get("Sunset") != ""
+		if gotDeprecated != tc.deprecated {
+			t.Errorf("%s: expected deprecated to be %v, got %v", tc.path, tc.deprecated, gotDeprecated)
+		}
+	}
+}
+
+// switching between /v1 and the legacy path should not give a client a fresh rate limit
+func TestNewRouter_RateLimitSharedAcrossVersions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits[RouteCreateBooking] = middleware.RateLimitOptions{Requests: 1, Per: time.Minute}
+	mux := NewRouter(cfg)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/bookings", strings.NewReader(`{}`)))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", rec.Code)
+	}
+}
+
+// only the routes we started with are aliased without the prefix, and the spec documents exactly those
+func TestNewRouter_LegacyRoutes(t *testing.T) {
+	mux := Routes()
+	for _, path := range []string{"/holds", "/webhooks", "/plans", "/rooms", "/export/classes"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound && rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: expected no legacy alias, got status %d", path, rec.Code)
+		}
+	}
+
+	var deprecated []string
+	for path, item := range Spec().Paths {
+		for method, operation := range item {
+			if operation.Deprecated {
+				deprecated = append(deprecated, method+" "+path)
+			}
+		}
+	}
+	sort.Strings(deprecated)
+	if expected := []string{"get /classes.ics", "post /bookings", "post /classes"}; !reflect.DeepEqual(deprecated, expected) {
+		t.Errorf("expected the deprecated routes %v, got %v", expected, deprecated)
+	}
+}
+
+// the widget lets go of holds with DELETE and the admin assigns instructors with PUT, their preflights must be allowed
+func TestNewRouter_CORSPreflightMethods(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CORS.AllowedOrigins = []string{"https://widget.example.com"}
+
+	for method, path := range map[string]string{http.MethodDelete: "/v1/holds/3f2a9c1e0b7d4a65", http.MethodPut: "/v1/classes/2024-10-02/instructor"} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "https://widget.example.com")
+		req.Header.Set("Access-Control-Request-Method", method)
+		rec := httptest.NewRecorder()
+		NewRouter(cfg).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent || !strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), method) {
+			t.Errorf("expected the %s preflight to be allowed, got %d %q", method, rec.Code, rec.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+// every route has its own limit and buckets, using up the one of the bookings leaves the holds alone
+func TestNewRouter_RateLimitPerRoute(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = map[string]middleware.RateLimitOptions{
+		RouteCreateBooking: {Requests: 1, Per: time.Minute},
+		RouteCreateHold:    {Requests: 2, Per: time.Minute},
+	}
+	mux := NewRouter(cfg)
+
+	codes := []int{}
+	for _, path := range []string{"/v1/bookings", "/v1/bookings", "/v1/holds", "/v1/holds", "/v1/holds", "/v1/holds/unknown/booking"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`)))
+		codes = append(codes, rec.Code)
+	}
+	limited := []bool{false, true, false, false, true, false}
+	for i, code := range codes {
+		if (code == http.StatusTooManyRequests) != limited[i] {
+			t.Fatalf("expected the requests limited as %v, got %v", limited, codes)
+		}
+	}
+}
+
+// calendars subscribed to the feed before /v1 keep getting it
+func TestNewRouter_CalendarAlias(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/classes.ics", nil))
+
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") || rec.Header().Get("Deprecation") == "" {
+		t.Errorf("expected the deprecated calendar feed, got %d %v", rec.Code, rec.Header())
+	}
+}
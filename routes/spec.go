@@ -0,0 +1,571 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/MeherKandukuri/studioClasses_API/handlers"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+	"github.com/MeherKandukuri/studioClasses_API/openapi"
+)
+
+// common responses shared by the routes
+var (
+	badRequest      = openapi.ResponseSpec{Description: "The payload is invalid", ContentType: "text/plain", Body: ""}
+	notFound        = openapi.ResponseSpec{Description: "The resource does not exist", ContentType: "text/plain", Body: ""}
+	conflict        = openapi.ResponseSpec{Description: "The request conflicts with existing data", ContentType: "text/plain", Body: ""}
+	tooManyRequests = openapi.ResponseSpec{Description: "The client is rate limited, see Retry-After", ContentType: "text/plain", Body: ""}
+	importApplied   = openapi.ResponseSpec{Description: "Every row was accepted, the rows were stored unless it was a dry run", Body: handlers.ImportReport{}}
+	importRejected  = openapi.ResponseSpec{Description: "At least one row was rejected, nothing was stored", Body: handlers.ImportReport{}}
+	calendar        = openapi.ResponseSpec{Description: "An iCalendar (RFC 5545) feed", ContentType: "text/calendar", Body: ""}
+	internalError   = openapi.ResponseSpec{Description: "Unexpected server error", ContentType: "application/problem+json", Body: helpers.ProblemDetails{}}
+)
+
+// imports take one row per line, the header of a CSV file uses the json field names
+var importContentTypes = []string{"text/csv", "application/x-ndjson"}
+
+// exports are filtered by class date, both bounds are optional and inclusive
+var exportRangeParameters = []openapi.Parameter{
+	{Name: "from", In: "query", Description: "First class date to export (YYYY-MM-DD)", Schema: &openapi.Schema{Type: "string", Format: "date"}},
+	{Name: "to", In: "query", Description: "Last class date to export (YYYY-MM-DD)", Schema: &openapi.Schema{Type: "string", Format: "date"}},
+}
+
+var notAcceptable = openapi.ResponseSpec{Description: "Only text/csv and application/json can be produced", ContentType: "text/plain", Body: ""}
+
+var dryRunParameter = openapi.Parameter{
+	Name:        "dry_run",
+	In:          "query",
+	Description: "Validate the rows and report without storing anything",
+	Schema:      &openapi.Schema{Type: "boolean"},
+}
+
+// v1Spec documents every route registered by v1Routes, paths are relative to the version prefix
+func v1Spec() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:  http.MethodPost,
+			Path:    "/classes",
+			Summary: "Create a class on every day between the start and end date",
+			Request: handlers.CreateClassRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:             {Description: "The classes were created", Body: helpers.MessageResponse{}},
+				http.StatusBadRequest:          badRequest,
+				http.StatusConflict:            conflict,
+				http.StatusInternalServerError: internalError,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/classes",
+			Summary: "List the class sessions, optionally between two dates, taught by an instructor and held in a room",
+			Query: append([]openapi.Parameter{
+				{Name: "instructor", In: "query", Description: "Id or name of the instructor teaching the sessions", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "room", In: "query", Description: "Id or name of the room the sessions are held in", Schema: &openapi.Schema{Type: "string"}},
+			}, exportRangeParameters...),
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:         {Description: "The class sessions sorted by date", Body: []handlers.ClassSession{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/classes/{date}/instructor",
+			Summary: "Assign an instructor to the class session on a date (YYYY-MM-DD)",
+			Request: handlers.AssignInstructorRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:                  {Description: "The instructor was assigned, the enrolled members are notified", Body: helpers.MessageResponse{}},
+				http.StatusBadRequest:          badRequest,
+				http.StatusNotFound:            notFound,
+				http.StatusConflict:            {Description: "The session started, or the instructor teaches another session at the same time", ContentType: "text/plain", Body: ""},
+				http.StatusInternalServerError: internalError,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/classes/{date}/instructor/history",
+			Summary: "Every change of instructor of the session on a date (YYYY-MM-DD), oldest first",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:         {Description: "The instructor changes", Body: []handlers.InstructorChangeResponse{}},
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/classes/substitutions",
+			Summary: "Assign a substitute instructor to one or more sessions and notify the enrolled members, all or nothing",
+			Request: handlers.SubstitutionRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:                  {Description: "The sessions were changed, with the number of members notified", Body: handlers.SubstitutionResponse{}},
+				http.StatusBadRequest:          badRequest,
+				http.StatusNotFound:            notFound,
+				http.StatusConflict:            {Description: "A session started, already has this instructor, or overlaps another session of the substitute", ContentType: "text/plain", Body: ""},
+				http.StatusInternalServerError: internalError,
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/instructors",
+			Summary: "Create an instructor",
+			Request: handlers.InstructorRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:    {Description: "The instructor was created, the Location header points at it", Body: handlers.InstructorResponse{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/instructors",
+			Summary: "List the instructors sorted by name",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The instructors", Body: []handlers.InstructorResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/instructors/{id}",
+			Summary: "Fetch an instructor",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The instructor", Body: handlers.InstructorResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/instructors/{id}",
+			Summary: "Replace the details of an instructor",
+			Request: handlers.InstructorRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:         {Description: "The updated instructor", Body: handlers.InstructorResponse{}},
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/instructors/{id}",
+			Summary: "Delete an instructor who is not assigned to any class",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusNoContent: {Description: "The instructor was deleted"},
+				http.StatusNotFound:  notFound,
+				http.StatusConflict:  {Description: "The instructor is still assigned to a class", ContentType: "text/plain", Body: ""},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/rooms",
+			Summary: "Create a room",
+			Request: handlers.RoomRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:    {Description: "The room was created, the Location header points at it", Body: handlers.RoomResponse{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/rooms",
+			Summary: "List the rooms sorted by name",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The rooms", Body: []handlers.RoomResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/rooms/{id}",
+			Summary: "Fetch a room",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The room", Body: handlers.RoomResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/rooms/{id}",
+			Summary: "Replace the details of a room",
+			Request: handlers.RoomRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:         {Description: "The updated room", Body: handlers.RoomResponse{}},
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+				http.StatusConflict:   {Description: "A class held in the room has a larger capacity than the new one", ContentType: "text/plain", Body: ""},
+			},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/rooms/{id}",
+			Summary: "Delete a room which does not host any class",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusNoContent: {Description: "The room was deleted"},
+				http.StatusNotFound:  notFound,
+				http.StatusConflict:  {Description: "The room still hosts a class", ContentType: "text/plain", Body: ""},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/bookings",
+			Summary: "Book a member into the class on a date",
+			Request: handlers.BookingRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated: {Description: "The member was enrolled, the Location header points at the booking", Body: helpers.MessageResponse{}},
+				http.StatusBadRequest: {
+					Description:  "The payload is invalid, or the class is in the past (problem details with code class_in_past)",
+					ContentType:  "text/plain",
+					Body:         "",
+					Alternatives: map[string]any{"application/problem+json": handlers.BookingWindowProblem{}},
+				},
+				http.StatusPaymentRequired:    {Description: "The member does not have enough credits for the class, or the drop-in payment is missing or failed", ContentType: "text/plain", Body: ""},
+				http.StatusServiceUnavailable: {Description: "The class is paid by drop-ins and no payment gateway is configured", ContentType: "text/plain", Body: ""},
+				http.StatusForbidden: {
+					Description:  "The member is suspended, or their plan does not cover the class (codes plan_limit_reached and class_not_in_plan)",
+					ContentType:  "text/plain",
+					Body:         "",
+					Alternatives: map[string]any{"application/problem+json": handlers.EntitlementProblem{}},
+				},
+				http.StatusConflict: {
+					Description:  "The member is already enrolled, the class is full or the spot is taken, or booking is not open (codes booking_not_open and booking_closed)",
+					ContentType:  "text/plain",
+					Body:         "",
+					Alternatives: map[string]any{"application/problem+json": handlers.BookingWindowProblem{}},
+				},
+				http.StatusTooManyRequests:     tooManyRequests,
+				http.StatusInternalServerError: internalError,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/bookings/{id}",
+			Summary: "Fetch a booking with its status",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The booking", Body: handlers.BookingResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/bookings/{id}/check-in",
+			Summary: "Check a member in for their booking, from an hour before the class until it ends",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The member was checked in", Body: helpers.MessageResponse{}},
+				http.StatusNotFound: notFound,
+				http.StatusConflict: conflict,
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/bookings/{id}/cancel",
+			Summary: "Cancel a booking, late cancellations are penalised following the cancellation policy",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The booking was cancelled, with the penalty applied if it was late", Body: handlers.CancellationResponse{}},
+				http.StatusNotFound: notFound,
+				http.StatusConflict: conflict,
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/holds",
+			Summary: "Hold a seat in the class on a date for a few minutes while the member checks out",
+			Request: handlers.HoldRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated: {Description: "The seat is held, the Location header points at the hold", Body: handlers.HoldResponse{}},
+				http.StatusBadRequest: {
+					Description:  "The payload is invalid, or the class is in the past (problem details with code class_in_past)",
+					ContentType:  "text/plain",
+					Body:         "",
+					Alternatives: map[string]any{"application/problem+json": handlers.BookingWindowProblem{}},
+				},
+				http.StatusForbidden: {Description: "The member is suspended", ContentType: "text/plain", Body: ""},
+				http.StatusConflict: {
+					Description:  "The member is already enrolled, the class is full or the spot is taken, or booking is not open (codes booking_not_open and booking_closed)",
+					ContentType:  "text/plain",
+					Body:         "",
+					Alternatives: map[string]any{"application/problem+json": handlers.BookingWindowProblem{}},
+				},
+				http.StatusTooManyRequests:     tooManyRequests,
+				http.StatusInternalServerError: internalError,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/holds/{id}",
+			Summary: "Fetch a hold with its status",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The hold", Body: handlers.HoldResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/holds/{id}/booking",
+			Summary: "Convert a hold into a booking, drop-ins pay with a payment token",
+			Request: handlers.ConvertHoldRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:            {Description: "The member was enrolled, the Location header points at the booking", Body: helpers.MessageResponse{}},
+				http.StatusBadRequest:         badRequest,
+				http.StatusPaymentRequired:    {Description: "The member does not have enough credits for the class, or the drop-in payment is missing or failed", ContentType: "text/plain", Body: ""},
+				http.StatusServiceUnavailable: {Description: "The class is paid by drop-ins and no payment gateway is configured", ContentType: "text/plain", Body: ""},
+				http.StatusForbidden: {
+					Description: "The member is suspended, or the plan of the member does not cover the class (codes plan_limit_reached and class_not_in_plan)",
+					ContentType: "application/problem+json",
+					Body:        handlers.EntitlementProblem{},
+				},
+				http.StatusNotFound:        notFound,
+				http.StatusConflict:        {Description: "The hold expired, was let go or was already converted, or bookings for the class closed", ContentType: "text/plain", Body: ""},
+				http.StatusTooManyRequests: tooManyRequests,
+			},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/holds/{id}",
+			Summary: "Let go of a hold, giving the seat back to the class",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusNoContent: {Description: "The hold was let go"},
+				http.StatusNotFound:  notFound,
+				http.StatusConflict:  {Description: "The hold expired, was let go or was already converted", ContentType: "text/plain", Body: ""},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/members/{id}/attendance",
+			Summary: "Attendance history of a member, the id is the member name",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The bookings of the member with their attendance status", Body: handlers.AttendanceHistory{}},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/members/{id}/credits",
+			Summary: "Buy a class pack for a member, the id is the member name",
+			Request: handlers.CreditPurchaseRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:    {Description: "The credits were added, with the new balance of the member", Body: handlers.CreditsResponse{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/members/{id}/credits",
+			Summary: "Credits balance of a member with the packs it comes from and the history of purchases, debits, refunds and expiries",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The credits of the member", Body: handlers.CreditsResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/members/{id}/fees",
+			Summary: "Fees a member owes, e.g for late cancellations, the id is the member name",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The fees of the member with their total", Body: handlers.FeesResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/plans",
+			Summary: "Create a membership plan",
+			Request: handlers.PlanRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:    {Description: "The plan was created, the Location header points at it", Body: handlers.PlanResponse{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/plans",
+			Summary: "List the membership plans sorted by name",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The plans", Body: []handlers.PlanResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/plans/{id}",
+			Summary: "Fetch a membership plan",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The plan", Body: handlers.PlanResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/plans/{id}",
+			Summary: "Delete a membership plan nobody is subscribed to",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusNoContent: {Description: "The plan was deleted"},
+				http.StatusNotFound:  notFound,
+				http.StatusConflict:  {Description: "A member is subscribed to the plan", ContentType: "text/plain", Body: ""},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/members/{id}/subscriptions",
+			Summary: "Subscribe a member to a membership plan, the id is the member name",
+			Request: handlers.SubscriptionRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:    {Description: "The member was subscribed", Body: handlers.SubscriptionResponse{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/members/{id}/subscriptions",
+			Summary: "Subscriptions of a member, with the usage of the current period of the active ones",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The subscriptions of the member", Body: []handlers.SubscriptionResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/webhooks",
+			Summary: "Subscribe a URL to events, the deliveries are signed with the secret of the webhook",
+			Request: handlers.WebhookRequest{},
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusCreated:    {Description: "The webhook was created, the secret is only returned here", Body: handlers.WebhookResponse{}},
+				http.StatusBadRequest: badRequest,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/webhooks",
+			Summary: "List the webhooks",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The webhooks, the oldest first", Body: []handlers.WebhookResponse{}},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/webhooks/{id}",
+			Summary: "Fetch a webhook",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The webhook", Body: handlers.WebhookResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/webhooks/{id}",
+			Summary: "Remove a webhook, pending retries are dropped",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusNoContent: {Description: "The webhook was removed"},
+				http.StatusNotFound:  notFound,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/webhooks/{id}/deliveries",
+			Summary: "The delivery log of a webhook, every attempt of the last deliveries",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:       {Description: "The attempts, the oldest first", Body: []handlers.WebhookDeliveryResponse{}},
+				http.StatusNotFound: notFound,
+			},
+		},
+		{
+			Method:              http.MethodPost,
+			Path:                "/import/classes",
+			Summary:             "Import classes from CSV or JSON Lines, validated like POST /classes",
+			Query:               []openapi.Parameter{dryRunParameter},
+			Request:             handlers.CreateClassRequest{},
+			RequestContentTypes: importContentTypes,
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:                   importApplied,
+				http.StatusBadRequest:           badRequest,
+				http.StatusUnsupportedMediaType: {Description: "The body is not CSV or JSON Lines", ContentType: "text/plain", Body: ""},
+				http.StatusUnprocessableEntity:  importRejected,
+			},
+		},
+		{
+			Method:              http.MethodPost,
+			Path:                "/import/bookings",
+			Summary:             "Import bookings from CSV or JSON Lines, validated like POST /bookings",
+			Query:               []openapi.Parameter{dryRunParameter},
+			Request:             handlers.BookingRequest{},
+			RequestContentTypes: importContentTypes,
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:                   importApplied,
+				http.StatusBadRequest:           badRequest,
+				http.StatusUnsupportedMediaType: {Description: "The body is not CSV or JSON Lines", ContentType: "text/plain", Body: ""},
+				http.StatusUnprocessableEntity:  importRejected,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/export/classes",
+			Summary: "Export the schedule as JSON or CSV depending on the Accept header",
+			Query:   exportRangeParameters,
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The classes ordered by date", Body: []handlers.ExportClass{},
+					Alternatives: map[string]any{"text/csv": ""}},
+				http.StatusBadRequest:    badRequest,
+				http.StatusNotAcceptable: notAcceptable,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/export/rosters",
+			Summary: "Export the sign-in sheets of the classes as JSON or CSV depending on the Accept header",
+			Query:   exportRangeParameters,
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The rosters ordered by date, in CSV there is one line per attendee", Body: []handlers.ExportRoster{},
+					Alternatives: map[string]any{"text/csv": ""}},
+				http.StatusBadRequest:    badRequest,
+				http.StatusNotAcceptable: notAcceptable,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/classes.ics",
+			Summary: "Calendar feed of every class of the studio",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: calendar,
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/members/{id}/bookings.ics",
+			Summary: "Calendar feed of the classes booked by a member, the id is the member name",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK:         calendar,
+				http.StatusBadRequest: badRequest,
+			},
+		},
+	}
+}
+
+// Spec returns the OpenAPI document describing the router.
+// Every versioned route is listed under its prefix and the legacy aliases are listed as deprecated.
+func Spec() *openapi.Document {
+	routes := []openapi.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/metrics",
+			Summary: "Metrics in the Prometheus text exposition format",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The metrics", ContentType: "text/plain", Body: ""},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/openapi.json",
+			Summary: "This OpenAPI document",
+			Responses: map[int]openapi.ResponseSpec{
+				http.StatusOK: {Description: "The OpenAPI document", Body: map[string]any{}},
+			},
+		},
+	}
+
+	for _, version := range apiVersions() {
+		for _, route := range version.spec() {
+			route.Path = version.prefix + route.Path
+			routes = append(routes, route)
+		}
+	}
+
+	for _, route := range v1Spec() {
+		if !isLegacyRoute(route.Method, route.Path) {
+			continue
+		}
+		route.Deprecated = true
+		route.Summary = strings.TrimSpace(route.Summary + " (deprecated alias of /v1" + route.Path + ")")
+		routes = append(routes, route)
+	}
+
+	return openapi.Build(openapi.Info{Title: "Studio Classes API", Version: "1.0.0"}, routes)
+}
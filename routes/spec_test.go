@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+// every route in the router must be documented, add the route to the spec when this fails
+func TestSpec_CoversRoutes(t *testing.T) {
+	doc := Spec()
+
+	mux, ok := Routes().(*chi.Mux)
+	if !ok {
+		t.Fatalf("expected Routes to return a *chi.Mux")
+	}
+
+	err := chi.Walk(mux, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !doc.HasOperation(method, route) {
+			t.Errorf("%s %s is missing from the OpenAPI spec", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not walk the routes: %v", err)
+	}
+}
+
+// the document is served at /openapi.json and describes the real json field names
+func TestRoutes_OpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("expected openapi 3.1.0, got %s", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/v1/classes"]["post"]; !ok {
+		t.Errorf("expected POST /v1/classes to be documented")
+	}
+	if _, ok := doc.Components.Schemas["CreateClassRequest"].Properties["class_name"]; !ok {
+		t.Errorf("expected CreateClassRequest to have the class_name property, got %v", doc.Components.Schemas["CreateClassRequest"])
+	}
+}
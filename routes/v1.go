@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"github.com/MeherKandukuri/studioClasses_API/handlers"
+	"github.com/go-chi/chi"
+)
+
+// v1Routes registers version 1 of the API, which uses the request types from the handlers package
+func v1Routes(shared sharedMiddleware) func(r chi.Router) {
+	return func(r chi.Router) {
+		// -POST /classes: Handles the creating of class
+		r.Post("/classes", handlers.PostCreateClass)
+
+		// -GET /classes: the class sessions, filtered by date range, instructor and room
+		r.Get("/classes", handlers.GetClasses)
+
+		// -PUT /classes/{date}/instructor: assigns an instructor to the session on a date
+		r.Put("/classes/{date}/instructor", handlers.PutClassInstructor)
+
+		// -GET /classes/{date}/instructor/history: every change of instructor of the session on a date
+		r.Get("/classes/{date}/instructor/history", handlers.GetClassInstructorHistory)
+
+		// -POST /classes/substitutions: a substitute takes over one or more sessions, enrolled members are notified
+		r.Post("/classes/substitutions", handlers.PostSubstituteInstructor)
+
+		// -/instructors: create, list, fetch, update and delete instructors
+		r.Post("/instructors", handlers.PostCreateInstructor)
+		r.Get("/instructors", handlers.GetInstructors)
+		r.Get("/instructors/{id}", handlers.GetInstructor)
+		r.Put("/instructors/{id}", handlers.PutInstructor)
+		r.Delete("/instructors/{id}", handlers.DeleteInstructor)
+
+		// -/rooms: create, list, fetch, update and delete the rooms classes are held in
+		r.Post("/rooms", handlers.PostCreateRoom)
+		r.Get("/rooms", handlers.GetRooms)
+		r.Get("/rooms/{id}", handlers.GetRoom)
+		r.Put("/rooms/{id}", handlers.PutRoom)
+		r.Delete("/rooms/{id}", handlers.DeleteRoom)
+
+		// -POST /bookings: Handles the bookings for a class, rate limited so that one client cannot grab every seat
+		r.With(shared.rateLimit(RouteCreateBooking)).Post("/bookings", handlers.PostCreateBooking)
+
+		// -GET /bookings/{id}: a single booking with its status
+		r.Get("/bookings/{id}", handlers.GetBooking)
+
+		// -POST /bookings/{id}/check-in: the front desk checks a member in when they show up
+		r.Post("/bookings/{id}/check-in", handlers.PostCheckIn)
+
+		// -POST /bookings/{id}/cancel: cancels a booking, applying the cancellation policy
+		r.Post("/bookings/{id}/cancel", handlers.PostCancelBooking)
+
+		// -/holds: holds a seat during checkout, converts the hold into a booking or lets it go.
+		// Holds take seats like bookings, so they are rate limited too
+		r.With(shared.rateLimit(RouteCreateHold)).Post("/holds", handlers.PostCreateHold)
+		r.Get("/holds/{id}", handlers.GetHold)
+		r.With(shared.rateLimit(RouteConvertHold)).Post("/holds/{id}/booking", handlers.PostConvertHold)
+		r.Delete("/holds/{id}", handlers.DeleteHold)
+
+		// -GET /members/{id}/attendance: attendance history of a member
+		r.Get("/members/{id}/attendance", handlers.GetMemberAttendance)
+
+		// -/members/{id}/credits: a member buys a class pack, and their balance with the history of the ledger
+		r.Post("/members/{id}/credits", handlers.PostPurchaseCredits)
+		r.Get("/members/{id}/credits", handlers.GetMemberCredits)
+
+		// -GET /members/{id}/fees: the fees a member owes, e.g for late cancellations
+		r.Get("/members/{id}/fees", handlers.GetMemberFees)
+
+		// -/plans: create, list, fetch and delete membership plans
+		r.Post("/plans", handlers.PostCreatePlan)
+		r.Get("/plans", handlers.GetPlans)
+		r.Get("/plans/{id}", handlers.GetPlan)
+		r.Delete("/plans/{id}", handlers.DeletePlan)
+
+		// -/members/{id}/subscriptions: subscribes a member to a plan, and their subscriptions with the usage of the current period
+		r.Post("/members/{id}/subscriptions", handlers.PostCreateSubscription)
+		r.Get("/members/{id}/subscriptions", handlers.GetMemberSubscriptions)
+
+		// -/webhooks: subscribes URLs to class and booking events, with the log of their deliveries
+		r.Post("/webhooks", handlers.PostCreateWebhook)
+		r.Get("/webhooks", handlers.GetWebhooks)
+		r.Get("/webhooks/{id}", handlers.GetWebhook)
+		r.Delete("/webhooks/{id}", handlers.DeleteWebhook)
+		r.Get("/webhooks/{id}/deliveries", handlers.GetWebhookDeliveries)
+
+		// -POST /import/classes and /import/bookings: all-or-nothing bulk imports from CSV or JSON Lines
+		r.Post("/import/classes", handlers.PostImportClasses)
+		r.Post("/import/bookings", handlers.PostImportBookings)
+
+		// -GET /export/classes and /export/rosters: schedule and sign-in sheets as CSV or JSON
+		r.Get("/export/classes", handlers.GetExportClasses)
+		r.Get("/export/rosters", handlers.GetExportRosters)
+
+		// -GET /classes.ics: iCalendar feed of the studio schedule
+		r.Get("/classes.ics", handlers.GetClassesCalendar)
+
+		// -GET /members/{id}/bookings.ics: iCalendar feed of the classes a member booked
+		r.Get("/members/{id}/bookings.ics", handlers.GetMemberBookingsCalendar)
+	}
+}
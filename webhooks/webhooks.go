@@ -0,0 +1,415 @@
+// Package webhooks delivers events to the URLs subscribed to them as signed JSON requests.
+// Handlers only publish the events, a worker running the Dispatcher delivers them in the background
+// and retries failed deliveries with exponential backoff, every attempt is kept in a delivery log.
+// The publisher is told once the deliveries of an event are over, so that it can keep the event until then.
+// The events of a class reach a subscription in the order they were published.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+	"github.com/MeherKandukuri/studioClasses_API/helpers"
+)
+
+// EventTypes are the events of the bus which can be subscribed to
+var EventTypes = []string{events.TypeClassCreated, events.TypeBookingCreated, events.TypeBookingCancelled}
+
+// headers sent with every delivery
+const (
+	HeaderEvent    = "X-Studio-Event"
+	HeaderDelivery = "X-Studio-Delivery"
+	// HeaderTimestamp is the unix time the attempt was signed at, it is part of the signature so that it cannot be replayed later
+	HeaderTimestamp = "X-Studio-Timestamp"
+	HeaderSignature = "X-Studio-Signature"
+)
+
+// DeliveryLogSize is how many attempts are kept in the delivery log of each subscription
+const DeliveryLogSize = 100
+
+// Subscription sends the events of the subscribed types to a URL
+type Subscription struct {
+	ID     string
+	URL    string
+	Events []string
+	// Secret signs the deliveries so that the receiver can tell they come from us
+	Secret    string
+	CreatedAt time.Time
+}
+
+// Wants reports whether the subscription is for events of the type
+func (s Subscription) Wants(eventType string) bool {
+	for _, event := range s.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body of a delivery
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+	// Key is the class the event is about, the events with the same key are delivered in order
+	Key string `json:"-"`
+}
+
+// Delivery is an attempt to deliver an event to a subscription
+type Delivery struct {
+	// ID is the same for every attempt of an event to a subscription, so that receivers can drop duplicates
+	ID             string
+	SubscriptionID string
+	EventID        string
+	EventType      string
+	Attempt        int
+	// StatusCode is the status the receiver answered with, zero when the request failed
+	StatusCode int
+	Error      string
+	Succeeded  bool
+	At         time.Time
+	Duration   time.Duration
+}
+
+// RetryPolicy tells how often a failed delivery is attempted and how long to wait between the attempts
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy attempts a delivery 6 times over about half an hour
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 6, InitialBackoff: time.Minute, MaxBackoff: 10 * time.Minute}
+}
+
+// Backoff returns how long to wait after the failed attempt, it doubles with every attempt up to MaxBackoff
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// Sign returns the signature of a delivery, the hex HMAC-SHA256 of "timestamp.body" with the secret of the subscription
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature was made for the timestamp and body with the secret, receivers use it to check a delivery
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+// job is an event to deliver to a subscription
+type job struct {
+	deliveryID   string
+	subscription Subscription
+	event        Event
+	body         []byte
+	// done is called once the delivery is over
+	done func(err error)
+}
+
+// Lanes is the number of queues of a dispatcher. The deliveries of a class to a subscription always go through the
+// same lane, which delivers them one at a time with their retries, while the other lanes carry on.
+const Lanes = 8
+
+// Dispatcher keeps the subscriptions and delivers the events published to them
+type Dispatcher struct {
+	client *http.Client
+	policy RetryPolicy
+	lanes  []chan job
+
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string][]Delivery
+}
+
+// NewDispatcher returns a dispatcher sending the deliveries with client, each lane holding up to size undelivered events
+func NewDispatcher(client *http.Client, policy RetryPolicy, size int) *Dispatcher {
+	lanes := make([]chan job, Lanes)
+	for i := range lanes {
+		lanes[i] = make(chan job, size)
+	}
+	return &Dispatcher{
+		client:        client,
+		policy:        policy,
+		lanes:         lanes,
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string][]Delivery),
+	}
+}
+
+// Subscribe adds or replaces the subscription with the same id
+func (d *Dispatcher) Subscribe(subscription Subscription) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions[subscription.ID] = subscription
+}
+
+// Unsubscribe removes the subscription and its delivery log, it reports false when there is no such subscription.
+// Events which are being delivered to it are not retried anymore.
+func (d *Dispatcher) Unsubscribe(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, found := d.subscriptions[id]; !found {
+		return false
+	}
+	delete(d.subscriptions, id)
+	delete(d.deliveries, id)
+	return true
+}
+
+// Subscription returns the subscription with the id
+func (d *Dispatcher) Subscription(id string) (Subscription, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subscription, found := d.subscriptions[id]
+	return subscription, found
+}
+
+// Subscriptions returns every subscription, the oldest first
+func (d *Dispatcher) Subscriptions() []Subscription {
+	d.mu.Lock()
+	list := make([]Subscription, 0, len(d.subscriptions))
+	for _, subscription := range d.subscriptions {
+		list = append(list, subscription)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Deliveries returns the delivery log of the subscription, the oldest attempt first
+func (d *Dispatcher) Deliveries(subscriptionID string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Delivery(nil), d.deliveries[subscriptionID]...)
+}
+
+// Publish queues the event for every subscription wanting it without blocking and returns how many deliveries were queued.
+// It fails for the subscriptions whose queue is full, the event should then be published again: the subscriptions which
+// got it get it twice with the same event id.
+// done, when not nil, is called once the queued deliveries are over, with the errors of the ones which could not be queued.
+// A delivery is over once it succeeded or was given up, it fails with the context of the worker when the worker stopped before.
+func (d *Dispatcher) Publish(event Event, done func(err error)) (int, error) {
+	if done == nil {
+		done = func(error) {}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		done(nil)
+		return 0, fmt.Errorf("could not encode %s event %s: %w", event.Type, event.ID, err)
+	}
+
+	var wanted []Subscription
+	for _, subscription := range d.Subscriptions() {
+		if subscription.Wants(event.Type) {
+			wanted = append(wanted, subscription)
+		}
+	}
+
+	queued := 0
+	var errs []error
+	acks := events.Ack(done).Split(len(wanted))
+	for i, subscription := range wanted {
+		select {
+		case d.lanes[lane(subscription.ID, event.Key)] <- job{deliveryID: helpers.NewID(), subscription: subscription, event: event, body: body, done: acks[i]}:
+			queued++
+		default:
+			err := fmt.Errorf("queue is full for %s event %s to %s", event.Type, event.ID, subscription.URL)
+			errs = append(errs, err)
+			acks[i](err)
+		}
+	}
+	return queued, errors.Join(errs...)
+}
+
+// Run delivers the queued events until the context is done, with one worker per lane.
+// A delivery is retried before the next one of its lane is attempted, so that the events of a class stay in order.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, queue := range d.lanes {
+		wg.Add(1)
+		go func(queue chan job) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-queue:
+					d.deliver(ctx, j)
+				}
+			}
+		}(queue)
+	}
+	wg.Wait()
+}
+
+// Flush delivers the events which are queued right now, retries included, and returns how many were delivered,
+// it is used on shutdown and by tests which dont run a worker
+func (d *Dispatcher) Flush(ctx context.Context) int {
+	delivered := 0
+	for _, queue := range d.lanes {
+		for drained := false; !drained; {
+			select {
+			case j := <-queue:
+				if d.deliver(ctx, j) {
+					delivered++
+				}
+			default:
+				drained = true
+			}
+		}
+	}
+	return delivered
+}
+
+// lane picks the lane of the deliveries of the key to the subscription
+func lane(subscriptionID, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(subscriptionID))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % Lanes)
+}
+
+// deliver attempts the delivery until it succeeds, fails for good or runs out of attempts, then tells the publisher
+func (d *Dispatcher) deliver(ctx context.Context, j job) bool {
+	delivered, err := d.attempts(ctx, j)
+	if j.done != nil {
+		j.done(err)
+	}
+	return delivered
+}
+
+// attempts sends the event until it succeeds, fails for good or runs out of attempts. It only fails when the context
+// is done before, a delivery which was given up is over as retrying it would not help.
+func (d *Dispatcher) attempts(ctx context.Context, j job) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		// the subscription might have been removed while we waited to retry
+		if _, found := d.Subscription(j.subscription.ID); !found {
+			return false, nil
+		}
+
+		delivery := d.attempt(ctx, j, attempt)
+		d.record(delivery)
+		if delivery.Succeeded {
+			return true, nil
+		}
+		if !retryable(delivery.StatusCode) || attempt >= d.policy.MaxAttempts {
+			log.Printf("webhooks: giving up on %s event %s for %s after %d attempts: %s",
+				j.event.Type, j.event.ID, j.subscription.URL, attempt, delivery.Error)
+			return false, nil
+		}
+
+		timer := time.NewTimer(d.policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends the event once
+func (d *Dispatcher) attempt(ctx context.Context, j job, attempt int) Delivery {
+	start := time.Now()
+	delivery := Delivery{
+		ID:             j.deliveryID,
+		SubscriptionID: j.subscription.ID,
+		EventID:        j.event.ID,
+		EventType:      j.event.Type,
+		Attempt:        attempt,
+		At:             start.UTC(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.subscription.URL, bytes.NewReader(j.body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "studioClasses-webhooks")
+	req.Header.Set(HeaderEvent, j.event.Type)
+	req.Header.Set(HeaderDelivery, j.deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(j.subscription.Secret, start.Unix(), j.body))
+
+	resp, err := d.client.Do(req)
+	delivery.Duration = time.Since(start)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	// draining the body lets the connection be reused, receivers should not send much back anyway
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Succeeded = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Succeeded {
+		delivery.Error = resp.Status
+	}
+	return delivery
+}
+
+// record adds the attempt to the delivery log of its subscription, dropping the oldest attempts
+func (d *Dispatcher) record(delivery Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, found := d.subscriptions[delivery.SubscriptionID]; !found {
+		return
+	}
+	deliveries := append(d.deliveries[delivery.SubscriptionID], delivery)
+	if len(deliveries) > DeliveryLogSize {
+		deliveries = deliveries[len(deliveries)-DeliveryLogSize:]
+	}
+	d.deliveries[delivery.SubscriptionID] = deliveries
+}
+
+// retryable reports whether a failed attempt is worth retrying, requests which did not get an answer are.
+// Receivers turning the delivery down with a client error other than a timeout or rate limit would do so again.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout
+}
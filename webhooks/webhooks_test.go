@@ -0,0 +1,230 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MeherKandukuri/studioClasses_API/events"
+)
+
+// receiver records the deliveries it gets and answers with the statuses it is given, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(t *testing.T, rec *receiver, types ...string) (*Dispatcher, Subscription) {
+	t.Helper()
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+
+	d := NewDispatcher(server.Client(), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, 10)
+	subscription := Subscription{ID: "w1", URL: server.URL, Events: types, Secret: "s3cret", CreatedAt: time.Now()}
+	d.Subscribe(subscription)
+	return d, subscription
+}
+
+// the backoff doubles after every attempt until it reaches the maximum
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if backoff := p.Backoff(attempt); backoff != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, backoff)
+		}
+	}
+}
+
+// deliveries are signed JSON requests which only go to the subscriptions wanting the event
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	rec := &receiver{}
+	d, _ := newTestDispatcher(t, rec, events.TypeBookingCreated)
+
+	if queued, err := d.Publish(Event{ID: "e1", Type: events.TypeClassCreated}, nil); queued != 0 || err != nil {
+		t.Errorf("expected no delivery for an event nobody subscribed to, got %d %v", queued, err)
+	}
+	d.Publish(Event{ID: "e2", Type: events.TypeBookingCreated, CreatedAt: time.Now(), Data: map[string]string{"name": "Meher"}}, nil)
+	if delivered := d.Flush(context.Background()); delivered != 1 {
+		t.Fatalf("expected 1 delivery, got %d", delivered)
+	}
+
+	req, body := rec.requests[0], rec.bodies[0]
+	if req.Header.Get(HeaderEvent) != events.TypeBookingCreated || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	if !Verify("s3cret", req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body) {
+		t.Errorf("expected a valid signature, got %s", req.Header.Get(HeaderSignature))
+	}
+	if Verify("other", req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body) {
+		t.Errorf("expected the signature not to match another secret")
+	}
+
+	var event struct {
+		ID   string            `json:"id"`
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID != "e2" || event.Data["name"] != "Meher" {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+// failed deliveries are retried with the same delivery id and every attempt is logged
+func TestDispatcher_Retries(t *testing.T) {
+	rec := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}}
+	d, subscription := newTestDispatcher(t, rec, events.TypeBookingCreated)
+
+	d.Publish(Event{ID: "e1", Type: events.TypeBookingCreated}, nil)
+	if delivered := d.Flush(context.Background()); delivered != 1 {
+		t.Fatalf("expected the delivery to succeed on the third attempt, got %d", delivered)
+	}
+
+	deliveries := d.Deliveries(subscription.ID)
+	if len(deliveries) != 3 || deliveries[0].StatusCode != 503 || deliveries[0].Succeeded || !deliveries[2].Succeeded || deliveries[2].Attempt != 3 {
+		t.Fatalf("unexpected delivery log %+v", deliveries)
+	}
+	if rec.requests[0].Header.Get(HeaderDelivery) != rec.requests[2].Header.Get(HeaderDelivery) {
+		t.Errorf("expected every attempt to have the same delivery id")
+	}
+}
+
+// receivers turning the delivery down are not retried, and deliveries stop after the last attempt
+func TestDispatcher_GivesUp(t *testing.T) {
+	rec := &receiver{statuses: []int{http.StatusGone}}
+	d, subscription := newTestDispatcher(t, rec, events.TypeBookingCreated)
+
+	d.Publish(Event{ID: "e1", Type: events.TypeBookingCreated}, nil)
+	if delivered := d.Flush(context.Background()); delivered != 0 || len(d.Deliveries(subscription.ID)) != 1 {
+		t.Errorf("expected a single attempt, got %+v", d.Deliveries(subscription.ID))
+	}
+
+	rec.statuses = []int{500, 500, 500, 500}
+	d.Publish(Event{ID: "e2", Type: events.TypeBookingCreated}, nil)
+	if delivered := d.Flush(context.Background()); delivered != 0 || len(d.Deliveries(subscription.ID)) != 4 {
+		t.Errorf("expected 3 more attempts, got %+v", d.Deliveries(subscription.ID))
+	}
+}
+
+// the publisher is told once the deliveries of an event are over, with an error when the worker stopped before
+func TestDispatcher_PublishDone(t *testing.T) {
+	rec := &receiver{statuses: []int{http.StatusGone}}
+	d, _ := newTestDispatcher(t, rec, events.TypeBookingCreated)
+	d.Subscribe(Subscription{ID: "w2", URL: "http://127.0.0.1:0", Events: []string{events.TypeBookingCreated}, Secret: "s3cret"})
+
+	var dones []error
+	done := func(err error) { dones = append(dones, err) }
+	d.Publish(Event{ID: "e1", Type: events.TypeClassCreated}, done)
+	if len(dones) != 1 || dones[0] != nil {
+		t.Fatalf("expected an event nobody wants to be over right away, got %v", dones)
+	}
+
+	d.Publish(Event{ID: "e2", Type: events.TypeBookingCreated}, done)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Flush(ctx)
+	if len(dones) != 2 || !errors.Is(dones[1], context.Canceled) {
+		t.Fatalf("expected the deliveries to fail with the stopped worker, got %v", dones)
+	}
+
+	// given up deliveries are over too, retrying them would not help
+	d.Publish(Event{ID: "e3", Type: events.TypeBookingCreated}, done)
+	d.Flush(context.Background())
+	if len(dones) != 3 || dones[2] != nil {
+		t.Errorf("expected the deliveries to be over, got %v", dones)
+	}
+}
+
+// Run delivers the events in the background until its context is done
+func TestDispatcher_Run(t *testing.T) {
+	rec := &receiver{}
+	d, subscription := newTestDispatcher(t, rec, events.TypeClassCreated)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	d.Publish(Event{ID: "e1", Type: events.TypeClassCreated}, nil)
+	deadline := time.Now().Add(time.Second)
+	for len(d.Deliveries(subscription.ID)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the event to be delivered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// the events of a class reach the receiver in order, even when the first one has to be retried
+func TestDispatcher_RunKeepsOrder(t *testing.T) {
+	rec := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	d, subscription := newTestDispatcher(t, rec, events.TypeBookingCreated, events.TypeBookingCancelled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	d.Publish(Event{ID: "e1", Type: events.TypeBookingCreated, Key: "c1"}, nil)
+	d.Publish(Event{ID: "e2", Type: events.TypeBookingCancelled, Key: "c1"}, nil)
+	deadline := time.Now().Add(time.Second)
+	for len(d.Deliveries(subscription.ID)) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 attempts, got %+v", d.Deliveries(subscription.ID))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var ids []string
+	for _, delivery := range d.Deliveries(subscription.ID) {
+		ids = append(ids, delivery.EventID)
+	}
+	if len(ids) != 3 || ids[0] != "e1" || ids[1] != "e1" || ids[2] != "e2" {
+		t.Errorf("expected e1 to be retried before e2 is sent, got %v", ids)
+	}
+}
+
+// publishing fails once the lane of the event is full, so that the event can be published again later
+func TestDispatcher_PublishFull(t *testing.T) {
+	d := NewDispatcher(http.DefaultClient, RetryPolicy{MaxAttempts: 1}, 1)
+	d.Subscribe(Subscription{ID: "w1", URL: "http://127.0.0.1:0", Events: []string{events.TypeBookingCreated}, Secret: "s3cret"})
+
+	if queued, err := d.Publish(Event{ID: "e1", Type: events.TypeBookingCreated, Key: "c1"}, nil); queued != 1 || err != nil {
+		t.Fatalf("expected the first event to be queued, got %d %v", queued, err)
+	}
+	if queued, err := d.Publish(Event{ID: "e2", Type: events.TypeBookingCreated, Key: "c1"}, nil); queued != 0 || err == nil {
+		t.Errorf("expected an error once the lane is full, got %d %v", queued, err)
+	}
+}